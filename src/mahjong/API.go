@@ -0,0 +1,131 @@
+package mahjong
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ServeHands serves the hands of a player as JSON, the query parameters are
+// player, opponent, rules, since, until, offset and limit, where since and
+// until are RFC 3339 times or dates
+func ServeHands(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respond(w, http.StatusMethodNotAllowed, apiError {"method not allowed"})
+		return
+	}
+	query, err := parseHandQuery(r)
+	if err != nil {
+		respond(w, http.StatusBadRequest, apiError {err.Error()})
+		return
+	}
+	page, err := Storage.HandRecords(query.own())
+	if err != nil {
+		respond(w, http.StatusInternalServerError, apiError {err.Error()})
+		return
+	}
+	respond(w, http.StatusOK, page)
+}
+
+// ServeLeaderboard serves a page of a leaderboard as JSON, the query
+// parameters are period, id, metric, player, offset and limit
+func ServeLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respond(w, http.StatusMethodNotAllowed, apiError {"method not allowed"})
+		return
+	}
+	if Leaderboards == nil {
+		respond(w, http.StatusNotFound, apiError {"leaderboards are disabled"})
+		return
+	}
+	values := r.URL.Query()
+	query  := LeaderboardQuery {Period: values.Get("period"), ID: values.Get("id"), Metric: values.Get("metric"), Player: values.Get("player")}
+	var err error
+	if query.Offset, err = parseInt(values.Get("offset")); err == nil {
+		query.Limit, err = parseInt(values.Get("limit"))
+	}
+	if err != nil {
+		respond(w, http.StatusBadRequest, apiError {err.Error()})
+		return
+	}
+	page, err := Leaderboards.Query(query)
+	if err == ErrNotFound {
+		respond(w, http.StatusNotFound, apiError {err.Error()})
+		return
+	}
+	if err != nil {
+		respond(w, http.StatusBadRequest, apiError {err.Error()})
+		return
+	}
+	respond(w, http.StatusOK, page)
+}
+
+// ServeArchives serves the periods of the leaderboards which ended as JSON,
+// the query parameter is period
+func ServeArchives(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respond(w, http.StatusMethodNotAllowed, apiError {"method not allowed"})
+		return
+	}
+	if Leaderboards == nil {
+		respond(w, http.StatusNotFound, apiError {"leaderboards are disabled"})
+		return
+	}
+	archives, err := Leaderboards.Archives(r.URL.Query().Get("period"))
+	if err != nil {
+		respond(w, http.StatusBadRequest, apiError {err.Error()})
+		return
+	}
+	respond(w, http.StatusOK, archives)
+}
+
+type apiError struct {
+	Error string
+}
+
+func parseHandQuery(r *http.Request) (HandQuery, error) {
+	values := r.URL.Query()
+	query  := HandQuery {Player: values.Get("player"), Opponent: values.Get("opponent"), Rules: values.Get("rules")}
+	if query.Player == "" {
+		return query, errors.New("player is required")
+	}
+	var err error
+	if query.Since, err = parseTime(values.Get("since")); err != nil {
+		return query, err
+	}
+	if query.Until, err = parseTime(values.Get("until")); err != nil {
+		return query, err
+	}
+	if query.Offset, err = parseInt(values.Get("offset")); err != nil {
+		return query, err
+	}
+	if query.Limit, err = parseInt(values.Get("limit")); err != nil {
+		return query, err
+	}
+	return query, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+func parseInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func respond(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
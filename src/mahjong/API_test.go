@@ -0,0 +1,55 @@
+package mahjong
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serve calls the handler with a request of the method and the URL, and
+// decodes the JSON response into value
+func serve(t *testing.T, handler http.HandlerFunc, method string, url string, value interface{}) int {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(method, url, nil))
+	if kind := recorder.Header().Get("Content-Type"); kind != "application/json" {
+		t.Errorf("%s %s: the content type is %q", method, url, kind)
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(value); err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	return recorder.Code
+}
+
+func TestServeHands(t *testing.T) {
+	handStore(t, 30)
+	var page HandPage
+	code := serve(t, ServeHands, http.MethodGet, "/api/hands?player=alice&opponent=carol&rules=chengdu&since=2026-01-01&until=2026-01-02T00:00:00Z&offset=2&limit=3", &page)
+	if code != http.StatusOK || page.Total != 11 || page.Offset != 2 || len(page.Hands) != 3 || page.Hands[0].GameID != "hand-17" {
+		t.Errorf("status %d, page of %d hands from %d of %d", code, len(page.Hands), page.Offset, page.Total)
+	}
+
+	if code := serve(t, ServeHands, http.MethodGet, "/api/hands?player=alice&since=2025-12-01", &page); code != http.StatusOK || page.Total != 30 {
+		t.Errorf("status %d, %d hands since before the account, want 30", code, page.Total)
+	}
+
+	cases := map[string]struct {
+		method string
+		url    string
+		code   int
+	}{
+		"no player":  {http.MethodGet,  "/api/hands",                        http.StatusBadRequest},
+		"bad since":  {http.MethodGet,  "/api/hands?player=alice&since=x",   http.StatusBadRequest},
+		"bad until":  {http.MethodGet,  "/api/hands?player=alice&until=1",   http.StatusBadRequest},
+		"bad offset": {http.MethodGet,  "/api/hands?player=alice&offset=a",  http.StatusBadRequest},
+		"bad limit":  {http.MethodGet,  "/api/hands?player=alice&limit=1.5", http.StatusBadRequest},
+		"not a GET":  {http.MethodPost, "/api/hands?player=alice",           http.StatusMethodNotAllowed},
+	}
+	for name, c := range cases {
+		var failure apiError
+		if code := serve(t, ServeHands, c.method, c.url, &failure); code != c.code || failure.Error == "" {
+			t.Errorf("%s: status %d with the error %q, want %d", name, code, failure.Error, c.code)
+		}
+	}
+}
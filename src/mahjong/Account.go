@@ -0,0 +1,298 @@
+package mahjong
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// accountMutex makes creating and upgrading an account atomic
+var accountMutex sync.Mutex
+
+// SessionTTL is how long a login session lasts since it's last used, and
+// how long the name of a guest who doesn't come back is kept
+var SessionTTL = 30 * 24 * time.Hour
+
+// Password hashing, PBKDF2 with HMAC-SHA256
+const (
+	hashIterations = 100000
+	hashLength     = 32
+	saltLength     = 16
+	minPassword    = 6
+	maxName        = 20
+)
+
+// Account errors
+var (
+	ErrInvalidName    = errors.New("invalid name")
+	ErrNameTaken      = errors.New("name is taken")
+	ErrShortPassword  = errors.New("password is too short")
+	ErrWrongPassword  = errors.New("wrong name or password")
+	ErrInvalidSession = errors.New("invalid session")
+	ErrNotGuest       = errors.New("account is not a guest")
+)
+
+// Account represents a player's account, a guest has no password and can be
+// upgraded to a full account by setting one
+type Account struct {
+	Name       string
+	Guest      bool
+	Salt       []byte
+	Hash       []byte
+	Iterations int
+	Created    time.Time
+	LastSeen   time.Time
+}
+
+// LoginSession represents a login of an account, the token is kept by the
+// client to resume the login after reconnecting
+type LoginSession struct {
+	Token   string
+	Name    string
+	Expires time.Time
+}
+
+// Register creates an account with the password and logs in
+func Register(name string, password string) (LoginSession, error) {
+	if !isValidName(name) {
+		return LoginSession{}, ErrInvalidName
+	}
+	if utf8.RuneCountInString(password) < minPassword {
+		return LoginSession{}, ErrShortPassword
+	}
+	accountMutex.Lock()
+	defer accountMutex.Unlock()
+	_, err := Storage.Account(name)
+	if err == nil {
+		return LoginSession{}, ErrNameTaken
+	}
+	if err != ErrNotFound {
+		return LoginSession{}, err
+	}
+	now     := time.Now()
+	account := Account {Name: name, Created: now, LastSeen: now}
+	account.setPassword(password)
+	if err := Storage.PutAccount(account); err != nil {
+		return LoginSession{}, err
+	}
+	if err := grantStartingCredits(name); err != nil {
+		return LoginSession{}, err
+	}
+	return newLoginSession(name)
+}
+
+// Authenticate checks the password of the account and logs in
+func Authenticate(name string, password string) (LoginSession, error) {
+	account, err := Storage.Account(name)
+	if err == ErrNotFound || err == nil && (account.Guest || !account.checkPassword(password)) {
+		return LoginSession{}, ErrWrongPassword
+	}
+	if err != nil {
+		return LoginSession{}, err
+	}
+	if err := touchAccount(name); err != nil {
+		return LoginSession{}, err
+	}
+	return newLoginSession(name)
+}
+
+// GuestLogin creates a guest account and logs in, the name of a guest who
+// hasn't come back in SessionTTL can be taken by a new guest, who doesn't
+// get anything the last one left
+func GuestLogin(name string) (LoginSession, error) {
+	if !isValidName(name) {
+		return LoginSession{}, ErrInvalidName
+	}
+	accountMutex.Lock()
+	defer accountMutex.Unlock()
+	account, err := Storage.Account(name)
+	if err == nil && (!account.Guest || time.Since(account.LastSeen) < SessionTTL) {
+		return LoginSession{}, ErrNameTaken
+	}
+	if err != nil && err != ErrNotFound {
+		return LoginSession{}, err
+	}
+	if err == nil {
+		if err := retireGuest(account); err != nil {
+			return LoginSession{}, err
+		}
+	}
+	now := time.Now()
+	if err := Storage.PutAccount(Account {Name: name, Guest: true, Created: now, LastSeen: now}); err != nil {
+		return LoginSession{}, err
+	}
+	return newLoginSession(name)
+}
+
+// Resume resumes the login of token and extends it
+func Resume(token string) (LoginSession, error) {
+	session, err := Storage.LoginSession(token)
+	if err == ErrNotFound || err == nil && time.Now().After(session.Expires) {
+		return LoginSession{}, ErrInvalidSession
+	}
+	if err != nil {
+		return LoginSession{}, err
+	}
+	if err := touchAccount(session.Name); err == ErrNotFound {
+		return LoginSession{}, ErrInvalidSession
+	} else if err != nil {
+		return LoginSession{}, err
+	}
+	session.Expires = time.Now().Add(SessionTTL)
+	return session, Storage.PutLoginSession(session)
+}
+
+// Upgrade sets the password of the guest account of token, the guest keeps
+// the name and the login and is granted the starting credits
+func Upgrade(token string, password string) error {
+	session, err := Resume(token)
+	if err != nil {
+		return err
+	}
+	accountMutex.Lock()
+	defer accountMutex.Unlock()
+	account, err := Storage.Account(session.Name)
+	if err != nil {
+		return err
+	}
+	if !account.Guest {
+		return ErrNotGuest
+	}
+	if utf8.RuneCountInString(password) < minPassword {
+		return ErrShortPassword
+	}
+	account.Guest = false
+	account.setPassword(password)
+	if err := Storage.PutAccount(account); err != nil {
+		return err
+	}
+	return grantStartingCredits(account.Name)
+}
+
+// EndSession ends the login of token, it fails if the login isn't of the
+// account
+func EndSession(account string, token string) error {
+	session, err := Storage.LoginSession(token)
+	if err == ErrNotFound || err == nil && session.Name != account {
+		return ErrInvalidSession
+	}
+	if err != nil {
+		return err
+	}
+	return Storage.DeleteLoginSession(token)
+}
+
+func newLoginSession(name string) (LoginSession, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return LoginSession{}, err
+	}
+	session := LoginSession {hex.EncodeToString(token), name, time.Now().Add(SessionTTL)}
+	return session, Storage.PutLoginSession(session)
+}
+
+// retireGuest clears what the guest whose name expired left, so a new guest
+// who takes the name starts afresh. The balance goes back to the bank and the
+// rating and the standings in progress are forgotten, the history before
+// the new guest is hidden by historyStart
+func retireGuest(account Account) error {
+	balance, err := Storage.Balance(account.Name)
+	if err != nil {
+		return err
+	}
+	if balance != 0 {
+		now    := time.Now()
+		id     := fmt.Sprintf("expire/%s/%d", account.Name, account.LastSeen.UnixNano())
+		expire := Settlement {id, now, []Transaction {{id + "/0", id, "expire", "訪客過期", now, []Posting {
+			{account.Name, BankAccount, -balance},
+			{BankAccount, account.Name, balance},
+		}}}}
+		if err := Storage.PostSettlement(expire); err != nil && err != ErrPosted {
+			return err
+		}
+	}
+	if Ratings != nil {
+		if err := Ratings.Forget(account.Name); err != nil {
+			return err
+		}
+	}
+	if Leaderboards != nil {
+		return Leaderboards.Forget(account.Name)
+	}
+	return nil
+}
+
+// historyStart returns when the history of the player of name starts, the
+// hands and the transactions before the account was created are of a guest
+// who had the name before
+func historyStart(name string) time.Time {
+	account, err := Storage.Account(name)
+	if err != nil {
+		return time.Time{}
+	}
+	return account.Created
+}
+
+func touchAccount(name string) error {
+	accountMutex.Lock()
+	defer accountMutex.Unlock()
+	account, err := Storage.Account(name)
+	if err != nil {
+		return err
+	}
+	account.LastSeen = time.Now()
+	return Storage.PutAccount(account)
+}
+
+func isValidName(name string) bool {
+	count := utf8.RuneCountInString(name)
+	return utf8.ValidString(name) && count > 0 && count <= maxName && name[0] != ' ' && name[0] != '#' && name[len(name) - 1] != ' '
+}
+
+func (account *Account) setPassword(password string) {
+	account.Salt = make([]byte, saltLength)
+	rand.Read(account.Salt)
+	account.Iterations = hashIterations
+	account.Hash       = pbkdf2([]byte(password), account.Salt, account.Iterations, hashLength)
+}
+
+func (account Account) checkPassword(password string) bool {
+	hash := pbkdf2([]byte(password), account.Salt, account.Iterations, len(account.Hash))
+	return len(account.Hash) > 0 && hmac.Equal(hash, account.Hash)
+}
+
+// pbkdf2 derives a key of length from the password and the salt by PBKDF2
+// with HMAC-SHA256 (RFC 8018)
+func pbkdf2(password []byte, salt []byte, iterations int, length int) []byte {
+	prf    := hmac.New(sha256.New, password)
+	size   := prf.Size()
+	blocks := (length + size - 1) / size
+	key    := make([]byte, 0, blocks * size)
+	u      := make([]byte, size)
+	index  := make([]byte, 4)
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(index, uint32(block))
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(index)
+		key = prf.Sum(key)
+		t  := key[len(key) - size:]
+		copy(u, t)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range u {
+				t[j] ^= u[j]
+			}
+		}
+	}
+	return key[:length]
+}
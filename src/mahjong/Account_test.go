@@ -0,0 +1,133 @@
+package mahjong
+
+import (
+	"testing"
+	"time"
+
+	"mahjong/engine"
+)
+
+func TestBotNameReserved(t *testing.T) {
+	for n := 1; n <= 3; n++ {
+		if isValidName(BotName(n)) {
+			t.Errorf("%s is a valid name", BotName(n))
+		}
+		if _, err := Register(BotName(n), "password"); err != ErrInvalidName {
+			t.Errorf("registering %s: %v", BotName(n), err)
+		}
+		if _, err := GuestLogin(BotName(n)); err != ErrInvalidName {
+			t.Errorf("logging in as %s: %v", BotName(n), err)
+		}
+	}
+}
+
+func TestGuestCredits(t *testing.T) {
+	if _, err := GuestLogin("guest-credits"); err != nil {
+		t.Fatal(err)
+	}
+	if balance, _ := Storage.Balance("guest-credits"); balance != 0 {
+		t.Errorf("a guest is granted %d credits", balance)
+	}
+	if tier, ok := GetTier("", true); !ok || tier.Name != PracticeTier {
+		t.Errorf("a guest gets the tier %q", tier.Name)
+	}
+	if _, ok := GetTier("beginner", true); ok {
+		t.Error("a guest can queue for a ranked tier")
+	}
+	if tier, ok := GetTier("", false); !ok || tier.Name != DefaultTier {
+		t.Errorf("a player gets the tier %q", tier.Name)
+	}
+
+	session, err := Register("player-credits", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance, _ := Storage.Balance(session.Name); balance != StartingCredits {
+		t.Errorf("a new account has %d credits", balance)
+	}
+	guest, _ := GuestLogin("upgraded-credits")
+	if err := Upgrade(guest.Token, "password"); err != nil {
+		t.Fatal(err)
+	}
+	if balance, _ := Storage.Balance(guest.Name); balance != StartingCredits {
+		t.Errorf("an upgraded guest has %d credits", balance)
+	}
+}
+
+func TestGuestNameReuse(t *testing.T) {
+	ratings, _ := LoadRatings(Storage)
+	book, _    := LoadLeaderboards(Storage)
+	Ratings, Leaderboards = ratings, book
+	defer func() {
+		Ratings, Leaderboards = nil, nil
+	}()
+
+	const name = "reused"
+	if _, err := GuestLogin(name); err != nil {
+		t.Fatal(err)
+	}
+	// what a guest of the old rules left
+	if err := grantStartingCredits(name); err != nil {
+		t.Fatal(err)
+	}
+	names  := [4]string{name, BotName(1), BotName(2), BotName(3)}
+	record := HandRecord {GameID: "reused-hand", Names: names, End: time.Now().Add(-time.Hour), Result: make([]engine.GameResult, 4)}
+	Ratings.Update(record.GameID, names, [4]bool{true}, [4]int{8, -8})
+	Leaderboards.Record(record, [4]bool{true}, [4]float64{1600})
+	Storage.PutHandRecord(record)
+
+	if _, err := GuestLogin(name); err != ErrNameTaken {
+		t.Fatalf("the name of an active guest is taken: %v", err)
+	}
+	account, _ := Storage.Account(name)
+	account.LastSeen = time.Now().Add(-SessionTTL - time.Hour)
+	Storage.PutAccount(account)
+	if _, err := GuestLogin(name); err != nil {
+		t.Fatal(err)
+	}
+
+	if balance, _ := Storage.Balance(name); balance != 0 {
+		t.Errorf("the new guest has %d credits", balance)
+	}
+	if rating := Ratings.Get(name); rating.Hands != 0 || rating.Rating != InitialRating || len(Ratings.History(name, 0)) != 0 {
+		t.Errorf("the new guest has the rating %v", rating)
+	}
+	if page, err := Leaderboards.Query(LeaderboardQuery {Player: name}); err != nil || page.Player != nil {
+		t.Errorf("the new guest is on the leaderboard: %v", page.Player)
+	}
+	if page, _ := getHands(HandQuery {Player: name}); page.Total != 0 {
+		t.Errorf("the new guest has %d hands", page.Total)
+	}
+	if page, _ := getHands(HandQuery {Player: BotName(1)}); page.Total == 0 {
+		t.Error("the hand is gone")
+	}
+	if transactions, _ := Storage.Transactions(name, 0, 1); len(transactions) != 1 || transactions[0].Reason != "expire" {
+		t.Errorf("the balance isn't returned to the bank: %v", transactions)
+	}
+}
+
+func TestEndSession(t *testing.T) {
+	alice, err := Register("alice-session", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := Register("bob-session", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := EndSession(alice.Name, bob.Token); err != ErrInvalidSession {
+		t.Errorf("ending another account's session: %v", err)
+	}
+	if _, err := Resume(bob.Token); err != nil {
+		t.Errorf("the session of another account is ended: %v", err)
+	}
+	if err := EndSession(alice.Name, alice.Token); err != nil {
+		t.Error(err)
+	}
+	if _, err := Resume(alice.Token); err != ErrInvalidSession {
+		t.Errorf("the ended session resumes: %v", err)
+	}
+	if err := EndSession(alice.Name, alice.Token); err != ErrInvalidSession {
+		t.Errorf("ending the session again: %v", err)
+	}
+}
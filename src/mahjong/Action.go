@@ -1,302 +1,106 @@
 package mahjong
 
 import (
-	"encoding/json"
 	"time"
+
+	"mahjong/engine"
 )
 
 const microSec = 1000000
 
-// COMMAND is a map of command type
-var COMMAND = map[string]int{
-	"NONE":   0,
-	"PON":    1,
-	"GON":    2,
-	"ONGON":  4,
-	"PONGON": 8,
-	"HU":     16,
-	"ZIMO":   32,
-}
-
-// NewAction creates a new action
-func NewAction(command int, tile Tile, score int) Action {
-	return Action{command, tile, score}
-}
-
-// NewActionSet creates a new action set
-func NewActionSet() ActionSet {
-	return make(ActionSet)
-}
-
-// Action represent a command made by player
-type Action struct {
-	Command int
-	Tile    Tile
-	Score   int
+// NewSocketAgent creates a new socket agent for the player with uuid
+func NewSocketAgent(uuid string) *SocketAgent {
+	return &SocketAgent {UUID: uuid}
 }
 
-// ToJSON converts action to json string
-func (act Action) ToJSON() string {
-	type Tmp struct {
-		Command int
-		Tile    string
-		Score   int
-	}
-	tmp     := Tmp {act.Command, act.Tile.ToString(), act.Score}
-	JSON, _ := json.Marshal(tmp)
-	return string(JSON)
+// SocketAgent is a player agent which asks the client through socket
+type SocketAgent struct {
+	UUID string
 }
 
-// JSONToAction converts json string to action
-func JSONToAction(actionStr string) Action {
-	type Tmp struct {
-		Command int
-		Tile    string
-		Score   int
+// player returns the agent's player, nil if the player is gone
+func (agent SocketAgent) player() *IPlayer {
+	player, ok := PlayerList.Get(agent.UUID)
+	if !ok || player.Socket == nil {
+		return nil
 	}
-	var t Tmp
-	json.Unmarshal([]byte(actionStr), &t)
-	return NewAction(t.Command, StringToTile(t.Tile), t.Score)
+	return &player
 }
 
-// ActionSet represents a set of action
-type ActionSet map[int][]Tile
-
-// ToJSON converts action set to json string
-func (set ActionSet) ToJSON() string {
-	type Tmp struct {
-		Key   int
-		Value []string
+// emit emits to the client if the player is connected
+func (agent SocketAgent) emit(event string, args ...interface{}) {
+	if player := agent.player(); player != nil {
+		(*player.Socket).Emit(event, args...)
 	}
-	var tmpSet []Tmp
-	for key, value := range set {
-		t     := ArrayToSuitSet(value)
-		tmp   := Tmp {key, t.ToStringArray()}
-		tmpSet = append(tmpSet, tmp)
-	}
-	JSON, _ := json.Marshal(tmpSet)
-	return string(JSON)
 }
 
 // ChangeTiles emits to client to get the change tiles
-func (player *Player) ChangeTiles() []Tile {
-	defaultChange := ArrayToSuitSet(player.defaultChangeTile()).ToStringArray()
-	waitingTime   := 30 * time.Second
+func (agent *SocketAgent) ChangeTiles(game engine.GameState, seat int, defaultTiles []engine.Tile) []engine.Tile {
+	defaultChange := engine.ArrayToSuitSet(defaultTiles).ToStringArray()
+	waitingTime   := time.Duration(game.Rules.ChangeTime) * time.Second
 	t := make([]interface{}, 3)
 	for i := 0; i < 3; i++ {
 		t[i] = defaultChange[i]
 	}
 
-	player.Socket().Emit("change", defaultChange, waitingTime / microSec)
-	val := player.waitForSocket("changeTile", t, waitingTime)
-	var changeTiles []Tile
-	if player.checkChangeTiles(val) {
+	val := agent.ask("change", "changeTile", t, waitingTime, defaultChange)
+	var changeTiles []engine.Tile
+	if agent.checkChangeTiles(val) {
 		valArr := val.([]interface{})
 		for i := 0; i < 3; i++ {
-			changeTiles = append(changeTiles, StringToTile(valArr[i].(string)))
+			changeTiles = append(changeTiles, engine.StringToTile(valArr[i].(string)))
 		}
 	} else {
-		changeTiles = StringArrayToTileArray(defaultChange)
+		agent.reject(seat, "changeTile", val)
+		changeTiles = engine.StringArrayToTileArray(defaultChange)
 	}
-	player.Hand.Sub(changeTiles)
-	player.room.BroadcastChange(player.ID)
 	return changeTiles
 }
 
 // ChooseLack emits to client to get the choose lack
-func (player *Player) ChooseLack() int {
+func (agent *SocketAgent) ChooseLack(game engine.GameState, seat int) int {
 	defaultLack := float64(0)
-	waitingTime := 10 * time.Second
-	go player.Socket().Emit("lack", defaultLack, waitingTime / microSec)
-	val := player.waitForSocket("chooseLack", defaultLack, waitingTime)
-	if (player.checkLack(val)) {
-		player.Lack = int(val.(float64))
-	} else {
-		player.Lack = 0
+	waitingTime := time.Duration(game.Rules.LackTime) * time.Second
+	val := agent.ask("lack", "chooseLack", defaultLack, waitingTime, defaultLack)
+	if (agent.checkLack(val)) {
+		return int(val.(float64))
 	}
-	return player.Lack
+	agent.reject(seat, "chooseLack", val)
+	return 0
 }
 
 // Throw emits to client to get the throw Tile
-func (player *Player) Throw(drawTile Tile) Tile {
-	if drawTile.Suit == -1 {
-		drawTile = player.Hand.At(0)
-	} 
-	defaultTile := drawTile.ToString()
-	waitingTime := 10 * time.Second
-	go player.Socket().Emit("throw", defaultTile, waitingTime / microSec)
-	val := player.waitForSocket("throwTile", defaultTile, waitingTime)
-	var throwTile Tile
-	if player.checkThrow(val) {
-		throwTile = StringToTile(val.(string))
-	} else {
-		throwTile = StringToTile(defaultTile)
+func (agent *SocketAgent) Throw(game engine.GameState, seat int, defaultTile engine.Tile) engine.Tile {
+	waitingTime := time.Duration(game.Rules.ThrowTime) * time.Second
+	val := agent.ask("throw", "throwTile", defaultTile.ToString(), waitingTime, defaultTile.ToString())
+	if agent.checkThrow(val) {
+		return engine.StringToTile(val.(string))
 	}
-	player.Hand.Sub(throwTile)
-	player.room.BroadcastThrow(player.ID, throwTile)
-	return throwTile
-}
-
-// Draw draws a Tile
-func (player *Player) Draw(drawTile Tile) Action {
-	player.Hand.Add(drawTile)
-	player.Socket().Emit("draw", drawTile.ToString())
-
-	var tai int
-	player.CheckHu(NewTile(-1, 0), &tai)
-	actionSet, command := player.getAvaliableAction(true, drawTile, tai)
-	playerAct          := NewAction(COMMAND["NONE"], drawTile, 0)
-
-	if command == COMMAND["NONE"] {
-		playerAct.Command = COMMAND["NONE"]
-		playerAct.Tile = drawTile
-	} else if player.IsHu {
-		if (command & COMMAND["ZIMO"]) != 0 {
-			playerAct.Command = COMMAND["ZIMO"]
-		} else if (command & COMMAND["ONGON"]) != 0 {
-			playerAct.Command = COMMAND["ONGON"]
-		} else if (command & COMMAND["PONGON"]) != 0 {
-			playerAct.Command = COMMAND["PONGON"]
-		}
-		playerAct.Tile = actionSet[playerAct.Command][0]
-	} else {
-		playerAct = player.Command(actionSet, command)
-	}
-
-	player.procDrawCommand(drawTile, &playerAct, tai)
-	return playerAct
+	agent.reject(seat, "throwTile", val)
+	return defaultTile
 }
 
 // Command emits to client to get command
-func (player *Player) Command(actionSet ActionSet, command int) Action {
-	defaultCommand := NewAction(COMMAND["NONE"], NewTile(-1, 0), 0).ToJSON()
-	waitingTime    := 10 * time.Second
-	go player.Socket().Emit("command", actionSet.ToJSON(), command, waitingTime / microSec)
-	val := player.waitForSocket("sendCommand", defaultCommand, waitingTime)
-	if player.checkCommand(val) {
-		return JSONToAction(val.(string))
-	} 
-	return JSONToAction(defaultCommand)
-}
-
-// Fail emits to client to notice the command is failed
-func (player *Player) Fail(command int) {
-	player.Socket().Emit("fail", command)
-}
-
-// Success emits to client to notice the command is successed
-func (player *Player) Success(from int, command int, tile Tile, score int) {
-	player.Socket().Emit("success", from, command, tile.ToString(), score)
-	player.room.BroadcastCommand(from, player.ID, command, tile, score)
-}
-
-func (player *Player) defaultChangeTile() []Tile {
-	var result []Tile
-	count := 0
-	for s := 0; s < 3 && count < 3; s++ {
-		if (player.Hand[s].Count()) >= 3 {
-			for v := uint(0); count < 3 && v < 9; v++ {
-				for n := uint(0); count < 3 && n < player.Hand[s].GetIndex(v); n++ {
-					result = append(result, NewTile(s, v))
-					count++
-				}
-			}
-		}
-	}
-	return result
-}
-
-func (player *Player) waitForSocket(eventName string, defaultValue interface{}, waitingTime time.Duration) interface{} {
-	c := make(chan interface{}, 1)
-	var val interface{}
-	go func() {
-		player.Socket().On(eventName, func(v interface{}) {
-			c <- v
-		})
-	}()
-	select {
-	case val = <-c:
-	case <-time.After(waitingTime):
-		val = defaultValue
+func (agent *SocketAgent) Command(game engine.GameState, seat int, actionSet engine.ActionSet, command int) engine.Action {
+	defaultCommand := engine.NewAction(engine.COMMAND["NONE"], engine.NewTile(-1, 0), 0).ToJSON()
+	waitingTime    := time.Duration(game.Rules.CommandTime) * time.Second
+	val := agent.ask("command", "sendCommand", defaultCommand, waitingTime, actionSet.ToJSON(), command)
+	if agent.checkCommand(val) {
+		return engine.JSONToAction(val.(string))
 	}
-	return val
+	agent.reject(seat, "sendCommand", val)
+	return engine.JSONToAction(defaultCommand)
 }
 
-func (player *Player) getAvaliableAction(isDraw bool, tile Tile, tai int) (ActionSet, int) {
-	actionSet := NewActionSet()
-	command   := 0
-
-	if isDraw {
-		actionSet, command = player.checkDrawAction(tile, tai)
-	} else {
-		actionSet, command = player.checkNonDrawAction(tile, tai)
+// ask emits the prompt with the waiting time, its ID and deadline, and waits
+// for the response, defaultValue is returned if there is none in time
+func (agent *SocketAgent) ask(event string, response string, defaultValue interface{}, waitingTime time.Duration, args ...interface{}) interface{} {
+	player := agent.player()
+	if player == nil || player.Prompts == nil {
+		return defaultValue
 	}
-	return actionSet, command
+	id, deadline := player.Prompts.Open(response, waitingTime)
+	args = append(args, waitingTime / microSec, id, deadline.UnixNano() / microSec)
+	(*player.Socket).Emit(event, args...)
+	return player.Prompts.Wait(id, defaultValue)
 }
-
-func (player *Player) checkDrawAction(tile Tile, tai int) (ActionSet, int) {
-	actionSet := NewActionSet()
-	command   := 0
-	if tai > 0 {
-		command |= COMMAND["ZIMO"]
-		actionSet[COMMAND["ZIMO"]] = append(actionSet[COMMAND["ZIMO"]], tile)
-	}
-	for s := 0; s < 3; s++ {
-		for v := uint(0); v < 9; v++ {
-			tmpTile := NewTile(s, v)
-			
-			if player.Hand[s].GetIndex(v) == 4 {
-				player.Hand.Sub(tmpTile)
-				if player.CheckGon(tmpTile) {
-					command |= COMMAND["ONGON"]
-					actionSet[COMMAND["ONGON"]] = append(actionSet[COMMAND["ONGON"]], tmpTile)
-				}
-				player.Hand.Add(tmpTile)
-			} else if player.Hand[s].GetIndex(v) == 1 && player.Door[s].GetIndex(v) == 3 {
-				player.Hand.Sub(tmpTile)
-				if player.CheckGon(tmpTile) {
-					command |= COMMAND["PONGON"]
-					actionSet[COMMAND["PONGON"]] = append(actionSet[COMMAND["PONGON"]], tmpTile)
-				}
-				player.Hand.Add(tmpTile)
-			}
-		}
-	}
-	return actionSet, command
-}
-
-func (player *Player) checkNonDrawAction(tile Tile, tai int) (ActionSet, int) {
-	actionSet := NewActionSet()
-	command   := 0
-	if tai > 0 {
-		command |= COMMAND["HU"]
-		actionSet[COMMAND["HU"]] = append(actionSet[COMMAND["HU"]], tile)
-	}
-	if player.Hand[tile.Suit].GetIndex(tile.Value) == 3 {
-		if player.CheckGon(tile) {
-			command |= COMMAND["GON"]
-			actionSet[COMMAND["GON"]] = append(actionSet[COMMAND["GON"]], tile)
-		}
-	}
-	if player.CheckPon(tile) {
-		command |= COMMAND["PON"]
-		actionSet[COMMAND["PON"]] = append(actionSet[COMMAND["PON"]], tile)
-	}
-	return actionSet, command
-}
-
-func (player *Player) procDrawCommand(drawTile Tile, act *Action, tai int) {
-	if (act.Command & COMMAND["ZIMO"]) != 0 {
-		act.Score = player.Hu(act.Tile, tai, act.Command, true, true, -1)
-	} else if (act.Command & (COMMAND["ONGON"] | COMMAND["PONGON"])) != 0 {
-		act.Score = player.Gon(act.Tile, act.Command, -1)
-	} else {
-		if player.IsHu {
-			act.Tile = drawTile
-			player.Hand.Sub(act.Tile)
-			player.room.BroadcastThrow(player.ID, drawTile)
-		} else {
-			act.Tile = player.Throw(drawTile)
-		}
-	}
-}
\ No newline at end of file
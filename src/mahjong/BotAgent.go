@@ -0,0 +1,45 @@
+package mahjong
+
+import (
+	"time"
+
+	"mahjong/ai"
+	"mahjong/engine"
+)
+
+// BotDelay is how long a bot seated by the server waits before each decision
+var BotDelay = time.Second
+
+// NewBotAgent creates a new bot agent which waits delay before each decision
+func NewBotAgent(delay time.Duration) *BotAgent {
+	return &BotAgent {Delay: delay}
+}
+
+// BotAgent is a player agent decided by the rule-based AI
+type BotAgent struct {
+	Delay time.Duration
+}
+
+// ChangeTiles chooses three tiles of the weakest suit
+func (agent *BotAgent) ChangeTiles(game engine.GameState, seat int, defaultTiles []engine.Tile) []engine.Tile {
+	time.Sleep(agent.Delay)
+	return ai.ChangeTiles(game.Seats[seat])
+}
+
+// ChooseLack chooses the weakest suit
+func (agent *BotAgent) ChooseLack(game engine.GameState, seat int) int {
+	time.Sleep(agent.Delay)
+	return ai.ChooseLack(game.Seats[seat])
+}
+
+// Throw throws the tile which keeps the hand closest to hu
+func (agent *BotAgent) Throw(game engine.GameState, seat int, defaultTile engine.Tile) engine.Tile {
+	time.Sleep(agent.Delay)
+	return ai.Throw(game, seat)
+}
+
+// Command chooses a command from the action set
+func (agent *BotAgent) Command(game engine.GameState, seat int, actionSet engine.ActionSet, command int) engine.Action {
+	time.Sleep(agent.Delay)
+	return ai.Command(game, seat, actionSet, command)
+}
@@ -2,64 +2,114 @@ package mahjong
 
 import (
 	"encoding/json"
+
+	"mahjong/engine"
 )
 
+func (room *Room) broadcast(event string, args ...interface{}) {
+	if room.IO != nil {
+		room.IO.BroadcastTo(room.Name, event, args...)
+	}
+}
+
 // BroadcastRemainTile broadcasts remain tile
-func (room Room) BroadcastRemainTile(num uint) {
-	room.IO.BroadcastTo(room.Name, "remainTile", num)
+func (room *Room) BroadcastRemainTile(num uint) {
+	room.broadcast("remainTile", num)
 }
 
 // BroadcastStopWaiting broadcasts stop waiting signal
-func (room Room) BroadcastStopWaiting() {
-	room.IO.BroadcastTo(room.Name, "stopWaiting")
+func (room *Room) BroadcastStopWaiting() {
+	room.broadcast("stopWaiting")
 }
 
 // BroadcastReady broadcasts the player's name who is ready
-func (room Room) BroadcastReady(name string) {
-	room.IO.BroadcastTo(room.Name, "broadcastReady", name)
+func (room *Room) BroadcastReady(name string) {
+	room.broadcast("broadcastReady", name)
 }
 
 // BroadcastGameStart broadcasts player list
-func (room Room) BroadcastGameStart() {
-	room.IO.BroadcastTo(room.Name, "broadcastGameStart", room.GetPlayerList())
+func (room *Room) BroadcastGameStart() {
+	room.broadcast("broadcastGameStart", room.GetPlayerList())
 }
 
 // BroadcastChange broadcasts the player's id who already change tiles
-func (room Room) BroadcastChange(id int) {
-	room.IO.BroadcastTo(room.Name, "broadcastChange", id)
+func (room *Room) BroadcastChange(id int) {
+	room.broadcast("broadcastChange", id)
 }
 
 // BroadcastLack broadcasts the player's id who already choose lack
-func (room Room) BroadcastLack() {
-	room.IO.BroadcastTo(room.Name, "broadcastLack", room.ChoosedLack)
+func (room *Room) BroadcastLack() {
+	room.broadcast("broadcastLack", room.GetLack())
+}
+
+// BroadcastDraw broadcasts the player's id who draw a tile, the remain count and
+// whether the tile is drawn from the back
+func (room *Room) BroadcastDraw(id int, num uint, back bool) {
+	room.broadcast("broadcastDraw", id, num, back)
 }
 
-// BroadcastDraw broadcasts the player's id who draw a tile
-func (room Room) BroadcastDraw(id int, num uint) {
-	room.IO.BroadcastTo(room.Name, "broadcastDraw", id, num)
+// BroadcastWall broadcasts the dice, where the wall is broken and the
+// amount of tiles of the wall
+func (room *Room) BroadcastWall(dice [2]int, breakAt int, total int) {
+	room.broadcast("wall", dice, breakAt, total)
 }
 
 // BroadcastThrow broadcasts the player's id and the tile he threw
-func (room Room) BroadcastThrow(id int, tile Tile) {
-	room.IO.BroadcastTo(room.Name, "broadcastThrow", id, tile.ToString())
+func (room *Room) BroadcastThrow(id int, tile engine.Tile) {
+	room.broadcast("broadcastThrow", id, tile.ToString())
 }
 
 // BroadcastCommand broadcasts the player's id and the command he made
-func (room Room) BroadcastCommand(from int, to int, command int, tile Tile, score int) {
-	if command == COMMAND["ONGON"] {
-		room.IO.BroadcastTo(room.Name, "broadcastCommand", from, to, command, "", score)
+func (room *Room) BroadcastCommand(from int, to int, command int, tile engine.Tile, score int) {
+	if command == engine.COMMAND["ONGON"] {
+		room.broadcast("broadcastCommand", from, to, command, "", score)
 	} else {
-		room.IO.BroadcastTo(room.Name, "broadcastCommand", from, to, command, tile.ToString(), score)
+		room.broadcast("broadcastCommand", from, to, command, tile.ToString(), score)
 	}
 }
 
-// BroadcastEnd broadcasts the game result
-func (room Room) BroadcastEnd(data []GameResult) {
+// BroadcastHandStart broadcasts the index of the hand, the number of hands
+// and the dealer
+func (room *Room) BroadcastHandStart(hand int, hands int, dealer int) {
+	room.broadcast("handStart", hand, hands, dealer)
+}
+
+// BroadcastHandEnd broadcasts the summary of a hand
+func (room *Room) BroadcastHandEnd(summary HandSummary) {
+	result, _ := json.Marshal(summary)
+	room.broadcast("handEnd", string(result))
+}
+
+// BroadcastMatchEnd broadcasts the final result of a match
+func (room *Room) BroadcastMatchEnd(data MatchResult) {
 	result, _ := json.Marshal(data)
-	room.IO.BroadcastTo(room.Name, "end", string(result))
+	room.broadcast("matchEnd", string(result))
+}
+
+// BroadcastRules broadcasts the rules of the game
+func (room *Room) BroadcastRules() {
+	result, _ := json.Marshal(room.Rules)
+	room.broadcast("rules", string(result))
+}
+
+// BroadcastFan broadcasts the patterns of a hu
+func (room *Room) BroadcastFan(id int, fans []engine.Fan) {
+	result, _ := json.Marshal(fans)
+	room.broadcast("broadcastFan", id, string(result))
+}
+
+// BroadcastEnd broadcasts the game result, the game id and the path of game log
+func (room *Room) BroadcastEnd(data []engine.GameResult, gameID string, path string) {
+	result, _ := json.Marshal(data)
+	room.broadcast("end", string(result), gameID, path)
+}
+
+// BroadcastBankrupt broadcasts the player's id who can't cover a payment
+func (room *Room) BroadcastBankrupt(id int) {
+	room.broadcast("broadcastBankrupt", id)
 }
 
 // BroadcastRobGon broadcasts rob gon
-func (room Room) BroadcastRobGon(id int, tile Tile) {
-	room.IO.BroadcastTo(room.Name, "robGon", id, tile.ToString())
+func (room *Room) BroadcastRobGon(id int, tile engine.Tile) {
+	room.broadcast("robGon", id, tile.ToString())
 }
\ No newline at end of file
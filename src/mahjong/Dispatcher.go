@@ -0,0 +1,132 @@
+package mahjong
+
+import (
+	"sync"
+	"time"
+)
+
+// Rejection code of a response
+const (
+	ErrStale     = "stale"
+	ErrDuplicate = "duplicate"
+	ErrUnknown   = "unknown"
+)
+
+// ResponseEvents are the socket events which answer a prompt
+var ResponseEvents = []string{"changeTile", "chooseLack", "throwTile", "sendCommand"}
+
+// keepAnswered is how many answered prompts are kept to find duplicates
+const keepAnswered = 32
+
+// NewDispatcher creates a dispatcher for a connection
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher {pending: make(map[int]*pendingPrompt), answered: make(map[int]bool)}
+}
+
+// Dispatcher routes the responses of a connection to its open prompts
+//
+// Every prompt gets an ID and a deadline, and a response is delivered only
+// to the open prompt of the same ID and event before the deadline. A
+// response to an answered prompt is a duplicate, to a prompt which is
+// expired is stale, and to a prompt never opened is unknown
+type Dispatcher struct {
+	mutex    sync.Mutex
+	lastID   int
+	pending  map[int]*pendingPrompt
+	answered map[int]bool
+	closed   bool
+}
+
+type pendingPrompt struct {
+	event    string
+	deadline time.Time
+	reply    chan interface{}
+}
+
+// Open opens a prompt answered by event and returns its ID and deadline
+func (d *Dispatcher) Open(event string, waitingTime time.Duration) (int, time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.lastID++
+	deadline := time.Now().Add(waitingTime)
+	if !d.closed {
+		d.pending[d.lastID] = &pendingPrompt {event, deadline, make(chan interface{}, 1)}
+	}
+	return d.lastID, deadline
+}
+
+// Wait waits for the response of the prompt until its deadline, and
+// returns defaultValue if there is none
+func (d *Dispatcher) Wait(id int, defaultValue interface{}) interface{} {
+	d.mutex.Lock()
+	prompt := d.pending[id]
+	d.mutex.Unlock()
+	if prompt == nil {
+		return defaultValue
+	}
+
+	timer := time.NewTimer(time.Until(prompt.deadline))
+	defer timer.Stop()
+	select {
+	case val, ok := <-prompt.reply:
+		if ok {
+			return val
+		}
+		return defaultValue
+	case <-timer.C:
+	}
+
+	d.mutex.Lock()
+	delete(d.pending, id)
+	d.mutex.Unlock()
+	select {
+	case val, ok := <-prompt.reply:
+		if ok {
+			return val
+		}
+	default:
+	}
+	return defaultValue
+}
+
+// Deliver delivers the response of event to the prompt of id, and returns
+// the rejection code if it's not delivered
+func (d *Dispatcher) Deliver(event string, id int, val interface{}) string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	prompt, ok := d.pending[id]
+	switch {
+	case ok && prompt.event == event && time.Now().Before(prompt.deadline):
+		delete(d.pending, id)
+		d.answer(id)
+		prompt.reply <- val
+		return ""
+	case d.answered[id]:
+		return ErrDuplicate
+	case ok && prompt.event != event || id <= 0 || id > d.lastID:
+		return ErrUnknown
+	default:
+		return ErrStale
+	}
+}
+
+// Close closes the dispatcher when the connection is gone, the open
+// prompts return their default value at once
+func (d *Dispatcher) Close() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.closed = true
+	for id, prompt := range d.pending {
+		close(prompt.reply)
+		delete(d.pending, id)
+	}
+}
+
+func (d *Dispatcher) answer(id int) {
+	d.answered[id] = true
+	for old := range d.answered {
+		if old <= d.lastID - keepAnswered {
+			delete(d.answered, old)
+		}
+	}
+}
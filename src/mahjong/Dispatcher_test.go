@@ -0,0 +1,114 @@
+package mahjong
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDispatcherDeliver(t *testing.T) {
+	d       := NewDispatcher()
+	id, _   := d.Open("throwTile", time.Second)
+	started := time.Now()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		if code := d.Deliver("throwTile", id, "c1"); code != "" {
+			t.Errorf("the response is rejected: %s", code)
+		}
+	}()
+	if val := d.Wait(id, "default"); val != "c1" {
+		t.Errorf("got %v, want c1", val)
+	}
+	if time.Since(started) >= time.Second {
+		t.Error("Wait waits until the deadline")
+	}
+	if code := d.Deliver("throwTile", id, "c2"); code != ErrDuplicate {
+		t.Errorf("a duplicate response: got %q, want %q", code, ErrDuplicate)
+	}
+}
+
+func TestDispatcherLateReply(t *testing.T) {
+	d     := NewDispatcher()
+	id, _ := d.Open("sendCommand", 10 * time.Millisecond)
+	if val := d.Wait(id, "default"); val != "default" {
+		t.Errorf("got %v, want the default", val)
+	}
+	if code := d.Deliver("sendCommand", id, 1); code != ErrStale {
+		t.Errorf("a late response: got %q, want %q", code, ErrStale)
+	}
+
+	// a response after the deadline but before Wait notices it
+	id, _ = d.Open("sendCommand", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if code := d.Deliver("sendCommand", id, 1); code != ErrStale {
+		t.Errorf("a response after the deadline: got %q, want %q", code, ErrStale)
+	}
+	if val := d.Wait(id, "default"); val != "default" {
+		t.Errorf("got %v, want the default", val)
+	}
+}
+
+func TestDispatcherUnknown(t *testing.T) {
+	d     := NewDispatcher()
+	id, _ := d.Open("changeTile", time.Second)
+	cases := []struct {
+		name  string
+		event string
+		id    int
+	}{
+		{"zero id",     "changeTile", 0},
+		{"negative id", "changeTile", -1},
+		{"future id",   "changeTile", id + 1},
+		{"wrong event", "chooseLack", id},
+	}
+	for _, c := range cases {
+		if code := d.Deliver(c.event, c.id, 0); code != ErrUnknown {
+			t.Errorf("%s: got %q, want %q", c.name, code, ErrUnknown)
+		}
+	}
+	if code := d.Deliver("changeTile", id, "ok"); code != "" {
+		t.Errorf("the prompt is closed by the unknown responses: %s", code)
+	}
+}
+
+func TestDispatcherClose(t *testing.T) {
+	d     := NewDispatcher()
+	id, _ := d.Open("chooseLack", time.Minute)
+	done  := make(chan interface{})
+	go func() {
+		done <- d.Wait(id, "default")
+	}()
+	time.Sleep(10 * time.Millisecond)
+	d.Close()
+	select {
+	case val := <-done:
+		if val != "default" {
+			t.Errorf("got %v, want the default", val)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait doesn't return when the dispatcher is closed")
+	}
+	if code := d.Deliver("chooseLack", id, 0); code != ErrStale {
+		t.Errorf("a response to a closed prompt: got %q, want %q", code, ErrStale)
+	}
+
+	id, _ = d.Open("chooseLack", time.Minute)
+	if val := d.Wait(id, "default"); val != "default" {
+		t.Errorf("a prompt opened after closing: got %v, want the default", val)
+	}
+}
+
+func TestDispatcherKeepsAnswered(t *testing.T) {
+	d        := NewDispatcher()
+	first, _ := d.Open("throwTile", time.Second)
+	d.Deliver("throwTile", first, 0)
+	for i := 0; i < keepAnswered; i++ {
+		id, _ := d.Open("throwTile", time.Second)
+		d.Deliver("throwTile", id, 0)
+	}
+	if code := d.Deliver("throwTile", first, 0); code != ErrStale {
+		t.Errorf("an answer older than the kept ones: got %q, want %q", code, ErrStale)
+	}
+	if len(d.answered) > keepAnswered {
+		t.Errorf("%d answers are kept", len(d.answered))
+	}
+}
@@ -0,0 +1,704 @@
+package mahjong
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"mahjong/engine"
+)
+
+// DataDir is the directory of the file store
+var DataDir = "."
+
+// SchemaVersion is the version of the layout of a file store
+const SchemaVersion = 1
+
+// Files of a file store
+const (
+	versionFile  = "version.json"
+	accountsFile = "accounts.jsonl"
+	sessionsFile = "sessions.jsonl"
+	ratingsFile  = "ratings.json"
+	historyFile  = "ratings.jsonl"
+	matchesFile  = "matches.jsonl"
+	handsFile    = "hands.jsonl"
+	ledgerFile   = "ledger.jsonl"
+	boardsFile   = "leaderboards.json"
+	archivesFile = "archives.jsonl"
+	revokedFile  = "revocations.jsonl"
+	gameLogDir   = "log"
+)
+
+// compactLines is how many lines a journal has at least before it's
+// compacted, it's compacted when it has twice as many lines as records
+var compactLines = 1000
+
+// migration upgrades the files of a store in dir to Version
+type migration struct {
+	Version int
+	Name    string
+	Up      func(dir string) error
+}
+
+// migrations are applied in order to a store older than their versions, a
+// migration must be safe to run again if the store stops before it's done.
+// Version 1 is the first layout, a new store starts at SchemaVersion
+var migrations = []migration {}
+
+// OpenFileStore opens the store in dir, the directory is created if there is
+// none and an older store is migrated to SchemaVersion
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Join(dir, gameLogDir), 0755); err != nil {
+		return nil, err
+	}
+	if err := migrate(dir); err != nil {
+		return nil, err
+	}
+	store := &FileStore {MemoryStore: NewMemoryStore(), dir: dir, logs: make(map[string]*os.File)}
+	var ratings []Rating
+	for file, value := range map[string]interface{} {
+		ratingsFile: &ratings,
+		boardsFile:  &store.boards,
+	} {
+		if err := readJSON(filepath.Join(dir, file), value); err != nil {
+			return nil, err
+		}
+	}
+	for _, rating := range ratings {
+		store.ratings[rating.Name] = rating
+	}
+	err := loadLines(filepath.Join(dir, historyFile), func(line []byte) error {
+		var changed []Rating
+		if err := json.Unmarshal(line, &changed); err != nil {
+			return err
+		}
+		for _, rating := range changed {
+			if len(rating.History) > 0 {
+				stored        := store.ratings[rating.Name]
+				stored.Name    = rating.Name
+				stored.History = append(stored.History, rating.History...)
+				rating         = stored
+			}
+			store.ratings[rating.Name] = rating
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the snapshot is behind the history if the store stopped between them
+	for name, rating := range store.ratings {
+		if rating.Hands == len(rating.History) {
+			continue
+		}
+		rating.Rating, rating.Hands = InitialRating, len(rating.History)
+		if rating.Hands > 0 {
+			rating.Rating = rating.History[rating.Hands - 1].Rating
+		}
+		store.ratings[name] = rating
+	}
+	err = loadLines(filepath.Join(dir, accountsFile), func(line []byte) error {
+		var account Account
+		if err := json.Unmarshal(line, &account); err != nil {
+			return err
+		}
+		store.accounts[account.Name] = account
+		store.accountLines++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = loadLines(filepath.Join(dir, sessionsFile), func(line []byte) error {
+		var session LoginSession
+		if err := json.Unmarshal(line, &session); err != nil {
+			return err
+		}
+		if session.Name == "" {
+			delete(store.sessions, session.Token)
+		} else {
+			store.sessions[session.Token] = session
+		}
+		store.sessionLines++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixNano()
+	err  = loadLines(filepath.Join(dir, revokedFile), func(line []byte) error {
+		var revocation Revocation
+		if err := json.Unmarshal(line, &revocation); err != nil {
+			return err
+		}
+		if now < revocation.Expires {
+			store.revoked = append(store.revoked, revocation)
+		}
+		store.revokedLines++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = loadLines(filepath.Join(dir, matchesFile), func(line []byte) error {
+		var result MatchResult
+		if err := json.Unmarshal(line, &result); err != nil {
+			return err
+		}
+		store.matches[result.MatchID] = result
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = loadLines(filepath.Join(dir, ledgerFile), func(line []byte) error {
+		var settlement Settlement
+		if err := json.Unmarshal(line, &settlement); err != nil {
+			return err
+		}
+		if err := store.ledger.check(settlement); err != nil {
+			return err
+		}
+		store.ledger.post(settlement)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = loadLines(filepath.Join(dir, archivesFile), func(line []byte) error {
+		var board Board
+		if err := json.Unmarshal(line, &board); err != nil {
+			return err
+		}
+		store.archives = append(store.archives, board)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = loadLines(filepath.Join(dir, handsFile), func(line []byte) error {
+		var record HandRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		store.handSpans = append(store.handSpans, handSpan {store.handSize, len(line)})
+		store.handIndex.add(record)
+		store.handSize += int64(len(line))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// FileStore is a store in a directory which needs no other service, the
+// records are kept in memory and written to JSON files on every change
+//
+// A table is rewritten as a whole to a temporary file which replaces the old
+// one, so a table is never left half written. The ratings are a snapshot
+// without the history, which is appended as a line of the ratings changed by
+// a hand. The accounts and the login
+// sessions are journals, every change is appended as a line and the last
+// line of a name or a token wins, a session of no name is deleted. A journal
+// is rewritten with only the records once it has compactLines lines and
+// twice as many as the records. The results of the matches and the finished
+// hands are appended to JSONL files, and every game log is a JSONL file of
+// its own. Only the index of the hands is kept in memory, and a page of them
+// is read from the file
+type FileStore struct {
+	*MemoryStore
+	dir          string
+	mutex        sync.Mutex
+	logMutex     sync.Mutex
+	logs         map[string]*os.File
+	handIndex    handIndex
+	handSpans    []handSpan
+	handSize     int64
+	accountLines int
+	sessionLines int
+	revokedLines int
+}
+
+// handSpan is where a hand record is in the file
+type handSpan struct {
+	Offset int64
+	Length int
+}
+
+// PutAccount creates or replaces the account
+func (store *FileStore) PutAccount(account Account) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := appendLine(filepath.Join(store.dir, accountsFile), account); err != nil {
+		return err
+	}
+	store.MemoryStore.PutAccount(account)
+	store.accountLines++
+	store.MemoryStore.mutex.RLock()
+	count := len(store.accounts)
+	store.MemoryStore.mutex.RUnlock()
+	if store.accountLines < compactLines || store.accountLines < 2 * count {
+		return nil
+	}
+	return store.saveAccounts()
+}
+
+// PutLoginSession creates or replaces the session, and drops the expired ones
+func (store *FileStore) PutLoginSession(session LoginSession) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := appendLine(filepath.Join(store.dir, sessionsFile), session); err != nil {
+		return err
+	}
+	store.MemoryStore.PutLoginSession(session)
+	return store.journalSession()
+}
+
+// DeleteLoginSession deletes the session of token
+func (store *FileStore) DeleteLoginSession(token string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := appendLine(filepath.Join(store.dir, sessionsFile), LoginSession {Token: token}); err != nil {
+		return err
+	}
+	store.MemoryStore.DeleteLoginSession(token)
+	return store.journalSession()
+}
+
+// CreateGameLog creates the log file of the game and returns its path
+func (store *FileStore) CreateGameLog(header LogHeader) (string, error) {
+	path, ok := store.gameLogPath(header.GameID)
+	if !ok {
+		return "", fmt.Errorf("invalid game id %q", header.GameID)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY | os.O_CREATE | os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	if err := writeLine(file, header); err != nil {
+		file.Close()
+		return "", err
+	}
+	store.logMutex.Lock()
+	defer store.logMutex.Unlock()
+	store.logs[header.GameID] = file
+	return path, nil
+}
+
+// AppendGameLog appends the record to the log file of the game
+func (store *FileStore) AppendGameLog(gameID string, record LogRecord) error {
+	store.logMutex.Lock()
+	defer store.logMutex.Unlock()
+	file, ok := store.logs[gameID]
+	if !ok {
+		return ErrNotFound
+	}
+	return writeLine(file, record)
+}
+
+// FinishGameLog appends the result to the log file of the game and closes it
+func (store *FileStore) FinishGameLog(gameID string, result LogResult) error {
+	store.logMutex.Lock()
+	defer store.logMutex.Unlock()
+	file, ok := store.logs[gameID]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(store.logs, gameID)
+	err := writeLine(file, result)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// GameLog reads the log file of the game
+func (store *FileStore) GameLog(gameID string) (LogHeader, []LogRecord, []engine.GameResult, error) {
+	path, ok := store.gameLogPath(gameID)
+	if !ok {
+		return LogHeader{}, nil, nil, ErrNotFound
+	}
+	header, records, result, err := ReadGameLog(path)
+	if os.IsNotExist(err) {
+		err = ErrNotFound
+	}
+	return header, records, result, err
+}
+
+// PutHandRecord appends the record of a finished hand
+func (store *FileStore) PutHandRecord(record HandRecord) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if err := appendBytes(filepath.Join(store.dir, handsFile), line); err != nil {
+		return err
+	}
+	store.handSpans = append(store.handSpans, handSpan {store.handSize, len(line)})
+	store.handIndex.add(record)
+	store.handSize += int64(len(line))
+	return nil
+}
+
+// HandRecords reads the page of the hands selected by the query
+func (store *FileStore) HandRecords(query HandQuery) (HandPage, error) {
+	store.mutex.Lock()
+	query = query.normalize()
+	positions, total := store.handIndex.query(query)
+	var spans []handSpan
+	for _, position := range positions {
+		spans = append(spans, store.handSpans[position])
+	}
+	store.mutex.Unlock()
+
+	page := HandPage {total, query.Offset, []HandRecord{}}
+	if len(spans) == 0 {
+		return page, nil
+	}
+	file, err := os.Open(filepath.Join(store.dir, handsFile))
+	if err != nil {
+		return page, err
+	}
+	defer file.Close()
+	for _, span := range spans {
+		line := make([]byte, span.Length)
+		if _, err := file.ReadAt(line, span.Offset); err != nil {
+			return page, err
+		}
+		var record HandRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return page, err
+		}
+		page.Hands = append(page.Hands, record)
+	}
+	return page, nil
+}
+
+// PutMatchResult appends the result of a match
+func (store *FileStore) PutMatchResult(result MatchResult) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := appendLine(filepath.Join(store.dir, matchesFile), result); err != nil {
+		return err
+	}
+	return store.MemoryStore.PutMatchResult(result)
+}
+
+// PutRatings appends the ratings with their changes to the history as a
+// line, and rewrites the snapshot of the ratings without the history
+func (store *FileStore) PutRatings(ratings []Rating) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := appendLine(filepath.Join(store.dir, historyFile), ratings); err != nil {
+		return err
+	}
+	store.MemoryStore.PutRatings(ratings)
+	store.MemoryStore.mutex.RLock()
+	snapshot := make([]Rating, 0, len(store.ratings))
+	for _, rating := range store.ratings {
+		snapshot = append(snapshot, Rating {rating.Name, rating.Rating, rating.Hands, nil})
+	}
+	store.MemoryStore.mutex.RUnlock()
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].Name < snapshot[j].Name
+	})
+	return writeJSON(filepath.Join(store.dir, ratingsFile), snapshot)
+}
+
+// PostSettlement appends the settlement to the ledger file as a line, so a
+// settlement which is cut off by a crash is dropped as a whole
+func (store *FileStore) PostSettlement(settlement Settlement) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.MemoryStore.mutex.Lock()
+	defer store.MemoryStore.mutex.Unlock()
+	if err := store.ledger.check(settlement); err != nil {
+		return err
+	}
+	if err := appendLine(filepath.Join(store.dir, ledgerFile), settlement); err != nil {
+		return err
+	}
+	store.ledger.post(settlement)
+	return nil
+}
+
+// PutLeaderboards replaces the boards of the periods in progress
+func (store *FileStore) PutLeaderboards(boards []Board) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.MemoryStore.PutLeaderboards(boards)
+	return writeJSON(filepath.Join(store.dir, boardsFile), boards)
+}
+
+// ArchiveBoard appends the final standings of a period to the archives, it
+// does nothing if the board is archived
+func (store *FileStore) ArchiveBoard(board Board) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.MemoryStore.mutex.Lock()
+	defer store.MemoryStore.mutex.Unlock()
+	if store.archived(board) {
+		return nil
+	}
+	if err := appendLine(filepath.Join(store.dir, archivesFile), board); err != nil {
+		return err
+	}
+	store.archives = append(store.archives, board)
+	return nil
+}
+
+// Close closes the log files of the games which aren't over
+func (store *FileStore) Close() error {
+	store.logMutex.Lock()
+	defer store.logMutex.Unlock()
+	var err error
+	for gameID, file := range store.logs {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		delete(store.logs, gameID)
+	}
+	return err
+}
+
+// PutRevocation appends the revocation to its journal, which is compacted
+// to the revocations which haven't expired
+func (store *FileStore) PutRevocation(revocation Revocation) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	path := filepath.Join(store.dir, revokedFile)
+	if err := appendLine(path, revocation); err != nil {
+		return err
+	}
+	store.MemoryStore.PutRevocation(revocation)
+	store.revokedLines++
+	revoked, _ := store.MemoryStore.Revocations()
+	if store.revokedLines < compactLines || store.revokedLines < 2 * len(revoked) {
+		return nil
+	}
+	var data []byte
+	for _, revocation := range revoked {
+		line, err := json.Marshal(revocation)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	if err := writeAtomic(path, data); err != nil {
+		return err
+	}
+	store.revokedLines = len(revoked)
+	return nil
+}
+
+// journalSession counts a line appended to the sessions journal and compacts
+// it if it's due, it's called with the mutex locked
+func (store *FileStore) journalSession() error {
+	store.sessionLines++
+	store.MemoryStore.mutex.RLock()
+	count := len(store.sessions)
+	store.MemoryStore.mutex.RUnlock()
+	if store.sessionLines < compactLines || store.sessionLines < 2 * count {
+		return nil
+	}
+	return store.saveSessions()
+}
+
+// saveAccounts compacts the accounts journal, it's called with the mutex
+// locked
+func (store *FileStore) saveAccounts() error {
+	store.MemoryStore.mutex.RLock()
+	accounts := make([]Account, 0, len(store.accounts))
+	for _, account := range store.accounts {
+		accounts = append(accounts, account)
+	}
+	store.MemoryStore.mutex.RUnlock()
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Name < accounts[j].Name
+	})
+	var data []byte
+	for _, account := range accounts {
+		line, err := json.Marshal(account)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	if err := writeAtomic(filepath.Join(store.dir, accountsFile), data); err != nil {
+		return err
+	}
+	store.accountLines = len(accounts)
+	return nil
+}
+
+// saveSessions compacts the sessions journal, it's called with the mutex
+// locked
+func (store *FileStore) saveSessions() error {
+	store.MemoryStore.mutex.RLock()
+	sessions := make([]LoginSession, 0, len(store.sessions))
+	for _, session := range store.sessions {
+		sessions = append(sessions, session)
+	}
+	store.MemoryStore.mutex.RUnlock()
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Token < sessions[j].Token
+	})
+	var data []byte
+	for _, session := range sessions {
+		line, err := json.Marshal(session)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	if err := writeAtomic(filepath.Join(store.dir, sessionsFile), data); err != nil {
+		return err
+	}
+	store.sessionLines = len(sessions)
+	return nil
+}
+
+func (store *FileStore) gameLogPath(gameID string) (string, bool) {
+	if gameID == "" || filepath.Base(gameID) != gameID || gameID[0] == '.' {
+		return "", false
+	}
+	return filepath.Join(store.dir, gameLogDir, gameID + ".jsonl"), true
+}
+
+// migrate applies the migrations newer than the version of the store in dir,
+// a store without a version is new and gets SchemaVersion
+func migrate(dir string) error {
+	var version struct {
+		Version int
+	}
+	path := filepath.Join(dir, versionFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		version.Version = SchemaVersion
+		return writeJSON(path, version)
+	}
+	if err := readJSON(path, &version); err != nil {
+		return err
+	}
+	if version.Version > SchemaVersion {
+		return fmt.Errorf("store version %d is newer than %d", version.Version, SchemaVersion)
+	}
+	for _, m := range migrations {
+		if m.Version <= version.Version {
+			continue
+		}
+		if err := m.Up(dir); err != nil {
+			return fmt.Errorf("migration %d (%s): %v", m.Version, m.Name, err)
+		}
+		version.Version = m.Version
+		if err := writeJSON(path, version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readJSON reads the JSON file at path into value, a missing file leaves
+// value unchanged
+func readJSON(path string, value interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+func writeJSON(path string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(path, data)
+}
+
+// loadLines calls fn with every line of the JSONL file at path, the last
+// line is cut off the file if it was left half written
+func loadLines(path string, fn func(line []byte) error) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	size   := int64(0)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				return os.Truncate(path, size)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+		size += int64(len(line))
+	}
+}
+
+// appendLine appends value as a line of the JSONL file at path and syncs it
+func appendLine(path string, value interface{}) error {
+	line, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return appendBytes(path, append(line, '\n'))
+}
+
+// appendBytes appends data to the file at path and syncs it, the file is cut
+// back if data isn't written as a whole
+func appendBytes(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY | os.O_CREATE | os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err == nil {
+		if _, err = file.Write(data); err == nil {
+			err = file.Sync()
+		} else {
+			file.Truncate(info.Size())
+		}
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func writeLine(file *os.File, value interface{}) error {
+	line, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,166 @@
+package mahjong
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openStore opens the file store of the directory and fails the test on an
+// error
+func openStore(t *testing.T, dir string) *FileStore {
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// countLines returns the number of the lines of the file
+func countLines(t *testing.T, path string) int {
+	lines := 0
+	if err := loadLines(path, func([]byte) error { lines++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+func TestJournal(t *testing.T) {
+	dir     := t.TempDir()
+	store   := openStore(t, dir)
+	expires := time.Now().Add(time.Hour).Round(0)
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("player-%d", i % 3)
+		if err := store.PutAccount(Account {Name: name, LastSeen: time.Unix(int64(i), 0)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.PutLoginSession(LoginSession {fmt.Sprint("token-", i), name, expires}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.DeleteLoginSession("token-4"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := openStore(t, dir)
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("player-%d", i)
+		account, err := reopened.Account(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last := int64(9 - (9 - i) % 3); account.LastSeen.Unix() != last {
+			t.Errorf("%s was last seen at %d, want %d", name, account.LastSeen.Unix(), last)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		_, err := reopened.LoginSession(fmt.Sprint("token-", i))
+		if deleted := i == 4; (err != nil) != deleted {
+			t.Errorf("session %d: %v", i, err)
+		}
+	}
+}
+
+func TestCompactJournal(t *testing.T) {
+	defer func(lines int) { compactLines = lines }(compactLines)
+	compactLines = 8
+	dir   := t.TempDir()
+	store := openStore(t, dir)
+	for i := 0; i < 7; i++ {
+		if err := store.PutAccount(Account {Name: "player", Iterations: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, accountsFile)
+	if lines := countLines(t, path); lines != 7 {
+		t.Fatalf("%d lines before the compaction, want 7", lines)
+	}
+	if err := store.PutAccount(Account {Name: "player", Iterations: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if lines := countLines(t, path); lines != 1 {
+		t.Errorf("%d lines after the compaction, want 1", lines)
+	}
+	if err := store.PutAccount(Account {Name: "player", Iterations: 8}); err != nil {
+		t.Fatal(err)
+	}
+	account, err := openStore(t, dir).Account("player")
+	if err != nil || account.Iterations != 8 {
+		t.Errorf("account %+v after the compaction, %v", account, err)
+	}
+
+	for i := 0; i < 8; i++ {
+		token := fmt.Sprint("token-", i % 2)
+		if err := store.PutLoginSession(LoginSession {token, "player", time.Now().Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lines := countLines(t, filepath.Join(dir, sessionsFile)); lines != 2 {
+		t.Errorf("%d session lines after the compaction, want 2", lines)
+	}
+}
+
+func TestLoadLinesTruncates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lines.jsonl")
+	if err := ioutil.WriteFile(path, []byte("{\"A\":1}\n{\"A\":2}\n{\"A\""), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	read := func(line []byte) error {
+		lines = append(lines, string(line))
+		return nil
+	}
+	if err := loadLines(path, read); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("%d lines are read, want 2", len(lines))
+	}
+	if err := appendLine(path, struct{ A int }{3}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"A\":1}\n{\"A\":2}\n{\"A\":3}\n"; string(data) != want {
+		t.Errorf("file is %q, want %q", data, want)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir  := t.TempDir()
+	path := filepath.Join(dir, versionFile)
+	var version struct {
+		Version int
+	}
+	openStore(t, dir)
+	if err := readJSON(path, &version); err != nil || version.Version != SchemaVersion {
+		t.Fatalf("a new store has version %d, want %d, %v", version.Version, SchemaVersion, err)
+	}
+
+	// a store of the version before the last migration
+	defer func(list []migration) { migrations = list }(migrations)
+	applied   := 0
+	migrations = append(migrations, migration {SchemaVersion, "test", func(string) error {
+		applied++
+		return nil
+	}})
+	if err := writeJSON(path, struct{ Version int }{SchemaVersion - 1}); err != nil {
+		t.Fatal(err)
+	}
+	openStore(t, dir)
+	openStore(t, dir)
+	if err := readJSON(path, &version); err != nil || version.Version != SchemaVersion || applied != 1 {
+		t.Errorf("version %d after %d runs of the migration, want %d after 1", version.Version, applied, SchemaVersion)
+	}
+
+	if err := writeJSON(path, struct{ Version int }{SchemaVersion + 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFileStore(dir); err == nil {
+		t.Error("a store newer than the server is opened")
+	}
+}
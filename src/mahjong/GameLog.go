@@ -0,0 +1,108 @@
+package mahjong
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"mahjong/engine"
+)
+
+// NewGameLog creates an append-only log of the game in the store
+func NewGameLog(store GameStore, header LogHeader) (*GameLog, error) {
+	path, err := store.CreateGameLog(header)
+	if err != nil {
+		return nil, err
+	}
+	return &GameLog {ID: header.GameID, Path: path, store: store}, nil
+}
+
+// GameLog represents the log of a game, Path is where the store keeps it
+type GameLog struct {
+	ID    string
+	Path  string
+	count int
+	store GameStore
+}
+
+// LogHeader is the first line of a game log, Stake and Balances are those
+// the hand starts with, Stake is 0 in the logs from before the stakes
+type LogHeader struct {
+	GameID   string
+	Match    string
+	Hand     int
+	Room     string
+	Seed     int64
+	Names    [4]string
+	Rules    engine.RuleSet
+	Dealer   int
+	Time     time.Time
+	Stake    int
+	Balances [4]int
+}
+
+// LogRecord is a line of game log after the header
+type LogRecord struct {
+	Index int
+	Time  time.Time
+	engine.Event
+}
+
+// LogResult is the last line of a game log
+type LogResult struct {
+	Time   time.Time
+	Result []engine.GameResult
+}
+
+// ReadGameLog reads the header, the records and the result of a game log
+// file, one json object per line, the result is nil if the game isn't over
+func ReadGameLog(path string) (LogHeader, []LogRecord, []engine.GameResult, error) {
+	var header  LogHeader
+	var records []LogRecord
+	var result  []engine.GameResult
+	file, err := os.Open(path)
+	if err != nil {
+		return header, records, result, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64 * 1024), 1024 * 1024)
+	if !scanner.Scan() {
+		return header, records, result, errors.New("empty game log")
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return header, records, result, err
+	}
+	for scanner.Scan() {
+		var record LogRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return header, records, result, err
+		}
+		if record.Type == "" {
+			var last LogResult
+			if err := json.Unmarshal(scanner.Bytes(), &last); err != nil {
+				return header, records, result, err
+			}
+			result = last.Result
+			continue
+		}
+		records = append(records, record)
+	}
+	return header, records, result, scanner.Err()
+}
+
+// Write appends the event to the game log
+func (gameLog *GameLog) Write(event engine.Event) error {
+	record := LogRecord {gameLog.count, time.Now(), event}
+	gameLog.count++
+	return gameLog.store.AppendGameLog(gameLog.ID, record)
+}
+
+// Finish appends the game result to the game log, and nothing can be
+// appended after it
+func (gameLog *GameLog) Finish(result []engine.GameResult) error {
+	return gameLog.store.FinishGameLog(gameLog.ID, LogResult {time.Now(), result})
+}
@@ -1,10 +1,13 @@
 package mahjong
 
 import (
-	"math"
-	"math/rand"
-	"sync"
+	"encoding/json"
+	"log"
 	"time"
+
+	"github.com/satori/go.uuid"
+
+	"mahjong/engine"
 )
 
 // Game State
@@ -16,337 +19,388 @@ const (
 	IdxTurn
 )
 
-// GameResult represents the result of mahjong
-type GameResult struct {
-	Hand     []string
-	Door     []string
-	Score    int
-	ScoreLog []ScoreRecord
-}
-
-// Run runs mahjong logic
+// Run runs a match of mahjong, the same players play every hand of it
 func (room *Room) Run() {
-	room.preproc()
-	currentIdx := 0
-	onlyThrow  := false
-	gameOver   := false
-	for !gameOver {
-		curPlayer := room.Players[currentIdx]
-		throwTile := NewTile(-1, 0)
-		act       := NewAction(COMMAND["NONE"], throwTile, 0)
-		room.State = IdxTurn + currentIdx
-
-		if onlyThrow {
-			throwTile = curPlayer.Throw(throwTile)
-			onlyThrow = false
-		} else {
-			drawTile := room.Deck.Draw()
-			room.BroadcastDraw(currentIdx, room.Deck.Count())
-			act       = curPlayer.Draw(drawTile)
-			throwTile = act.Tile
-		}
-
-		robGon, huIdx, gonIdx, ponIdx := room.checkAction(currentIdx, act, throwTile)
-		if robGon {
-			curPlayer.Fail(act.Command)
-			room.BroadcastRobGon(curPlayer.ID, act.Tile)
-		} else if act.Command != COMMAND["NONE"] {
-			curPlayer.Success(currentIdx, act.Command, act.Tile, act.Score)
-		}
-		curPlayer.JustGon = false
-
-		currentIdx, onlyThrow = room.doAction(currentIdx, throwTile, huIdx, gonIdx, ponIdx)
-		if currentIdx == curPlayer.ID && huIdx == -1 && (act.Command & COMMAND["ONGON"]) == 0 && (act.Command & COMMAND["PONGON"]) == 0 {
-			curPlayer.DiscardTiles.Add(throwTile)
-			currentIdx = (currentIdx + 1) % 4
-		}
-		if room.Deck.IsEmpty() {
-			gameOver = true
+	room.Session = NewSession(room.Seed, room.Rules.Hands)
+	for !room.Session.IsOver() {
+		room.preproc()
+		for !room.Game.IsOver() {
+			room.waitMoves()
 		}
+		room.end()
 	}
-	room.end()
+	room.endMatch()
 }
 
 func (room *Room) preproc() {
-	time.Sleep(2 * time.Second)
+	room.pause(2 * time.Second)
 	room.init()
-	time.Sleep(3 * time.Second)
-	room.changeTile()
-	time.Sleep(5 * time.Second)
+	room.pause(3 * time.Second)
+	if room.Rules.ChangeTile {
+		room.changeTile()
+		room.pause(5 * time.Second)
+	}
 	room.chooseLack()
-	time.Sleep(3 * time.Second)
+	room.pause(3 * time.Second)
+	room.start()
 }
 
-func (room *Room) init() {
-	room.Deck    = NewSuitSet(true)
-	room.HuTiles = NewSuitSet(false)
+// Pauses turns on the pauses between the stages of a hand, which give the
+// clients time to show them
+var Pauses = true
 
-	for _, player := range room.Players {
-		player.Init()
-		for j := 0; j < 13; j++ {
-			result := room.Deck.Draw()
-			player.Hand.Add(result)
-		}
-		player.Socket().Emit("dealTile", player.Hand.ToStringArray())
+func (room *Room) pause(duration time.Duration) {
+	if Pauses && room.HasHuman() {
+		time.Sleep(duration)
 	}
-	room.State = DealTile
 }
 
-func (room *Room) changeTile() {
-	var waitGroup sync.WaitGroup
-	waitGroup.Add(4)
-	for i := 0; i < 4; i++ {
-		go func(id int) {
-			room.ChangedTiles[id] = room.Players[id].ChangeTiles()
-			waitGroup.Done()
-		}(i)
+func (room *Room) init() {
+	var names [4]string
+	for i, player := range room.Players {
+		player.Init()
+		names[i] = player.Name()
 	}
-	waitGroup.Wait()
+	seed     := room.Session.NextSeed()
+	game     := engine.NewGameState(names, seed, room.Rules, room.Session.Dealer)
+	balances := room.stake()
+	game.SetStake(room.Tier.Stake, balances)
+	room.setGame(game, room.State)
+	room.openLog(names, seed, balances)
+	room.BroadcastRules()
+	room.BroadcastHandStart(room.Session.Hand, room.Session.Hands, room.Session.Dealer)
 
-	rand   := rand.Int31n(3)
-	offset := [3]int{1, 2, 3}
-	var tmp [4][]Tile
-	for i := 0; i < 4; i++ {
-		tmp[(i + offset[rand]) % 4] = room.ChangedTiles[i]
-	}
+	game, events := engine.Deal(room.Game)
+	room.update(game, events)
+	room.setGame(room.Game, DealTile)
+}
 
-	for i := 0; i < 4; i++ {
-		room.Players[i].Hand.Add(tmp[i])
-		t := ArrayToSuitSet(tmp[i])
-		room.Players[i].Socket().Emit("afterChange", t.ToStringArray(), rand)
+// stake sets the accounts of the seats and returns their balances for the
+// stake of the tier, a bot and every seat of a practice table play for the
+// house without a limit
+func (room *Room) stake() [4]int {
+	var balances [4]int
+	for i, player := range room.Players {
+		room.accounts[i] = HouseAccount
+		balances[i]      = -1
+		if !player.IsHuman() || room.Tier.Name == PracticeTier {
+			continue
+		}
+		room.accounts[i] = player.Name()
+		if room.Tier.Name == "" {
+			continue
+		}
+		balance, err := Storage.Balance(room.accounts[i])
+		if err != nil {
+			log.Println("balance error:", err)
+			continue
+		}
+		balances[i] = engine.IF(balance > 0, balance, 0).(int)
 	}
-	room.State = ChangeTile
+	return balances
 }
 
-func (room *Room) chooseLack() {
-	var waitGroup sync.WaitGroup
-	waitGroup.Add(4)
-	for i := 0; i < 4; i++ {
-		go func(id int) {
-			room.ChoosedLack[id] = room.Players[id].ChooseLack()
-			waitGroup.Done()
-		}(i)
+func (room *Room) openLog(names [4]string, seed int64, balances [4]int) {
+	room.GameID = uuid.Must(uuid.NewV4()).String()
+	log.Println("room", room.Name, "match", room.Session.ID, "hand", room.Session.Hand, "game", room.GameID, "seed", seed)
+
+	room.Header = LogHeader {room.GameID, room.Session.ID, room.Session.Hand, room.Name, seed, names, room.Rules, room.Session.Dealer, time.Now(), room.Game.Stake, balances}
+	gameLog, err := NewGameLog(Storage, room.Header)
+	if err != nil {
+		log.Println("game log error:", err)
+		return
 	}
-	waitGroup.Wait()
-	room.BroadcastLack()
-	room.State = ChooseLack
+	room.Log = gameLog
 }
 
-func (room *Room) checkAction(currentIdx int, playerAct Action, throwTile Tile) (bool, int, int, int) {
-	ponIdx, gonIdx, huIdx := -1, -1, -1
-	fail                  := false
+func (room *Room) changeTile() {
+	room.waitMoves()
+	room.setGame(room.Game, ChangeTile)
+}
 
-	if (playerAct.Command & COMMAND["PONGON"]) != 0 {
-		fail = room.checkRobGon(currentIdx, playerAct.Tile, &huIdx)
-	} else if (playerAct.Command & COMMAND["ZIMO"]) == 0 && (playerAct.Command & COMMAND["ONGON"]) == 0 {
-		room.checkOthers(currentIdx, throwTile, &huIdx, &gonIdx, &ponIdx)
-	}
+func (room *Room) chooseLack() {
+	room.waitMoves()
+	room.setGame(room.Game, ChooseLack)
+	room.BroadcastLack()
+}
 
-	return fail, huIdx, gonIdx, ponIdx
+func (room *Room) start() {
+	game, events := engine.Start(room.Game)
+	room.update(game, events)
 }
 
-func (room *Room) checkRobGon(currentIdx int, gonTile Tile, huIdx *int) bool {
-	var waitGroup  sync.WaitGroup
-	var playersAct [3]Action
-	waitGroup.Add(3)
-	for i := 1; i < 4; i++ {
-		id  := (i + currentIdx) % 4
-		tai := 0
-		if room.Players[id].CheckHu(gonTile, &tai) {
-			actionSet := NewActionSet()
-			actionSet[COMMAND["HU"]] = append(actionSet[COMMAND["HU"]], gonTile)
-			go func(i int) {
-				playersAct[i - 1] = room.Players[i].Command(actionSet, COMMAND["HU"])
-				waitGroup.Done()
-			}(id)
-		} else {
-			waitGroup.Done()
+func (room *Room) waitMoves() {
+	game  := room.Game
+	seats := game.WaitingSeats()
+	c     := make(chan engine.Move, len(seats))
+	for _, id := range seats {
+		go func(id int) {
+			c <- room.decide(game, id)
+		}(id)
+	}
+	for range seats {
+		move := <-c
+		game, events, err := engine.Apply(room.Game, move)
+		if err != nil {
+			log.Println("apply error:", err)
+			continue
 		}
+		room.update(game, events)
 	}
-	waitGroup.Wait()
-	return room.robGon(currentIdx, playersAct, gonTile, huIdx)
+	room.leave()
 }
 
-func (room *Room) robGon(currentIdx int, playersAct [3]Action, huTile Tile, huIdx *int) bool {
-	fail      := false
-	curPlayer := room.Players[currentIdx]
-	for i := 1; i < 4; i++ {
-		id        := (i + currentIdx) % 4
-		playerAct := playersAct[i - 1]
-		if (playerAct.Command & COMMAND["HU"]) != 0 {
-			tai := 0
-			room.Players[id].CheckHu(huTile, &tai)
-			score := room.Players[id].Hu(huTile, tai, COMMAND["HU"], true, !fail, currentIdx)
-			room.Players[id].Success(currentIdx, COMMAND["HU"], huTile, score)
-			if !fail {
-				curPlayer.Door.Sub(huTile)
-				curPlayer.VisiableDoor.Sub(huTile)
+func (room *Room) decide(game engine.GameState, id int) engine.Move {
+	agent  := room.Players[id].Agent
+	legal  := engine.LegalActions(game, id)
+
+	switch legal[0].Type {
+	case engine.MoveChange:
+		move := engine.NewChangeMove(id, agent.ChangeTiles(game, id, legal[0].Tiles))
+		return room.accept(game, move, legal[0])
+	case engine.MoveLack:
+		move := engine.NewLackMove(id, agent.ChooseLack(game, id))
+		return room.accept(game, move, legal[0])
+	}
+
+	actionSet, command := engine.ToActionSet(legal)
+	if command != engine.COMMAND["NONE"] {
+		act  := agent.Command(game, id, actionSet, command)
+		move := engine.NewCommandMove(id, act.Command, act.Tile)
+		if act.Command != engine.COMMAND["NONE"] || game.Step != engine.StepDraw {
+			if game.Step != engine.StepDraw {
+				return room.accept(game, move, legal[0])
 			}
-			*huIdx = id
-			fail   = true
+			err := engine.Check(game, move)
+			if err == nil {
+				return move
+			}
+			room.reject(move, err)
 		}
 	}
-	return fail
-}
 
-func (room *Room) checkOthers(currentIdx int, throwTile Tile, huIdx *int, gonIdx *int, ponIdx *int) {
-	playerAct := NewAction(COMMAND["NONE"], throwTile, 0)
-	var playersAct [3]Action
-	var waitGroup  sync.WaitGroup
-	waitGroup.Add(3)
-	for i := 1; i < 4; i++ {
-		otherPlayer := room.Players[(i + currentIdx) % 4]
-		tai         := 0
-
-		otherPlayer.CheckHu(throwTile, &tai)
-		actionSet, command := otherPlayer.getAvaliableAction(false, throwTile, tai)
-		if command == COMMAND["NONE"] {
-			playerAct.Command = COMMAND["NONE"]
-			playersAct[i - 1] = playerAct
-			waitGroup.Done()
-		} else if otherPlayer.IsHu {
-			if (command & COMMAND["HU"]) != 0 {
-				playerAct.Command = COMMAND["HU"]
-			} else if (command & COMMAND["GON"]) != 0 {
-				playerAct.Command = COMMAND["GON"]
+	defaultMove := engine.NewThrowMove(id, game.DrawTile)
+	if !engine.IsLegal(game, defaultMove) {
+		for _, move := range legal {
+			if move.Type == engine.MoveThrow {
+				defaultMove = move
+				break
 			}
-			playerAct.Tile    = throwTile
-			playersAct[i - 1] = playerAct
-			waitGroup.Done()
-		} else {
-			go func(i int) {
-				playersAct[i - 1] = otherPlayer.Command(actionSet, command)
-				waitGroup.Done()
-			}(i)
 		}
 	}
-	waitGroup.Wait()
-	for i := 1; i < 4; i++ {
-		playerID    := (i + currentIdx) % 4
-		otherPlayer := room.Players[playerID]
-		tai         := 0
-		playerAct    = playersAct[i - 1]
-		otherPlayer.CheckHu(throwTile, &tai)
-
-		if (playerAct.Command & COMMAND["HU"]) != 0 {
-			score := otherPlayer.Hu(playerAct.Tile, tai, COMMAND["HU"], false, *huIdx == -1, currentIdx)
-			*huIdx = playerID
-			otherPlayer.Success(currentIdx, COMMAND["HU"], playerAct.Tile, score)
-		} else if (playerAct.Command & COMMAND["GON"]) != 0 {
-			if *huIdx == -1 && *gonIdx == -1 {
-				*gonIdx = playerID
-			} else {
-				otherPlayer.Fail(playerAct.Command)
-			}
-		} else if (playerAct.Command & COMMAND["PON"]) != 0 {
-			if *huIdx == -1 && *gonIdx == -1 && *ponIdx == -1 {
-				*ponIdx = playerID
-			} else {
-				otherPlayer.Fail(playerAct.Command)
+	move := engine.NewThrowMove(id, agent.Throw(game, id, defaultMove.Tile))
+	return room.accept(game, move, defaultMove)
+}
+
+// accept returns the move if it is legal, else rejects it and returns the fallback
+func (room *Room) accept(game engine.GameState, move engine.Move, fallback engine.Move) engine.Move {
+	if err := engine.Check(game, move); err != nil {
+		room.reject(move, err)
+		return fallback
+	}
+	return move
+}
+
+// reject logs the rejected move and tells the player why
+func (room *Room) reject(move engine.Move, err error) {
+	player := room.Players[move.Seat]
+	data, _ := json.Marshal(move)
+	log.Println("reject move:", room.Name, room.GameID, move.Seat, player.Name(), string(data), err)
+	player.Emit("invalidMove", err)
+}
+
+// setGame sets the game state and the state of the room under the mutex
+func (room *Room) setGame(game engine.GameState, state int) {
+	room.mutex.Lock()
+	room.Game  = game
+	room.State = state
+	room.mutex.Unlock()
+}
+
+func (room *Room) update(game engine.GameState, events []engine.Event) {
+	state := room.State
+	if game.Phase == engine.Playing {
+		state = IdxTurn + game.Current
+	}
+	room.setGame(game, state)
+	for _, event := range events {
+		if room.Log != nil {
+			if err := room.Log.Write(event); err != nil {
+				log.Println("game log error:", err)
 			}
 		}
+		room.dispatch(event)
 	}
 }
 
-func (room *Room) doAction(currentIdx int, throwTile Tile, huIdx int, gonIdx int, ponIdx int) (int, bool) {
-	onlyThrow := false
-
-	if huIdx != -1 {
-		currentIdx = (huIdx + 1) % 4
-		if gonIdx != -1 {
-			room.Players[gonIdx].Fail(COMMAND["GON"])
+func (room *Room) dispatch(event engine.Event) {
+	switch event.Type {
+	case engine.EventWall:
+		room.BroadcastWall(event.Dice, event.Value, len(event.Tiles))
+	case engine.EventDeal:
+		room.Players[event.Seat].Emit("dealTile", engine.ArrayToSuitSet(event.Tiles).ToStringArray())
+	case engine.EventChange:
+		room.BroadcastChange(event.Seat)
+	case engine.EventAfterChange:
+		room.Players[event.Seat].Emit("afterChange", engine.ArrayToSuitSet(event.Tiles).ToStringArray(), event.Value)
+	case engine.EventDraw:
+		room.BroadcastDraw(event.Seat, uint(event.Value), event.Command == engine.COMMAND["GON"])
+		room.Players[event.Seat].Emit("draw", event.Tile.ToString())
+	case engine.EventThrow:
+		room.BroadcastThrow(event.Seat, event.Tile)
+	case engine.EventCommand:
+		room.Players[event.Seat].Emit("success", event.From, event.Command, event.Tile.ToString(), event.Score)
+		room.BroadcastCommand(event.From, event.Seat, event.Command, event.Tile, event.Score)
+	case engine.EventFail:
+		room.Players[event.Seat].Emit("fail", event.Command)
+	case engine.EventRobGon:
+		room.BroadcastRobGon(event.Seat, event.Tile)
+	case engine.EventScore:
+		if event.Fans != nil && event.Score > 0 {
+			room.BroadcastFan(event.Seat, event.Fans)
 		}
-		if ponIdx != -1 {
-			room.Players[ponIdx].Fail(COMMAND["PON"])
+	case engine.EventBankrupt:
+		room.BroadcastBankrupt(event.Seat)
+		if BankruptcyRule == BankruptcyLeave && room.Players[event.Seat].IsHuman() {
+			room.leaving = append(room.leaving, event.Seat)
 		}
-	} else if gonIdx != -1 {
-		score := room.Players[gonIdx].Gon(throwTile, COMMAND["GON"], currentIdx)
-		room.Players[gonIdx].Success(currentIdx, COMMAND["GON"], throwTile, score)
-		currentIdx = gonIdx
-	} else if ponIdx != -1 {
-		room.Players[ponIdx].Success(currentIdx, COMMAND["PON"], throwTile, 0)
-		room.Players[ponIdx].Pon(throwTile)
-		currentIdx = ponIdx
-		onlyThrow  = true
-	}
-	return currentIdx, onlyThrow
+	}
 }
 
-func (room *Room) end() {
-	if room.huUnder2() {
-		room.lackPenalty()
-		room.noTingPenalty()
-		room.returnMoney()
+// leave lets the bankrupt players leave the room after the moves in
+// progress are made, a bot plays the rest of the hand for the player and the
+// seat for the house from the next hand
+func (room *Room) leave() {
+	for _, id := range room.leaving {
+		player := room.Players[id]
+		if socket := player.Socket(); socket != nil {
+			socket.Emit("bankrupt", room.GameID)
+			socket.Leave(room.Name)
+		}
+		PlayerList.Update(player.UUID, func(info *IPlayer) {
+			info.Room  = ""
+			info.Index = -1
+			info.State = WAITING
+		})
+		room.mutex.Lock()
+		room.Players[id] = NewAgentPlayer(room, id, player.Name(), NewBotAgent(BotDelay))
+		room.mutex.Unlock()
+		log.Println("room", room.Name, "game", room.GameID, "bankrupt", id, player.Name())
 	}
+	room.leaving = nil
+}
 
-	var data []GameResult
-	for _, player := range room.Players {
-		data = append(data, GameResult {player.Hand.ToStringArray(), player.Door.ToStringArray(), player.Credit, player.ScoreLog})
+func (room *Room) end() {
+	path   := ""
+	result := room.Game.Result()
+	if room.Log != nil {
+		path = room.Log.Path
+		if err := room.Log.Finish(result); err != nil {
+			log.Println("game log error:", err)
+		}
+		room.Log = nil
 	}
-	room.BroadcastEnd(data)
-	players := FindPlayerListInRoom(room.Name)
-	for _, player := range players {
-		player.State = WAITING
+	record := NewHandRecord(room.Header, result, time.Now())
+	if err := Storage.PutHandRecord(record); err != nil {
+		log.Println("hand record error:", err)
 	}
+	room.BroadcastEnd(result, room.GameID, path)
+	summary := room.Session.Record(room.GameID, room.Game)
+	room.BroadcastHandEnd(summary)
+	room.settle()
+	room.rate(summary)
+	room.rank(record)
 }
 
-func (room *Room) huUnder2() bool {
-	count := 0
-	for i := 0; i < 4; i++ {
-		if room.Players[i].IsHu {
-			count++
-		} else {
-			room.Players[i].IsTing = room.Players[i].CheckTing(&room.Players[i].MaxTai)
+// settle posts the payments of the hand to the ledger and sends the balances
+// to the players, the bots pay and are paid by the house. A player who left
+// the room bankrupt is settled for the hand too
+func (room *Room) settle() {
+	if !room.hasAccount() {
+		return
+	}
+	settlement, err := NewSettlement(room.GameID, room.Game, room.accounts)
+	if err == nil {
+		err = Storage.PostSettlement(settlement)
+	}
+	if err != nil {
+		log.Println("settlement error:", err)
+		return
+	}
+	for _, account := range room.accounts {
+		if player, ok := PlayerList.GetByName(account); ok && account != HouseAccount && player.Socket != nil {
+			balance, _ := Storage.Balance(account)
+			(*player.Socket).Emit("balance", balance)
 		}
 	}
-	return count <= 2
 }
 
-func (room *Room) lackPenalty() {
-	score := 16
-	for i := 0; i < 4; i++ {
-		if room.Players[i].Hand.IsContainColor(room.Players[i].Lack) {
-			for j := 0; j < 4; j++ {
-				if room.Players[j].Hand[room.Players[j].Lack].Count() == 0 && i != j {
-					room.Players[i].IsPenalize = true
-					room.Players[i].Credit    -= score
-					room.Players[j].Credit    += score
-					room.Players[i].ScoreLog   = append(room.Players[i].ScoreLog, NewScoreRecord("花豬", "to", room.Players[j].Name(), "", -score))
-					room.Players[j].ScoreLog   = append(room.Players[j].ScoreLog, NewScoreRecord("花豬", "from", room.Players[i].Name(), "", score))
-				}
-			}
+// rate updates the ratings of the human players of the hand by its credits
+func (room *Room) rate(summary HandSummary) {
+	if Ratings == nil || !room.hasAccount() {
+		return
+	}
+	var names [4]string
+	var rated [4]bool
+	for i, player := range room.Players {
+		names[i] = player.Name()
+		rated[i] = room.accounts[i] != HouseAccount
+	}
+	changes, err := Ratings.Update(summary.GameID, names, rated, summary.Scores)
+	if err != nil {
+		log.Println("rating error:", err)
+	}
+	for i, account := range room.accounts {
+		if player, ok := PlayerList.GetByName(account); ok && rated[i] && player.Socket != nil {
+			(*player.Socket).Emit("rating", changes[i])
 		}
 	}
 }
 
-func (room *Room) noTingPenalty() {
-	for i := 0; i < 4; i++ {
-		if !room.Players[i].IsTing && !room.Players[i].IsHu && !room.Players[i].IsPenalize {
-			for j := 0; j < 4; j++ {
-				if room.Players[j].IsTing && i != j {
-					score := int(math.Pow(2, float64(room.Players[j].MaxTai - 1)))
-					room.Players[i].Credit -= score
-					room.Players[j].Credit += score
-					room.Players[i].ScoreLog = append(room.Players[i].ScoreLog, NewScoreRecord("大叫", "to", room.Players[j].Name(), "", -score))
-					room.Players[j].ScoreLog = append(room.Players[j].ScoreLog, NewScoreRecord("大叫", "from", room.Players[i].Name(), "", score))
-				}
-			}
+// rank adds the hand to the leaderboards of the human players, with their
+// ratings after the hand
+func (room *Room) rank(record HandRecord) {
+	if Leaderboards == nil || !room.hasAccount() {
+		return
+	}
+	var counted [4]bool
+	var ratings [4]float64
+	for i, account := range room.accounts {
+		counted[i] = account != HouseAccount
+		ratings[i] = InitialRating
+		if Ratings != nil {
+			ratings[i] = Ratings.Get(record.Names[i]).Rating
 		}
 	}
+	if err := Leaderboards.Record(record, counted, ratings); err != nil {
+		log.Println("leaderboard error:", err)
+	}
 }
 
-func (room *Room) returnMoney() {
-	for i := 0; i < 4; i++ {
-		if !room.Players[i].IsTing && !room.Players[i].IsHu {
-			for j := 0; j < 4; j++ {
-				score := room.Players[i].GonRecord[j]
-				if score != 0 {
-					room.Players[i].Credit -= score
-					room.Players[j].Credit += score
-					room.Players[i].ScoreLog = append(room.Players[i].ScoreLog, NewScoreRecord("退稅", "to", room.Players[j].Name(), "", -score))
-					room.Players[j].ScoreLog = append(room.Players[j].ScoreLog, NewScoreRecord("退稅", "from", room.Players[i].Name(), "", score))
-				}
-			}
+// hasAccount returns if a human played the hand
+func (room *Room) hasAccount() bool {
+	for _, account := range room.accounts {
+		if account != HouseAccount {
+			return true
 		}
 	}
+	return false
+}
+
+func (room *Room) endMatch() {
+	var names [4]string
+	for i, player := range room.Players {
+		names[i] = player.Name()
+	}
+	result := room.Session.Result(names)
+	log.Println("room", room.Name, "match", result.MatchID, "totals", result.Totals)
+	if err := Storage.PutMatchResult(result); err != nil {
+		log.Println("match result error:", err)
+	}
+	room.BroadcastMatchEnd(result)
+	for _, player := range PlayerList.InRoom(room.Name) {
+		PlayerList.Update(player.UUID, func(player *IPlayer) {
+			player.State = WAITING
+		})
+	}
 }
@@ -0,0 +1,93 @@
+package mahjong
+
+import (
+	"os"
+	"testing"
+
+	"mahjong/engine"
+)
+
+func TestMain(m *testing.M) {
+	engine.InitHuTable("")
+	Storage = NewMemoryStore()
+	os.Exit(m.Run())
+}
+
+// answerFirst answers every prompt of the agent by its first legal move
+// until the prompts are closed
+func answerFirst(agent *ChannelAgent) {
+	for prompt := range agent.Prompt {
+		prompt.Reply <- prompt.Legal[0]
+	}
+}
+
+// playMatch plays a match of the rules in process, two seats are bots and
+// two are channel agents, it fails the test if the match doesn't end
+func playMatch(t *testing.T, name string, rules engine.RuleSet) *Room {
+	t.Helper()
+	room      := NewRoom(name)
+	room.Rules = rules
+	var agents []*ChannelAgent
+	for i := 0; i < 4; i++ {
+		if i % 2 == 0 {
+			room.AddAgent(BotName(i + 1), NewBotAgent(0))
+			continue
+		}
+		agent := NewChannelAgent()
+		agents = append(agents, agent)
+		go answerFirst(agent)
+		room.AddAgent(BotName(i + 1), agent)
+	}
+	room.Run()
+	for _, agent := range agents {
+		close(agent.Prompt)
+	}
+	if !room.Session.IsOver() || !room.Game.IsOver() {
+		t.Fatalf("%s: the match isn't over after hand %d of %d", name, room.Session.Hand, room.Session.Hands)
+	}
+	return room
+}
+
+func TestPlayHand(t *testing.T) {
+	for name, rules := range engine.RulePresets {
+		rules.Hands = 1
+		room := playMatch(t, "test-" + name, rules)
+		sum  := 0
+		for _, result := range room.Game.Result() {
+			sum += result.Score
+		}
+		if sum != 0 {
+			t.Errorf("%s: credits sum to %d", name, sum)
+		}
+		replayer, err := NewReplayer(Storage, room.GameID)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := replayer.Verify(); err != nil {
+			t.Errorf("%s: replay: %v", name, err)
+		}
+		for i, seat := range replayer.Seek(replayer.Len()).Seats {
+			if seat.Hand != room.Game.Seats[i].Hand || seat.Door != room.Game.Seats[i].Door {
+				t.Errorf("%s: replayed seat %d differs", name, i)
+			}
+		}
+	}
+}
+
+func TestPlayMatch(t *testing.T) {
+	room := playMatch(t, "test-match", engine.RulePresets["simple"])
+	result, err := Storage.MatchResult(room.Session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Hands) != room.Rules.Hands {
+		t.Errorf("%d hands are played, want %d", len(result.Hands), room.Rules.Hands)
+	}
+	sum := 0
+	for _, total := range result.Totals {
+		sum += total
+	}
+	if sum != 0 {
+		t.Errorf("totals sum to %d", sum)
+	}
+}
@@ -1,16 +1,24 @@
 package mahjong
 
 import (
-	"math/rand"
-	"time"
+	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/googollee/go-socket.io";
 	"github.com/satori/go.uuid";
+
+	"mahjong/engine"
 )
 
 var game *GameManager
-// NewGameManager creates a new gameManager
+
+// HuTablePath is where the hu table is cached
+var HuTablePath = "hutable.bin"
+
+// NewGameManager creates a new gameManager, the hu table is loaded
+// before any match is made
 func NewGameManager() (bool) {
 	server, err := socketio.NewServer([]string{"websocket"})
 	if err != nil {
@@ -18,17 +26,89 @@ func NewGameManager() (bool) {
 		return true
 	}
 
-	go InitHuTable()
+	if err := engine.InitHuTable(HuTablePath); err != nil {
+		log.Println("hu table is not cached:", err)
+	}
+	store, err := OpenFileStore(DataDir)
+	if err != nil {
+		log.Fatal("store is not opened: ", err)
+		return true
+	}
+	Storage = store
+	ratings, err := LoadRatings(Storage)
+	if err != nil {
+		log.Println("ratings are not loaded:", err)
+	}
+	Ratings = ratings
+	leaderboards, err := LoadLeaderboards(Storage)
+	if err != nil {
+		log.Println("leaderboards are not loaded:", err)
+	}
+	Leaderboards = leaderboards
+	tokens, err := LoadTokenSigner(SecretPath, Storage)
+	if err != nil {
+		log.Fatal("token key is not loaded: ", err)
+		return true
+	}
+	Tokens = tokens
 
-	rooms := make(map[string]*Room)
-	game   = &GameManager {rooms, server}
+	queue := NewMatchQueue(4, BotWaitingTime, QueueTimeout, CreateRoom)
+	game   = &GameManager {rooms: make(map[string]*Room), Server: server, Queue: queue}
 	return false
 }
 
-// GameManager represents a gameManager
+// CloseGameManager stores the leaderboards kept unsaved and closes the store
+func CloseGameManager() {
+	if Leaderboards != nil {
+		if err := Leaderboards.Flush(); err != nil {
+			log.Println("leaderboards are not saved:", err)
+		}
+	}
+	if err := Storage.Close(); err != nil {
+		log.Println("store is not closed:", err)
+	}
+}
+
+// GameManager represents a gameManager, the rooms are guarded by mutex
 type GameManager struct {
-	Rooms  map[string]*Room
+	mutex  sync.RWMutex
+	rooms  map[string]*Room
 	Server *socketio.Server
+	Queue  *MatchQueue
+}
+
+// GetQueue returns the matchmaking queue
+func GetQueue() *MatchQueue {
+	return game.Queue
+}
+
+// Room returns the room of name, nil if there is none
+func (manager *GameManager) Room(name string) *Room {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+	return manager.rooms[name]
+}
+
+// NewRoom creates a room with a new name
+func (manager *GameManager) NewRoom() *Room {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	var name string
+	for {
+		name = uuid.Must(uuid.NewV4()).String()
+		if manager.rooms[name] == nil {
+			break
+		}
+	}
+	manager.rooms[name] = NewRoom(name)
+	return manager.rooms[name]
+}
+
+// RemoveRoom removes the room of name
+func (manager *GameManager) RemoveRoom(name string) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	delete(manager.rooms, name)
 }
 
 // GetServer returns socket io server
@@ -36,96 +116,104 @@ func GetServer() *socketio.Server {
 	return game.Server
 }
 
-// Login handles player's login
-func Login(name string, socket *socketio.Socket) (string, bool) {
-	uuid, err := AddPlayer(name)
+// Login handles player's login of the account name, the player who is
+// already online is taken over by the new socket
+func Login(name string, socket *socketio.Socket, prompts *Dispatcher) (string, bool) {
+	if player, ok := PlayerList.GetByName(name); ok {
+		PlayerList.Update(player.UUID, func(player *IPlayer) {
+			player.Socket  = socket
+			player.Prompts = prompts
+		})
+		return player.UUID, false
+	}
+	uuid, err := PlayerList.Add(name)
 	if err {
 		return "", true
 	}
-	index := FindPlayerByUUID(uuid)
-	PlayerList[index].Socket = socket
-	PlayerList[index].State  = WAITING
+	PlayerList.Update(uuid, func(player *IPlayer) {
+		player.Socket  = socket
+		player.Prompts = prompts
+		player.State   = WAITING
+	})
+	game.Queue.Join(uuid, "", "")
 
 	return uuid, false
 }
 
 // Logout handles player's logout
 func Logout(socket socketio.Socket) {
-	index := FindPlayerBySocket(socket)
-	if index >= 0 && index < len(PlayerList) {
-		if PlayerList[index].State == WAITING {
-			RemovePlayer(index)
-		} 
-		// else if PlayerList[index].State == MATCHED {
-		// 	game.RemoveRoom(PlayerList[index].Room)
-		// 	RemovePlayer(index)
-		// }
+	player, ok := PlayerList.GetBySocket(socket)
+	if ok && player.State == QUEUED {
+		game.Queue.Cancel(player.UUID)
+		player.State = WAITING
 	}
-}
-
-// Exec executes the whole game
-func Exec() {
-	for {
-		if WaitingNum() >= 4 {
-			go CreateRoom()
-			time.Sleep(2 * time.Second)
-		}
-		time.Sleep(5 * time.Second)
+	if ok && player.State == WAITING {
+		PlayerList.Remove(player.UUID)
 	}
 }
 
-// WaitingNum returns the number of player which state are waiting
-func WaitingNum() int {
-	return len(FindPlayerListIsSameState(WAITING))
+// DefaultRules are the rules of a new room
+var DefaultRules = engine.RulePresets[engine.DefaultRule]
+
+// BotWaitingTime is how long the queued players wait before bots fill
+// the empty seats, 0 disables the bots
+var BotWaitingTime = 30 * time.Second
+
+// QueueTimeout is how long a player stays in the queue, 0 keeps the player
+// until a table is formed
+var QueueTimeout = 10 * time.Minute
+
+// BotName returns the name of the n th bot of a room, it starts with '#' so
+// no account can take it
+func BotName(n int) string {
+	return fmt.Sprintf("#bot-%d", n)
 }
 
-// CreateRoom creates a new room and add player to that room
-func CreateRoom() {
-	var roomName string
-	for {
-		roomName = uuid.Must(uuid.NewV4()).String()
-		if game.Rooms[roomName] == nil {
-			break
+// CreateRoom creates a new room of the rules and the tier for the matched
+// players, fills the other seats with bots and runs it when the players are
+// ready. If they aren't ready in time, the ready ones are queued again
+func CreateRoom(matchPlayer []string, rules string, tier string) {
+	room      := game.NewRoom()
+	room.IO    = game.Server
+	room.Rules = engine.RulePresets[rules]
+	room.Tier  = Tiers[tier]
+	for i := len(matchPlayer); i < 4; i++ {
+		room.AddAgent(BotName(i - len(matchPlayer) + 1), NewBotAgent(BotDelay))
+	}
+	room.AddPlayer(matchPlayer)
+	started := room.WaitToStart()
+	var ready []string
+	for _, player := range room.Players {
+		if player.IsHuman() {
+			ready = append(ready, player.UUID)
+		}
+	}
+	RemoveRoom(room.Name)
+	if !started {
+		for _, uuid := range ready {
+			game.Queue.Join(uuid, rules, tier)
 		}
 	}
-	matchPlayer := Match()
-	game.Rooms[roomName]    = NewRoom(roomName)
-	game.Rooms[roomName].IO = game.Server
-	game.Rooms[roomName].AddPlayer(matchPlayer)
-	game.Rooms[roomName].WaitToStart()
-	RemoveRoom(roomName)
 }
 
-// RemoveRoom removes a room by room name
+// RemoveRoom removes a room by room name, the players of a room which
+// didn't start and the players back to waiting leave the room, the others
+// are removed
 func RemoveRoom(name string) {
-	if game.Rooms[name].Waiting {
-		game.Rooms[name].StopWaiting()
-	}
-	playerList := FindPlayerListInRoom(name)
-	for _, player := range playerList {
-		var index int
-		index = FindPlayerByUUID(player.UUID)
-		if game.Rooms[name].Waiting {
-			PlayerList[index].State = WAITING
+	room := game.Room(name)
+	if room == nil {
+		return
+	}
+	started := room.GetState() != BeforeStart
+	for _, player := range PlayerList.InRoom(name) {
+		if !started || player.State == WAITING {
+			PlayerList.Update(player.UUID, func(player *IPlayer) {
+				player.Room  = ""
+				player.State = WAITING
+			})
 		} else {
-			RemovePlayer(index)
+			PlayerList.Remove(player.UUID)
 		}
 	}
-	delete(game.Rooms, name)
+	game.RemoveRoom(name)
 }
-
-// Match matchs 4 player into a room
-func Match() []string {
-	waitingList := FindPlayerListIsSameState(WAITING)
-	var sample []string
-	for i := 0; i < 4; i++ {
-		index      := rand.Int31n(int32(len(waitingList)))
-		sample      = append(sample, waitingList[index].UUID)
-		waitingList = append(waitingList[: index], waitingList[index + 1: ]...)
-	}
-	for _, uuid := range sample {
-		index := FindPlayerByUUID(uuid)
-		PlayerList[index].State = MATCHED
-	}
-	return sample
-}
\ No newline at end of file
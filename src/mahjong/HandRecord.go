@@ -0,0 +1,143 @@
+package mahjong
+
+import (
+	"time"
+
+	"mahjong/engine"
+)
+
+// Pages of hand records
+const (
+	defaultHandPage = 20
+	maxHandPage     = 100
+)
+
+// NewHandRecord creates the record of a finished hand from the header of its
+// game log and its result
+func NewHandRecord(header LogHeader, result []engine.GameResult, end time.Time) HandRecord {
+	return HandRecord {header.GameID, header.Match, header.Hand, header.Room, header.Names, header.Rules, header.Seed, header.Dealer, header.Time, end, result}
+}
+
+// HandRecord represents a finished hand, Names are in the seat order and
+// Result has the final hand, door, credits and score log of every seat
+type HandRecord struct {
+	GameID  string
+	MatchID string
+	Hand    int
+	Room    string
+	Names   [4]string
+	Rules   engine.RuleSet
+	Seed    int64
+	Dealer  int
+	Start   time.Time
+	End     time.Time
+	Result  []engine.GameResult
+}
+
+// HandQuery selects the hands of Player, the latest first, Opponent and Rules
+// are ignored if "" and the hands end in [Since, Until), a zero time is
+// unbounded. Limit is at most 100, 20 if it's 0
+type HandQuery struct {
+	Player   string
+	Opponent string
+	Rules    string
+	Since    time.Time
+	Until    time.Time
+	Offset   int
+	Limit    int
+}
+
+// HandPage is a page of the hands selected by a query, Total is the number
+// of the hands selected on every page
+type HandPage struct {
+	Total  int
+	Offset int
+	Hands  []HandRecord
+}
+
+// Seat returns the seat of the player in the hand, -1 if the player isn't
+func (record HandRecord) Seat(name string) int {
+	for i, player := range record.Names {
+		if player == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (query HandQuery) normalize() HandQuery {
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultHandPage
+	}
+	if query.Limit > maxHandPage {
+		query.Limit = maxHandPage
+	}
+	return query
+}
+
+// own narrows the query to the hands since the player's account was created,
+// so a guest who takes an expired guest's name doesn't get the last one's
+func (query HandQuery) own() HandQuery {
+	if start := historyStart(query.Player); query.Since.Before(start) {
+		query.Since = start
+	}
+	return query
+}
+
+// handIndex indexes the hand records by player in the order they're added
+type handIndex struct {
+	entries  []handEntry
+	byPlayer map[string][]int
+}
+
+type handEntry struct {
+	Names [4]string
+	Rules string
+	End   time.Time
+}
+
+func (index *handIndex) add(record HandRecord) {
+	if index.byPlayer == nil {
+		index.byPlayer = make(map[string][]int)
+	}
+	position := len(index.entries)
+	index.entries = append(index.entries, handEntry {record.Names, record.Rules.Name, record.End})
+	for i, name := range record.Names {
+		if record.Seat(name) == i {
+			index.byPlayer[name] = append(index.byPlayer[name], position)
+		}
+	}
+}
+
+// query returns the positions of the records on the page of the query and
+// the number of the records selected
+func (index *handIndex) query(query HandQuery) ([]int, int) {
+	var page []int
+	total := 0
+	list  := index.byPlayer[query.Player]
+	for i := len(list) - 1; i >= 0; i-- {
+		entry := index.entries[list[i]]
+		if query.Rules != "" && entry.Rules != query.Rules ||
+			!query.Since.IsZero() && entry.End.Before(query.Since) ||
+			!query.Until.IsZero() && !entry.End.Before(query.Until) {
+			continue
+		}
+		if query.Opponent != "" {
+			found := false
+			for _, name := range entry.Names {
+				found = found || name == query.Opponent && name != query.Player
+			}
+			if !found {
+				continue
+			}
+		}
+		if total >= query.Offset && len(page) < query.Limit {
+			page = append(page, list[i])
+		}
+		total++
+	}
+	return page, total
+}
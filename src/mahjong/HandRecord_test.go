@@ -0,0 +1,127 @@
+package mahjong
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"mahjong/engine"
+)
+
+// handsStart is when alice's account is created in the hand store
+var handsStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// handStore replaces Storage until the test ends by a store where alice
+// played n hands an hour apart after the account is created, against bob in
+// the even hands and carol in the odd ones, of the standard rules in the
+// even hands and chengdu in the odd ones. An expired account of the same
+// name played a hand before
+func handStore(t *testing.T, n int) *MemoryStore {
+	store := NewMemoryStore()
+	saved := Storage
+	t.Cleanup(func() {
+		Storage = saved
+	})
+	Storage = store
+	if err := store.PutAccount(Account {Name: "alice", Created: handsStart}); err != nil {
+		t.Fatal(err)
+	}
+	put := func(id string, opponent string, rules string, end time.Time) {
+		hand := HandRecord {GameID: id, Names: [4]string{"alice", opponent, "dave", "erin"}, Rules: engine.RulePresets[rules], End: end}
+		if err := store.PutHandRecord(hand); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("expired", "bob", "standard", handsStart.Add(-time.Hour))
+	for i := 0; i < n; i++ {
+		put(fmt.Sprint("hand-", i), []string{"bob", "carol"}[i % 2], []string{"standard", "chengdu"}[i % 2], handsStart.Add(time.Duration(i + 1) * time.Hour))
+	}
+	return store
+}
+
+// handIDs returns the game IDs of the hands on the page of the query
+func handIDs(t *testing.T, query HandQuery) (HandPage, []string) {
+	t.Helper()
+	page, err := Storage.HandRecords(query.own())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, hand := range page.Hands {
+		ids = append(ids, hand.GameID)
+	}
+	return page, ids
+}
+
+func TestHandRecordsPaging(t *testing.T) {
+	handStore(t, 25)
+	var all []string
+	for offset := 0; offset < 30; offset += 10 {
+		page, ids := handIDs(t, HandQuery {Player: "alice", Offset: offset, Limit: 10})
+		if page.Total != 25 || page.Offset != offset {
+			t.Errorf("offset %d: the page is of %d hands from %d", offset, page.Total, page.Offset)
+		}
+		all = append(all, ids...)
+	}
+	if len(all) != 25 {
+		t.Fatalf("%d hands on the pages, want 25", len(all))
+	}
+	for i, id := range all {
+		if want := fmt.Sprint("hand-", 24 - i); id != want {
+			t.Fatalf("hand %d is %s, want %s", i, id, want)
+		}
+	}
+}
+
+func TestHandRecordsFilters(t *testing.T) {
+	handStore(t, 10)
+	hour := func(n int) time.Time {
+		return handsStart.Add(time.Duration(n) * time.Hour)
+	}
+	cases := []struct {
+		name  string
+		query HandQuery
+		ids   []string
+	}{
+		{"opponent",           HandQuery {Opponent: "carol"},                                  []string{"hand-9", "hand-7", "hand-5", "hand-3", "hand-1"}},
+		{"rules",              HandQuery {Rules: "standard"},                                  []string{"hand-8", "hand-6", "hand-4", "hand-2", "hand-0"}},
+		{"since and until",    HandQuery {Since: hour(3), Until: hour(6)},                     []string{"hand-4", "hand-3", "hand-2"}},
+		{"every filter",       HandQuery {Opponent: "bob", Rules: "standard", Since: hour(5)}, []string{"hand-8", "hand-6", "hand-4"}},
+		{"no match",           HandQuery {Opponent: "bob", Rules: "chengdu"},                  []string{}},
+		{"oneself",            HandQuery {Opponent: "alice"},                                  []string{}},
+		{"before the account", HandQuery {Until: hour(1)},                                     []string{}},
+	}
+	for _, c := range cases {
+		c.query.Player = "alice"
+		page, ids := handIDs(t, c.query)
+		if fmt.Sprint(ids) != fmt.Sprint(c.ids) || page.Total != len(c.ids) {
+			t.Errorf("%s: %v of %d, want %v", c.name, ids, page.Total, c.ids)
+		}
+	}
+	if page, _ := handIDs(t, HandQuery {Player: "bob"}); page.Total != 6 {
+		t.Errorf("bob has %d hands, want 6 with the one before alice's account", page.Total)
+	}
+}
+
+func TestHandRecordsBounds(t *testing.T) {
+	handStore(t, maxHandPage + 10)
+	cases := []struct {
+		name   string
+		offset int
+		limit  int
+		first  int
+		hands  int
+	}{
+		{"default limit",   0,                0,               0,                defaultHandPage},
+		{"limit capped",    0,                maxHandPage * 2, 0,                maxHandPage},
+		{"negative offset", -5,               5,               0,                5},
+		{"last page",       maxHandPage + 5,  20,              maxHandPage + 5,  5},
+		{"past the end",    maxHandPage + 10, 20,              maxHandPage + 10, 0},
+	}
+	for _, c := range cases {
+		page, ids := handIDs(t, HandQuery {Player: "alice", Offset: c.offset, Limit: c.limit})
+		if page.Total != maxHandPage + 10 || page.Offset != c.first || len(ids) != c.hands || page.Hands == nil {
+			t.Errorf("%s: %d hands from %d of %d, want %d from %d", c.name, len(ids), page.Offset, page.Total, c.hands, c.first)
+		}
+	}
+}
@@ -1,51 +1,70 @@
 package mahjong
 
-func (player *Player) checkChangeTiles(val interface{}) bool {
+import (
+	"log"
+
+	"mahjong/engine"
+)
+
+// ErrMalformed is the rejection code of an input which can't be read
+const ErrMalformed = "malformed"
+
+func (agent *SocketAgent) checkChangeTiles(val interface{}) bool {
 	switch val.(type) {
 	case []interface{}:
 	default:
 		return false
 	}
 	valArr := val.([]interface{})
+	if len(valArr) != 3 {
+		return false
+	}
 	for i := 0; i < 3; i++ {
-		if !IsValidTile(valArr[i].(string)) {
+		tile, ok := valArr[i].(string)
+		if !ok || !engine.IsValidTile(tile) {
 			return false
 		}
 	}
 	return true
 }
 
-func (player *Player) checkLack(val interface{}) bool {
+func (agent *SocketAgent) checkLack(val interface{}) bool {
 	switch val.(type) {
 	case float64:
 	default:
 		return false
 	}
 	lack := int(val.(float64))
-	return lack >= 0 && lack < 4
+	return lack >= 0 && lack < 3
 }
 
-func (player *Player) checkThrow(val interface{}) bool {
+func (agent *SocketAgent) checkThrow(val interface{}) bool {
 	switch val.(type) {
 	case string:
-		return IsValidTile(val.(string))
+		return engine.IsValidTile(val.(string))
 	default:
 		return false
 	}
 }
 
-func (player *Player) checkCommand(val interface{}) bool {
+func (agent *SocketAgent) checkCommand(val interface{}) bool {
 	switch val.(type) {
 	case string:
 	default:
 		return false
 	}
-	act  := JSONToAction(val.(string))
+	act  := engine.JSONToAction(val.(string))
 	flag := false
-	for _, command := range COMMAND {
+	for _, command := range engine.COMMAND {
 		if act.Command == command {
 			flag = true
 		}
 	}
-	return flag && act.Tile.Suit != -1
+	return flag && (act.Command == engine.COMMAND["NONE"] || act.Tile.Suit != -1)
+}
+
+// reject logs the input which can't be read and tells the client
+func (agent *SocketAgent) reject(seat int, event string, val interface{}) {
+	log.Println("reject input:", agent.UUID, seat, event, val)
+	agent.emit("invalidMove", &engine.MoveError {Code: ErrMalformed, Seat: seat, Message: "the input of " + event + " can not be read"})
 }
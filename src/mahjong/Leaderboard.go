@@ -0,0 +1,451 @@
+package mahjong
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Leaderboards are the leaderboards of the players, nil disables them
+var Leaderboards *LeaderboardBook
+
+// SeasonLength is how long a season lasts, a new season starts when it ends
+var SeasonLength = 28 * 24 * time.Hour
+
+// LeaderboardSaveDelay is how long the boards changed by a hand are kept
+// unsaved, the hands finished in the meantime are saved with it. 0 saves
+// the boards after every hand
+var LeaderboardSaveDelay = 10 * time.Second
+
+// Periods of the leaderboards
+const (
+	PeriodAll    = "all"
+	PeriodSeason = "season"
+	PeriodWeek   = "week"
+)
+
+// Metrics the leaderboards are ranked by
+const (
+	MetricRating  = "rating"
+	MetricCredits = "credits"
+	MetricWins    = "wins"
+	MetricMaxTai  = "maxTai"
+)
+
+var periods = []string {PeriodAll, PeriodSeason, PeriodWeek}
+
+// Standing is a player's standing over a period, Credits are the net credits
+// of the hands, Wins is the number of hu and MaxTai is the most tai of a hu.
+// Rating is the player's rating after the last hand of the period
+type Standing struct {
+	Name    string
+	Hands   int
+	Rating  float64
+	Credits int
+	Wins    int
+	MaxTai  int
+}
+
+// Board is the standings of a period in the order of the names, ID is "all",
+// the number of a season or the ISO week like "2026-W42". The period lasts
+// from Start until End, the all-time board doesn't end
+type Board struct {
+	Period    string
+	ID        string
+	Start     time.Time
+	End       time.Time
+	Standings []Standing
+}
+
+// RankEntry is a standing at its rank, the players of the same value share
+// a rank
+type RankEntry struct {
+	Rank int
+	Standing
+}
+
+// LeaderboardQuery selects a page of a leaderboard, Period is "all" and
+// Metric is "rating" if "", ID "" is the period in progress and an ID of a
+// period which ended selects its final standings. Player is the player
+// whose rank is looked up, ignored if "". Limit is at most 100, 20 if it's 0
+type LeaderboardQuery struct {
+	Period string
+	ID     string
+	Metric string
+	Player string
+	Offset int
+	Limit  int
+}
+
+// LeaderboardPage is a page of a leaderboard, Total is the number of the
+// players on the board and Player is the rank of the player of the query,
+// nil if the player isn't on the board
+type LeaderboardPage struct {
+	Period  string
+	ID      string
+	Metric  string
+	Start   time.Time
+	End     time.Time
+	Total   int
+	Offset  int
+	Entries []RankEntry
+	Player  *RankEntry
+}
+
+// LoadLeaderboards loads the leaderboards kept in the store
+func LoadLeaderboards(store LeaderboardStore) (*LeaderboardBook, error) {
+	book := &LeaderboardBook {store: store, boards: make(map[string]*leaderboard)}
+	boards, err := store.Leaderboards()
+	if err != nil {
+		return book, err
+	}
+	for _, board := range boards {
+		book.boards[board.Period] = newLeaderboard(board)
+	}
+	return book, nil
+}
+
+// LeaderboardBook keeps the leaderboards of the periods in progress, which
+// are updated by every finished hand. It's safe for concurrent use, the
+// boards changed by the hands are stored LeaderboardSaveDelay after the first
+// change and by Flush, the other changes are stored at once
+//
+// A season or a week ends when a hand finishes or a board is asked for after
+// it, then its final standings are archived in the store and a new one
+// starts empty. A new season starts when the last one ends, and a new week
+// on the Monday of the week
+type LeaderboardBook struct {
+	mutex  sync.Mutex
+	store  LeaderboardStore
+	boards map[string]*leaderboard
+	dirty  bool
+	timer  *time.Timer
+}
+
+// Record adds a finished hand to the standings of the counted seats,
+// ratings are the ratings of the seats after the hand
+func (book *LeaderboardBook) Record(record HandRecord, counted [4]bool, ratings [4]float64) error {
+	book.mutex.Lock()
+	defer book.mutex.Unlock()
+	if err := book.rollover(record.End); err != nil {
+		return err
+	}
+	for i, name := range record.Names {
+		if !counted[i] || i >= len(record.Result) {
+			continue
+		}
+		result := record.Result[i]
+		maxTai := 0
+		for _, fans := range result.Fans {
+			tai := 0
+			for _, fan := range fans {
+				tai += fan.Tai
+			}
+			if tai > maxTai {
+				maxTai = tai
+			}
+		}
+		for _, board := range book.boards {
+			board.add(name, ratings[i], result.Score, len(result.HuTiles), maxTai)
+		}
+	}
+	if LeaderboardSaveDelay <= 0 {
+		return book.save()
+	}
+	book.dirty = true
+	if book.timer == nil {
+		book.timer = time.AfterFunc(LeaderboardSaveDelay, func() {
+			if err := book.Flush(); err != nil {
+				log.Println("leaderboards are not saved:", err)
+			}
+		})
+	}
+	return nil
+}
+
+// Flush stores the boards changed by the hands which aren't stored yet
+func (book *LeaderboardBook) Flush() error {
+	book.mutex.Lock()
+	defer book.mutex.Unlock()
+	if !book.dirty {
+		return nil
+	}
+	return book.save()
+}
+
+// Forget removes the player from the boards in progress, the archived
+// boards keep the player's final standings
+func (book *LeaderboardBook) Forget(name string) error {
+	book.mutex.Lock()
+	defer book.mutex.Unlock()
+	forgotten := false
+	for _, board := range book.boards {
+		if _, ok := board.standings[name]; ok {
+			delete(board.standings, name)
+			board.rankings = make(map[string][]RankEntry)
+			forgotten      = true
+		}
+	}
+	if !forgotten {
+		return nil
+	}
+	return book.save()
+}
+
+// Query returns the page of the leaderboard selected by the query
+func (book *LeaderboardBook) Query(query LeaderboardQuery) (LeaderboardPage, error) {
+	query, err := query.normalize()
+	if err != nil {
+		return LeaderboardPage{}, err
+	}
+	book.mutex.Lock()
+	if err := book.rollover(time.Now()); err != nil {
+		book.mutex.Unlock()
+		return LeaderboardPage{}, err
+	}
+	board := book.boards[query.Period]
+	if query.ID != "" && query.ID != board.ID {
+		board = nil
+	}
+	var page LeaderboardPage
+	if board != nil {
+		page = board.page(query)
+	}
+	book.mutex.Unlock()
+	if board != nil {
+		return page, nil
+	}
+
+	archived, err := book.store.ArchivedBoards(query.Period)
+	if err != nil {
+		return LeaderboardPage{}, err
+	}
+	for _, board := range archived {
+		if board.ID == query.ID {
+			return newLeaderboard(board).page(query), nil
+		}
+	}
+	return LeaderboardPage{}, ErrNotFound
+}
+
+// Archives returns the periods which ended without their standings, the
+// latest first
+func (book *LeaderboardBook) Archives(period string) ([]Board, error) {
+	if !isPeriod(period) {
+		return nil, fmt.Errorf("unknown period %q", period)
+	}
+	archived, err := book.store.ArchivedBoards(period)
+	if err != nil {
+		return nil, err
+	}
+	result := []Board{}
+	for i := len(archived) - 1; i >= 0; i-- {
+		board := archived[i]
+		board.Standings = nil
+		result = append(result, board)
+	}
+	return result, nil
+}
+
+// rollover archives the boards of the periods which ended before now and
+// starts the new ones, it's called with the mutex locked
+func (book *LeaderboardBook) rollover(now time.Time) error {
+	now     = now.In(time.Local)
+	changed := false
+	if book.boards[PeriodAll] == nil {
+		book.boards[PeriodAll] = newLeaderboard(Board {PeriodAll, PeriodAll, now, time.Time{}, nil})
+		changed = true
+	}
+	if book.boards[PeriodSeason] == nil {
+		book.boards[PeriodSeason] = newLeaderboard(Board {PeriodSeason, "1", now, now.Add(SeasonLength), nil})
+		changed = true
+	}
+	for season := book.boards[PeriodSeason]; SeasonLength > 0 && !now.Before(season.End); season = book.boards[PeriodSeason] {
+		if err := book.archive(season); err != nil {
+			return err
+		}
+		number, _ := strconv.Atoi(season.ID)
+		book.boards[PeriodSeason] = newLeaderboard(Board {PeriodSeason, strconv.Itoa(number + 1), season.End, season.End.Add(SeasonLength), nil})
+		changed = true
+	}
+	if week := book.boards[PeriodWeek]; week == nil || !now.Before(week.End) {
+		if week != nil {
+			if err := book.archive(week); err != nil {
+				return err
+			}
+		}
+		start := time.Date(now.Year(), now.Month(), now.Day() - (int(now.Weekday()) + 6) % 7, 0, 0, 0, 0, time.Local)
+		book.boards[PeriodWeek] = newLeaderboard(Board {PeriodWeek, weekID(now), start, start.AddDate(0, 0, 7), nil})
+		changed = true
+	}
+	if changed {
+		return book.save()
+	}
+	return nil
+}
+
+// archive stores the final standings of the board, a board without any
+// player isn't archived
+func (book *LeaderboardBook) archive(board *leaderboard) error {
+	if len(board.standings) == 0 {
+		return nil
+	}
+	return book.store.ArchiveBoard(board.board())
+}
+
+// save stores every board, it's called with the mutex locked
+func (book *LeaderboardBook) save() error {
+	if book.timer != nil {
+		book.timer.Stop()
+		book.timer = nil
+	}
+	var boards []Board
+	for _, period := range periods {
+		if board, ok := book.boards[period]; ok {
+			boards = append(boards, board.board())
+		}
+	}
+	if err := book.store.PutLeaderboards(boards); err != nil {
+		return err
+	}
+	book.dirty = false
+	return nil
+}
+
+func weekID(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+func isPeriod(period string) bool {
+	for _, p := range periods {
+		if p == period {
+			return true
+		}
+	}
+	return false
+}
+
+func (query LeaderboardQuery) normalize() (LeaderboardQuery, error) {
+	if query.Period == "" {
+		query.Period = PeriodAll
+	}
+	if query.Metric == "" {
+		query.Metric = MetricRating
+	}
+	if !isPeriod(query.Period) {
+		return query, fmt.Errorf("unknown period %q", query.Period)
+	}
+	switch query.Metric {
+	case MetricRating, MetricCredits, MetricWins, MetricMaxTai:
+	default:
+		return query, fmt.Errorf("unknown metric %q", query.Metric)
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultHandPage
+	}
+	if query.Limit > maxHandPage {
+		query.Limit = maxHandPage
+	}
+	return query, nil
+}
+
+// leaderboard is a board with the standings by name and the rankings by
+// metric, which are sorted when they're asked for after a change
+type leaderboard struct {
+	Board
+	standings map[string]*Standing
+	rankings  map[string][]RankEntry
+}
+
+func newLeaderboard(board Board) *leaderboard {
+	result := &leaderboard {Board: board, standings: make(map[string]*Standing), rankings: make(map[string][]RankEntry)}
+	for i := range board.Standings {
+		standing := board.Standings[i]
+		result.standings[standing.Name] = &standing
+	}
+	result.Standings = nil
+	return result
+}
+
+func (board *leaderboard) add(name string, rating float64, credits int, wins int, maxTai int) {
+	standing, ok := board.standings[name]
+	if !ok {
+		standing = &Standing {Name: name}
+		board.standings[name] = standing
+	}
+	standing.Hands++
+	standing.Rating   = rating
+	standing.Credits += credits
+	standing.Wins    += wins
+	if maxTai > standing.MaxTai {
+		standing.MaxTai = maxTai
+	}
+	board.rankings = make(map[string][]RankEntry)
+}
+
+// board returns the board with the standings in the order of the names
+func (board *leaderboard) board() Board {
+	result := board.Board
+	result.Standings = make([]Standing, 0, len(board.standings))
+	for _, standing := range board.standings {
+		result.Standings = append(result.Standings, *standing)
+	}
+	sort.Slice(result.Standings, func(i, j int) bool {
+		return result.Standings[i].Name < result.Standings[j].Name
+	})
+	return result
+}
+
+// ranking returns the standings ranked by the metric, the players of the
+// same value are in the order of the names
+func (board *leaderboard) ranking(metric string) []RankEntry {
+	if ranking, ok := board.rankings[metric]; ok {
+		return ranking
+	}
+	value := func(standing Standing) float64 {
+		switch metric {
+		case MetricCredits:
+			return float64(standing.Credits)
+		case MetricWins:
+			return float64(standing.Wins)
+		case MetricMaxTai:
+			return float64(standing.MaxTai)
+		}
+		return standing.Rating
+	}
+	standings := board.board().Standings
+	sort.SliceStable(standings, func(i, j int) bool {
+		return value(standings[i]) > value(standings[j])
+	})
+	ranking := make([]RankEntry, len(standings))
+	for i, standing := range standings {
+		ranking[i] = RankEntry {i + 1, standing}
+		if i > 0 && value(standing) == value(standings[i - 1]) {
+			ranking[i].Rank = ranking[i - 1].Rank
+		}
+	}
+	board.rankings[metric] = ranking
+	return ranking
+}
+
+func (board *leaderboard) page(query LeaderboardQuery) LeaderboardPage {
+	ranking := board.ranking(query.Metric)
+	page    := LeaderboardPage {board.Period, board.ID, query.Metric, board.Start, board.End, len(ranking), query.Offset, []RankEntry{}, nil}
+	for i := query.Offset; i < len(ranking) && len(page.Entries) < query.Limit; i++ {
+		page.Entries = append(page.Entries, ranking[i])
+	}
+	for i := range ranking {
+		if query.Player != "" && ranking[i].Name == query.Player {
+			entry      := ranking[i]
+			page.Player = &entry
+		}
+	}
+	return page
+}
@@ -0,0 +1,196 @@
+package mahjong
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"mahjong/engine"
+)
+
+// ranked returns a hand which ended at end, every seat is counted and scores
+// its credits
+func ranked(end time.Time, names [4]string, credits [4]int) HandRecord {
+	result := make([]engine.GameResult, 4)
+	for i := range result {
+		result[i].Score = credits[i]
+	}
+	return HandRecord {GameID: fmt.Sprint("hand-", end.UnixNano()), Names: names, End: end, Result: result}
+}
+
+// record adds the hand to the book and fails the test on an error
+func record(t *testing.T, book *LeaderboardBook, hand HandRecord) {
+	if err := book.Record(hand, [4]bool{true, true, true, true}, [4]float64{1600, 1600, 1600, 1600}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// board returns the board of the period kept in the store
+func board(t *testing.T, store *MemoryStore, period string) Board {
+	boards, err := store.Leaderboards()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, board := range boards {
+		if board.Period == period {
+			return board
+		}
+	}
+	t.Fatalf("there is no board of %s", period)
+	return Board{}
+}
+
+func TestRollover(t *testing.T) {
+	defer func(delay time.Duration) { LeaderboardSaveDelay = delay }(LeaderboardSaveDelay)
+	LeaderboardSaveDelay = 0
+	store   := NewMemoryStore()
+	book, _ := LoadLeaderboards(store)
+	start   := time.Now()
+	first   := [4]string{"a", "b", "c", "d"}
+	second  := [4]string{"a", "e", "f", "g"}
+	record(t, book, ranked(start, first, [4]int{3, -1, -1, -1}))
+	week   := board(t, store, PeriodWeek)
+	record(t, book, ranked(week.End.Add(-time.Minute), first, [4]int{1, -1, 0, 0}))
+	if archived, _ := store.ArchivedBoards(PeriodWeek); len(archived) != 0 {
+		t.Errorf("%d weeks are archived before the week ends", len(archived))
+	}
+
+	record(t, book, ranked(week.End, second, [4]int{2, -2, 0, 0}))
+	archived, _ := store.ArchivedBoards(PeriodWeek)
+	if len(archived) != 1 || archived[0].ID != week.ID || len(archived[0].Standings) != 4 {
+		t.Fatalf("archived weeks %v, want %s of 4 players", archived, week.ID)
+	}
+	if standing := archived[0].Standings[0]; standing.Name != "a" || standing.Hands != 2 || standing.Credits != 4 {
+		t.Errorf("archived standing %+v", standing)
+	}
+	if next := board(t, store, PeriodWeek); !next.Start.Equal(week.End) || len(next.Standings) != 4 || next.Standings[0].Credits != 2 {
+		t.Errorf("the new week %+v", next)
+	}
+	if all := board(t, store, PeriodAll); len(all.Standings) != 7 {
+		t.Errorf("%d players are on the all-time board, want 7", len(all.Standings))
+	}
+
+	// season 1 is archived and season 2 is skipped without a hand
+	store   = NewMemoryStore()
+	book, _ = LoadLeaderboards(store)
+	record(t, book, ranked(start, first, [4]int{3, -1, -1, -1}))
+	record(t, book, ranked(start.Add(2 * SeasonLength), second, [4]int{2, -2, 0, 0}))
+	seasons, _ := store.ArchivedBoards(PeriodSeason)
+	if len(seasons) != 1 || seasons[0].ID != "1" || len(seasons[0].Standings) != 4 {
+		t.Errorf("archived seasons %v, want season 1 of 4 players", seasons)
+	}
+	if season := board(t, store, PeriodSeason); season.ID != "3" || !season.Start.Equal(start.Add(2 * SeasonLength)) || len(season.Standings) != 4 {
+		t.Errorf("season %s starts at %v with %d players", season.ID, season.Start, len(season.Standings))
+	}
+}
+
+func TestTiedRanks(t *testing.T) {
+	book, _ := LoadLeaderboards(NewMemoryStore())
+	record(t, book, ranked(time.Now(), [4]string{"a", "b", "c", "d"}, [4]int{4, 4, -4, -4}))
+	page, err := book.Query(LeaderboardQuery {Metric: MetricCredits, Player: "d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ranks []int
+	for _, entry := range page.Entries {
+		ranks = append(ranks, entry.Rank)
+	}
+	if fmt.Sprint(ranks) != "[1 1 3 3]" || page.Entries[0].Name != "a" || page.Entries[2].Name != "c" {
+		t.Errorf("ranks %v of %v", ranks, page.Entries)
+	}
+	if page.Player == nil || page.Player.Rank != 3 {
+		t.Errorf("the player's entry is %v", page.Player)
+	}
+}
+
+func TestLeaderboardPage(t *testing.T) {
+	// p29 to p01 are ranked 1 to 29 by credits, p00 and the bots share 30
+	book, _ := LoadLeaderboards(NewMemoryStore())
+	now     := time.Now()
+	for i := 0; i < 30; i++ {
+		names := [4]string{fmt.Sprintf("p%02d", i), BotName(1), BotName(2), BotName(3)}
+		record(t, book, ranked(now, names, [4]int{i}))
+	}
+	cases := []struct {
+		offset  int
+		limit   int
+		entries int
+		first   int
+	}{
+		{0,   0,   20, 1},
+		{0,   5,   5,  1},
+		{-3,  5,   5,  1},
+		{25,  5,   5,  26},
+		{30,  5,   3,  30},
+		{32,  5,   1,  30},
+		{33,  5,   0,  0},
+		{100, 5,   0,  0},
+		{0,   500, 33, 1},
+	}
+	for _, c := range cases {
+		page, err := book.Query(LeaderboardQuery {Metric: MetricCredits, Offset: c.offset, Limit: c.limit, Player: "p00"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 33 || len(page.Entries) != c.entries {
+			t.Errorf("offset %d limit %d: %d of %d entries, want %d of 33", c.offset, c.limit, len(page.Entries), page.Total, c.entries)
+			continue
+		}
+		if c.entries > 0 && page.Entries[0].Rank != c.first {
+			t.Errorf("offset %d limit %d: the first rank is %d, want %d", c.offset, c.limit, page.Entries[0].Rank, c.first)
+		}
+		if page.Player == nil || page.Player.Name != "p00" {
+			t.Errorf("offset %d limit %d: the player's entry is %v", c.offset, c.limit, page.Player)
+		}
+	}
+}
+
+func TestLeaderboardSaveDelay(t *testing.T) {
+	defer func(delay time.Duration) { LeaderboardSaveDelay = delay }(LeaderboardSaveDelay)
+	LeaderboardSaveDelay = time.Hour
+	store   := NewMemoryStore()
+	book, _ := LoadLeaderboards(store)
+	names   := [4]string{"a", "b", "c", "d"}
+	for i := 0; i < 3; i++ {
+		record(t, book, ranked(time.Now(), names, [4]int{1, -1}))
+	}
+	if standings := board(t, store, PeriodAll).Standings; len(standings) != 0 {
+		t.Errorf("%d standings are saved before the delay", len(standings))
+	}
+	if err := book.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if standings := board(t, store, PeriodAll).Standings; len(standings) != 4 || standings[0].Hands != 3 {
+		t.Errorf("standings %v after Flush", standings)
+	}
+
+	LeaderboardSaveDelay = 10 * time.Millisecond
+	record(t, book, ranked(time.Now(), names, [4]int{1, -1}))
+	deadline := time.Now().Add(5 * time.Second)
+	for board(t, store, PeriodAll).Standings[0].Hands != 4 {
+		if time.Now().After(deadline) {
+			t.Fatal("the hand isn't saved after the delay")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCappedWinCounts(t *testing.T) {
+	book, _ := LoadLeaderboards(NewMemoryStore())
+	hand    := ranked(time.Now(), [4]string{"a", "b", "c", "d"}, [4]int{0, 0, 0, 0})
+	// a wins twice, the second hu is capped to nothing as b and c are bankrupt
+	hand.Result[0].HuTiles = []string{"d5", "d8"}
+	hand.Result[0].Fans    = [][]engine.Fan {{{Name: "平胡", Tai: 1}}}
+	hand.Result[1].Fans    = [][]engine.Fan {{{Name: "七對", Tai: 3}}}
+	record(t, book, hand)
+	page, err := book.Query(LeaderboardQuery {Metric: MetricWins, Player: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Entries[0].Name != "a" || page.Entries[0].Wins != 2 {
+		t.Errorf("the first entry is %+v, want a with 2 wins", page.Entries[0])
+	}
+	if page.Player == nil || page.Player.Wins != 0 {
+		t.Errorf("a seat with fans but no hu has %+v", page.Player)
+	}
+}
@@ -0,0 +1,137 @@
+package mahjong
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"mahjong/engine"
+)
+
+// Accounts of the ledger which aren't players, a player's name can't start
+// with '#'. The bank grants the starting credits
+const (
+	HouseAccount   = "#house"
+	OpeningAccount = "#opening"
+	BankAccount    = "#bank"
+)
+
+// ErrPosted is returned by a store if the settlement of the game is posted
+var ErrPosted = errors.New("settlement is posted")
+
+// Posting is a side of a transaction, Amount is added to the balance of
+// Account and Counterparty is the account on the other side
+type Posting struct {
+	Account      string
+	Counterparty string
+	Amount       int
+}
+
+// Transaction moves credits between the accounts, the amounts of its
+// postings sum to 0
+type Transaction struct {
+	ID       string
+	GameID   string
+	Reason   string
+	Message  string
+	Time     time.Time
+	Postings []Posting
+}
+
+// Settlement is the transactions of a hand, which are posted at once
+type Settlement struct {
+	GameID       string
+	Time         time.Time
+	Transactions []Transaction
+}
+
+// NewSettlement creates the settlement of a finished hand, every payment in
+// the score logs becomes a transaction between the accounts of the seats. It
+// fails if the transactions don't add up to the credits of the seats
+func NewSettlement(gameID string, game engine.GameState, accounts [4]string) (Settlement, error) {
+	now        := time.Now()
+	settlement := Settlement {gameID, now, nil}
+	var net [4]int
+	for i, seat := range game.Seats {
+		for _, record := range seat.ScoreLog {
+			to := record.Counterparty
+			if record.Score >= 0 || to < 0 || to >= 4 {
+				continue
+			}
+			id := fmt.Sprintf("%s/%d", gameID, len(settlement.Transactions))
+			settlement.Transactions = append(settlement.Transactions, Transaction {id, gameID, record.Reason, record.Message, now, []Posting {
+				{accounts[i], accounts[to], record.Score},
+				{accounts[to], accounts[i], -record.Score},
+			}})
+			net[i]  += record.Score
+			net[to] -= record.Score
+		}
+	}
+	for i, seat := range game.Seats {
+		if net[i] != seat.Credit {
+			return settlement, fmt.Errorf("seat %d is paid %d but has %d credits", i, net[i], seat.Credit)
+		}
+	}
+	return settlement, settlement.Check()
+}
+
+// Check returns an error if a transaction isn't balanced or isn't of the game
+func (settlement Settlement) Check() error {
+	for _, transaction := range settlement.Transactions {
+		sum := 0
+		for _, posting := range transaction.Postings {
+			sum += posting.Amount
+		}
+		if sum != 0 || transaction.GameID != settlement.GameID {
+			return fmt.Errorf("transaction %s isn't balanced", transaction.ID)
+		}
+	}
+	return nil
+}
+
+// ledger is the balances and the transactions of the accounts kept in
+// memory, a settlement is checked before anything is changed
+type ledger struct {
+	balances     map[string]int
+	posted       map[string]bool
+	transactions []Transaction
+	byAccount    map[string][]int
+}
+
+func newLedger() ledger {
+	return ledger {balances: make(map[string]int), posted: make(map[string]bool), byAccount: make(map[string][]int)}
+}
+
+func (ledger *ledger) check(settlement Settlement) error {
+	if ledger.posted[settlement.GameID] {
+		return ErrPosted
+	}
+	return settlement.Check()
+}
+
+func (ledger *ledger) post(settlement Settlement) {
+	ledger.posted[settlement.GameID] = true
+	for _, transaction := range settlement.Transactions {
+		position := len(ledger.transactions)
+		ledger.transactions = append(ledger.transactions, transaction)
+		for i, posting := range transaction.Postings {
+			ledger.balances[posting.Account] += posting.Amount
+			if i == 0 || posting.Account != transaction.Postings[0].Account {
+				ledger.byAccount[posting.Account] = append(ledger.byAccount[posting.Account], position)
+			}
+		}
+	}
+}
+
+// history returns the transactions of the account, the latest first
+func (ledger *ledger) history(account string, offset int, limit int) []Transaction {
+	result := []Transaction{}
+	list   := ledger.byAccount[account]
+	if offset < 0 {
+		offset = 0
+	}
+	for i := len(list) - 1 - offset; i >= 0 && (limit <= 0 || len(result) < limit); i-- {
+		result = append(result, ledger.transactions[list[i]])
+	}
+	return result
+}
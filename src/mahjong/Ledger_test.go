@@ -0,0 +1,121 @@
+package mahjong
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mahjong/engine"
+)
+
+// transfer returns a settlement of the game which moves amount from one
+// account to another
+func transfer(gameID string, from string, to string, amount int) Settlement {
+	now := time.Now()
+	return Settlement {gameID, now, []Transaction {{gameID + "/0", gameID, "transfer", "", now, []Posting {
+		{to, from, amount},
+		{from, to, -amount},
+	}}}}
+}
+
+// balances returns the balances of the accounts in the store
+func balances(t *testing.T, store Store, accounts ...string) []int {
+	var result []int
+	for _, account := range accounts {
+		balance, err := store.Balance(account)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, balance)
+	}
+	return result
+}
+
+func TestNewSettlement(t *testing.T) {
+	rules      := engine.RulePresets["standard"]
+	rules.Hands = 1
+	room       := playMatch(t, "test-settlement", rules)
+	names      := room.Header.Names
+	settlement, err := NewSettlement(room.GameID, room.Game, names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryStore()
+	if err := store.PostSettlement(settlement); err != nil {
+		t.Fatal(err)
+	}
+	sum := 0
+	for i, balance := range balances(t, store, names[:]...) {
+		if balance != room.Game.Seats[i].Credit {
+			t.Errorf("seat %d has %d credits but a balance of %d", i, room.Game.Seats[i].Credit, balance)
+		}
+		sum += balance
+	}
+	if sum != 0 {
+		t.Errorf("balances sum to %d", sum)
+	}
+
+	game := room.Game
+	game.Seats[0].Credit++
+	if _, err := NewSettlement(room.GameID, game, names); err == nil {
+		t.Error("a settlement which doesn't add up to the credits is created")
+	}
+}
+
+func TestRejectSettlement(t *testing.T) {
+	unbalanced := transfer("unbalanced", "a", "b", 5)
+	unbalanced.Transactions[0].Postings[1].Amount = -4
+	foreign := transfer("foreign", "a", "b", 5)
+	foreign.Transactions[0].GameID = "other"
+
+	for name, store := range map[string]Store {
+		"memory": NewMemoryStore(),
+		"file":   openStore(t, t.TempDir()),
+	} {
+		if err := store.PostSettlement(transfer("game", "a", "b", 5)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := store.PostSettlement(transfer("game", "a", "b", 7)); err != ErrPosted {
+			t.Errorf("%s: a duplicate settlement returns %v, want ErrPosted", name, err)
+		}
+		for _, settlement := range []Settlement {unbalanced, foreign} {
+			if err := store.PostSettlement(settlement); err == nil {
+				t.Errorf("%s: settlement %s is posted", name, settlement.GameID)
+			}
+		}
+		if got := balances(t, store, "a", "b"); got[0] != -5 || got[1] != 5 {
+			t.Errorf("%s: balances %v, want [-5 5]", name, got)
+		}
+		if transactions, err := store.Transactions("a", 0, 10); err != nil || len(transactions) != 1 {
+			t.Errorf("%s: %d transactions, want 1, %v", name, len(transactions), err)
+		}
+	}
+}
+
+func TestPartialSettlement(t *testing.T) {
+	dir   := t.TempDir()
+	store := openStore(t, dir)
+	if err := store.PostSettlement(transfer("first", "a", "b", 5)); err != nil {
+		t.Fatal(err)
+	}
+	line, err := json.Marshal(transfer("second", "a", "b", 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := appendBytes(filepath.Join(dir, ledgerFile), line[:len(line) / 2]); err != nil {
+		t.Fatal(err)
+	}
+
+	store = openStore(t, dir)
+	if got := balances(t, store, "a", "b"); got[0] != -5 || got[1] != 5 {
+		t.Errorf("balances %v after a partial write, want [-5 5]", got)
+	}
+	if err := store.PostSettlement(transfer("second", "a", "b", 7)); err != nil {
+		t.Fatalf("the settlement of the partial write isn't posted again: %v", err)
+	}
+	store = openStore(t, dir)
+	if got := balances(t, store, "a", "b"); got[0] != -12 || got[1] != 12 {
+		t.Errorf("balances %v, want [-12 12]", got)
+	}
+}
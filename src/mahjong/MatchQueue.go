@@ -0,0 +1,369 @@
+package mahjong
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"mahjong/engine"
+)
+
+// keepWaits is how many waits of matched players are kept to estimate a wait
+const keepWaits = 16
+
+// rangeCheck is how often a queue checks again while the rating ranges widen
+const rangeCheck = time.Second
+
+// NewMatchQueue creates a queue which forms tables of size seats, match is
+// called with the players, the rules and the tier of every table formed
+func NewMatchQueue(size int, botWait time.Duration, timeout time.Duration, match func(players []string, rules string, tier string)) *MatchQueue {
+	return &MatchQueue {
+		Size: size, BotWait: botWait, Timeout: timeout, Range: 100, Widen: 10,
+		Notify: notifyPlayer, Rating: ratingOf, Balance: balanceOf, Guest: isGuest, match: match,
+	}
+}
+
+// MatchQueue forms tables from the queued players
+//
+// Players which ask for the same rules and tier are compatible, a player
+// needs the minimum balance of the tier to join and a guest told by Guest can
+// only join the practice tier. A table is formed
+// as soon as Size compatible players in the rating ranges of each other are
+// queued. If the oldest of them has waited BotWait, the table is formed with
+// the queued ones and bots fill the empty seats. A player leaves the queue
+// after Timeout, 0 disables either. Every change of the queue pushes the
+// position and the estimated wait to the queued players by Notify
+//
+// A player only sits with the players whose ratings are within Range of
+// the player's rating, and the range widens by Widen every second the player
+// waits. The oldest players are seated first, with the closest ratings
+//
+// The queue changes the players' states in PlayerList while its mutex is
+// locked, so the mutex is always locked before the registry's and never from
+// an Update callback. Notify and match are called with the mutex unlocked
+type MatchQueue struct {
+	Size    int
+	BotWait time.Duration
+	Timeout time.Duration
+	Range   float64
+	Widen   float64
+	Notify  func(uuid string, event string, args ...interface{})
+	Rating  func(uuid string) float64
+	Balance func(uuid string) int
+	Guest   func(uuid string) bool
+	match   func(players []string, rules string, tier string)
+	mutex   sync.Mutex
+	entries []queueEntry
+	waits   []time.Duration
+	timer   *time.Timer
+}
+
+type queueEntry struct {
+	UUID   string
+	Rules  string
+	Tier   string
+	Rating float64
+	Joined time.Time
+}
+
+// QueueStatus is pushed to a queued player, Position counts from 1 among the
+// players of the same rules and tier and Wait is the estimated wait in
+// milliseconds, -1 if it's unknown
+type QueueStatus struct {
+	Position int
+	Queued   int
+	Rules    string
+	Tier     string
+	Wait     int64
+}
+
+type notification struct {
+	uuid  string
+	event string
+	args  []interface{}
+}
+
+type table struct {
+	players []string
+	rules   string
+	tier    string
+}
+
+// Join queues the waiting player of uuid for the rules and the tier, "" is
+// the default, it fails if the player isn't waiting, the rules or the tier
+// are unknown or the player's balance is under the minimum of the tier
+func (queue *MatchQueue) Join(uuid string, rules string, tier string) bool {
+	if rules == "" {
+		rules = DefaultRules.Name
+	}
+	if _, ok := engine.GetRuleSet(rules); !ok {
+		return false
+	}
+	class, ok := GetTier(tier, queue.Guest(uuid))
+	if !ok || queue.Balance(uuid) < class.MinBalance {
+		return false
+	}
+	queue.mutex.Lock()
+	queued := false
+	PlayerList.Update(uuid, func(player *IPlayer) {
+		if player.State == WAITING {
+			player.State = QUEUED
+		}
+		queued = player.State == QUEUED
+	})
+	if !queued || queue.find(uuid) != -1 {
+		queue.mutex.Unlock()
+		return false
+	}
+	queue.entries = append(queue.entries, queueEntry {uuid, rules, class.Name, queue.Rating(uuid), time.Now()})
+	queue.update()
+	return true
+}
+
+// Cancel removes the player of uuid from the queue and the player waits in
+// the lobby, it returns false if the player isn't queued
+func (queue *MatchQueue) Cancel(uuid string) bool {
+	queue.mutex.Lock()
+	index := queue.find(uuid)
+	if index == -1 {
+		queue.mutex.Unlock()
+		return false
+	}
+	queue.remove(index, WAITING)
+	queue.update()
+	return true
+}
+
+// Position returns the status of the player of uuid, false if the player
+// isn't queued
+func (queue *MatchQueue) Position(uuid string) (QueueStatus, bool) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for _, status := range queue.statuses(time.Now()) {
+		if status.uuid == uuid {
+			return status.args[0].(QueueStatus), true
+		}
+	}
+	return QueueStatus{}, false
+}
+
+// Len returns the number of queued players
+func (queue *MatchQueue) Len() int {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return len(queue.entries)
+}
+
+func (queue *MatchQueue) check() {
+	queue.mutex.Lock()
+	queue.update()
+}
+
+// update forms the tables, drops the players which timed out, schedules the
+// next check and pushes the statuses, it's called with the mutex locked and
+// unlocks it
+func (queue *MatchQueue) update() {
+	now := time.Now()
+	var notifications []notification
+	var tables        []table
+	for i := 0; i < len(queue.entries); {
+		entry := queue.entries[i]
+		if queue.Timeout > 0 && now.Sub(entry.Joined) >= queue.Timeout {
+			queue.remove(i, WAITING)
+			notifications = append(notifications, notification {entry.UUID, "queueTimeout", nil})
+			continue
+		}
+		i++
+	}
+	for {
+		group := queue.ready(now)
+		if group == nil {
+			break
+		}
+		var players []string
+		for _, index := range group {
+			entry := queue.entries[index]
+			players = append(players, entry.UUID)
+			queue.waits = append(queue.waits, now.Sub(entry.Joined))
+		}
+		if len(queue.waits) > keepWaits {
+			queue.waits = queue.waits[len(queue.waits) - keepWaits: ]
+		}
+		first := queue.entries[group[0]]
+		tables = append(tables, table {players, first.Rules, first.Tier})
+		for i := len(group) - 1; i >= 0; i-- {
+			queue.remove(group[i], MATCHED)
+		}
+	}
+	queue.schedule(now)
+	notifications = append(notifications, queue.statuses(now)...)
+	queue.mutex.Unlock()
+
+	for _, table := range tables {
+		go queue.match(table.players, table.rules, table.tier)
+	}
+	for _, n := range notifications {
+		queue.Notify(n.uuid, n.event, n.args...)
+	}
+}
+
+// ready returns the indexes of the players of the first table which can be
+// formed in order, nil if there is none
+func (queue *MatchQueue) ready(now time.Time) []int {
+	for i, entry := range queue.entries {
+		var candidates []int
+		for j, other := range queue.entries {
+			if j != i && other.key() == entry.key() && queue.accepts(entry, other, now) {
+				candidates = append(candidates, j)
+			}
+		}
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return math.Abs(queue.entries[candidates[a]].Rating - entry.Rating) < math.Abs(queue.entries[candidates[b]].Rating - entry.Rating)
+		})
+		table := []int{i}
+		for _, j := range candidates {
+			if len(table) == queue.Size {
+				break
+			}
+			fit := true
+			for _, k := range table {
+				fit = fit && queue.accepts(queue.entries[j], queue.entries[k], now)
+			}
+			if fit {
+				table = append(table, j)
+			}
+		}
+		if len(table) == queue.Size || queue.BotWait > 0 && now.Sub(entry.Joined) >= queue.BotWait {
+			sort.Ints(table)
+			return table
+		}
+	}
+	return nil
+}
+
+// accepts returns if the two players are in the rating range of each other
+func (queue *MatchQueue) accepts(entry queueEntry, other queueEntry, now time.Time) bool {
+	diff := math.Abs(entry.Rating - other.Rating)
+	return diff <= queue.Range + queue.Widen * now.Sub(entry.Joined).Seconds() &&
+		diff <= queue.Range + queue.Widen * now.Sub(other.Joined).Seconds()
+}
+
+// schedule schedules the next check when a player times out, bots fill a
+// table or the rating ranges widen
+func (queue *MatchQueue) schedule(now time.Time) {
+	if queue.timer != nil {
+		queue.timer.Stop()
+		queue.timer = nil
+	}
+	var next time.Time
+	groups := make(map[string]int)
+	for _, entry := range queue.entries {
+		groups[entry.key()]++
+		if groups[entry.key()] >= queue.Size && queue.Widen > 0 {
+			next = now.Add(rangeCheck)
+		}
+	}
+	for _, entry := range queue.entries {
+		for _, wait := range []time.Duration{queue.BotWait, queue.Timeout} {
+			if wait > 0 && (next.IsZero() || entry.Joined.Add(wait).Before(next)) {
+				next = entry.Joined.Add(wait)
+			}
+		}
+	}
+	if !next.IsZero() {
+		queue.timer = time.AfterFunc(next.Sub(now), queue.check)
+	}
+}
+
+// statuses returns the status of every queued player
+func (queue *MatchQueue) statuses(now time.Time) []notification {
+	var average time.Duration
+	for _, wait := range queue.waits {
+		average += wait / time.Duration(len(queue.waits))
+	}
+	groups := make(map[string][]queueEntry)
+	for _, entry := range queue.entries {
+		groups[entry.key()] = append(groups[entry.key()], entry)
+	}
+	var result []notification
+	for _, entry := range queue.entries {
+		group    := groups[entry.key()]
+		position := 0
+		for group[position].UUID != entry.UUID {
+			position++
+		}
+		known := len(queue.waits) > 0
+		wait  := average - now.Sub(entry.Joined)
+		if first := group[position / queue.Size * queue.Size]; queue.BotWait > 0 {
+			botWait := queue.BotWait - now.Sub(first.Joined)
+			if !known || botWait < wait {
+				wait = botWait
+			}
+			known = true
+		}
+		if wait < 0 {
+			wait = 0
+		}
+		status := QueueStatus {position + 1, len(group), entry.Rules, entry.Tier, int64(engine.IF(known, int(wait / time.Millisecond), -1).(int))}
+		result  = append(result, notification {entry.UUID, "queue", []interface{}{status}})
+	}
+	return result
+}
+
+// key returns the key of the tables the player can sit at
+func (entry queueEntry) key() string {
+	return entry.Rules + "/" + entry.Tier
+}
+
+func (queue *MatchQueue) find(uuid string) int {
+	for i, entry := range queue.entries {
+		if entry.UUID == uuid {
+			return i
+		}
+	}
+	return -1
+}
+
+// remove removes the entry at index and moves the player to state
+func (queue *MatchQueue) remove(index int, state int) {
+	uuid := queue.entries[index].UUID
+	queue.entries = append(queue.entries[: index], queue.entries[index + 1: ]...)
+	PlayerList.Update(uuid, func(player *IPlayer) {
+		if player.State == QUEUED {
+			player.State = state
+		}
+	})
+}
+
+func ratingOf(uuid string) float64 {
+	player, ok := PlayerList.Get(uuid)
+	if !ok || Ratings == nil {
+		return InitialRating
+	}
+	return Ratings.Get(player.Name).Rating
+}
+
+func balanceOf(uuid string) int {
+	player, ok := PlayerList.Get(uuid)
+	if !ok {
+		return 0
+	}
+	balance, _ := Storage.Balance(player.Name)
+	return balance
+}
+
+func isGuest(uuid string) bool {
+	player, ok := PlayerList.Get(uuid)
+	if !ok {
+		return false
+	}
+	account, err := Storage.Account(player.Name)
+	return err == nil && account.Guest
+}
+
+func notifyPlayer(uuid string, event string, args ...interface{}) {
+	if player, ok := PlayerList.Get(uuid); ok && player.Socket != nil {
+		(*player.Socket).Emit(event, args...)
+	}
+}
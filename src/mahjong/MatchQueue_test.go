@@ -0,0 +1,207 @@
+package mahjong
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+// queueTest is a queue whose players are rated, funded and told apart as a
+// guest by its maps, the tables formed and the notifications other than the
+// statuses are sent to its channels
+type queueTest struct {
+	t        *testing.T
+	queue    *MatchQueue
+	tables   chan table
+	events   chan notification
+	ratings  map[string]float64
+	balances map[string]int
+	guests   map[string]bool
+}
+
+func newQueue(t *testing.T, size int, botWait time.Duration, timeout time.Duration) *queueTest {
+	test := &queueTest {
+		t:        t,
+		tables:   make(chan table, 16),
+		events:   make(chan notification, 16),
+		ratings:  make(map[string]float64),
+		balances: make(map[string]int),
+		guests:   make(map[string]bool),
+	}
+	test.queue = NewMatchQueue(size, botWait, timeout, func(players []string, rules string, tier string) {
+		test.tables <- table {players, rules, tier}
+	})
+	test.queue.Rating  = func(uuid string) float64 { return test.ratings[uuid] }
+	test.queue.Balance = func(uuid string) int { return test.balances[uuid] }
+	test.queue.Guest   = func(uuid string) bool { return test.guests[uuid] }
+	test.queue.Notify  = func(uuid string, event string, args ...interface{}) {
+		if event != "queue" {
+			test.events <- notification {uuid, event, args}
+		}
+	}
+	return test
+}
+
+// player adds a waiting player of the rating with enough credits for every
+// tier, the player is removed when the test ends
+func (test *queueTest) player(name string, rating float64) string {
+	uuid, err := PlayerList.Add(test.t.Name() + "/" + name)
+	if err {
+		test.t.Fatalf("the player %s is added twice", name)
+	}
+	test.ratings[uuid]  = rating
+	test.balances[uuid] = 1 << 30
+	test.t.Cleanup(func() {
+		test.queue.Cancel(uuid)
+		PlayerList.Remove(uuid)
+	})
+	return uuid
+}
+
+func (test *queueTest) state(uuid string) int {
+	player, _ := PlayerList.Get(uuid)
+	return player.State
+}
+
+// table waits up to wait for a table, ok is false if none is formed
+func (test *queueTest) table(wait time.Duration) (table, bool) {
+	select {
+	case formed := <-test.tables:
+		sort.Strings(formed.players)
+		return formed, true
+	case <-time.After(wait):
+		return table{}, false
+	}
+}
+
+func TestQueueFormsTable(t *testing.T) {
+	test := newQueue(t, 4, 0, 0)
+	var players []string
+	for _, name := range []string{"a", "b", "c", "d"} {
+		players = append(players, test.player(name, InitialRating))
+	}
+	for _, uuid := range players[:3] {
+		if !test.queue.Join(uuid, "", "") {
+			t.Fatal("a waiting player can't join")
+		}
+	}
+	if formed, ok := test.table(50 * time.Millisecond); ok {
+		t.Fatalf("a table %v is formed by three players", formed)
+	}
+	test.queue.Join(players[3], "", "")
+	formed, ok := test.table(time.Second)
+	sort.Strings(players)
+	if !ok || len(formed.players) != 4 || formed.rules != DefaultRules.Name || formed.tier != DefaultTier {
+		t.Fatalf("formed %v, want the four players", formed)
+	}
+	for i, uuid := range players {
+		if formed.players[i] != uuid || test.state(uuid) != MATCHED {
+			t.Errorf("the player %s isn't matched", uuid)
+		}
+	}
+	if test.queue.Len() != 0 {
+		t.Errorf("%d players are left in the queue", test.queue.Len())
+	}
+}
+
+func TestQueueRulesAndTiers(t *testing.T) {
+	test   := newQueue(t, 4, 0, 0)
+	kinds  := [][2]string{{"standard", "beginner"}, {"chengdu", "beginner"}, {"standard", "standard"}}
+	joined := make(map[string][2]string)
+	for _, kind := range kinds {
+		for _, name := range []string{"a", "b"} {
+			uuid := test.player(kind[0] + "/" + kind[1] + "/" + name, InitialRating)
+			if !test.queue.Join(uuid, kind[0], kind[1]) {
+				t.Fatalf("can't join %v", kind)
+			}
+			joined[uuid] = kind
+		}
+	}
+	if formed, ok := test.table(50 * time.Millisecond); ok {
+		t.Fatalf("a table %v is formed by different rules or tiers", formed)
+	}
+	for _, name := range []string{"c", "d"} {
+		uuid := test.player("standard/beginner/" + name, InitialRating)
+		test.queue.Join(uuid, "standard", "beginner")
+		joined[uuid] = kinds[0]
+	}
+	formed, ok := test.table(time.Second)
+	if !ok || formed.rules != "standard" || formed.tier != "beginner" {
+		t.Fatalf("formed %v, want a standard beginner table", formed)
+	}
+	for _, uuid := range formed.players {
+		if joined[uuid] != kinds[0] {
+			t.Errorf("a player of %v sits at the table", joined[uuid])
+		}
+	}
+	if test.queue.Len() != 4 {
+		t.Errorf("%d players are left in the queue, want 4", test.queue.Len())
+	}
+}
+
+func TestQueueRatingRange(t *testing.T) {
+	test             := newQueue(t, 4, 0, 0)
+	test.queue.Range  = 100
+	test.queue.Widen  = 200
+	for i, rating := range []float64{1500, 1500, 1520, 1650} {
+		test.queue.Join(test.player(string(rune('a' + i)), rating), "", "")
+	}
+	if formed, ok := test.table(rangeCheck / 2); ok {
+		t.Fatalf("a table %v is formed across a gap wider than the range", formed)
+	}
+	if formed, ok := test.table(3 * rangeCheck); !ok || len(formed.players) != 4 {
+		t.Fatalf("formed %v, %v after the range widens", formed, ok)
+	}
+}
+
+func TestQueueBotsFillTable(t *testing.T) {
+	test  := newQueue(t, 4, 50 * time.Millisecond, 0)
+	alice := test.player("alice", InitialRating)
+	bob   := test.player("bob", InitialRating)
+	test.queue.Join(alice, "", "")
+	test.queue.Join(bob, "", "")
+	formed, ok := test.table(time.Second)
+	if !ok || len(formed.players) != 2 {
+		t.Fatalf("formed %v, %v, want the two players and bots", formed, ok)
+	}
+	if test.state(alice) != MATCHED || test.state(bob) != MATCHED {
+		t.Error("the players aren't matched")
+	}
+}
+
+func TestQueueTimeout(t *testing.T) {
+	test  := newQueue(t, 4, 0, 50 * time.Millisecond)
+	alice := test.player("alice", InitialRating)
+	test.queue.Join(alice, "", "")
+	select {
+	case event := <-test.events:
+		if event.uuid != alice || event.event != "queueTimeout" {
+			t.Errorf("notified %v, want queueTimeout", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the player isn't told of the timeout")
+	}
+	if test.state(alice) != WAITING || test.queue.Len() != 0 {
+		t.Errorf("the player is in the state %d after the timeout", test.state(alice))
+	}
+}
+
+func TestQueueCancel(t *testing.T) {
+	test  := newQueue(t, 4, 0, 0)
+	alice := test.player("alice", InitialRating)
+	if !test.queue.Join(alice, "", "") || test.state(alice) != QUEUED {
+		t.Fatal("the player isn't queued")
+	}
+	if test.queue.Join(alice, "", "") || test.queue.Len() != 1 {
+		t.Error("the player joins twice")
+	}
+	if !test.queue.Cancel(alice) || test.state(alice) != WAITING || test.queue.Len() != 0 {
+		t.Errorf("the player is in the state %d after canceling", test.state(alice))
+	}
+	if test.queue.Cancel(alice) {
+		t.Error("a player who isn't queued cancels")
+	}
+	if !test.queue.Join(alice, "", "") {
+		t.Error("the player can't join again")
+	}
+}
@@ -0,0 +1,323 @@
+package mahjong
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"mahjong/engine"
+)
+
+// NewMemoryStore creates an empty store kept in memory, for the tests and
+// the servers which don't keep their data
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore {
+		accounts: make(map[string]Account),
+		sessions: make(map[string]LoginSession),
+		games:    make(map[string]*memoryGame),
+		matches:  make(map[string]MatchResult),
+		ratings:  make(map[string]Rating),
+		ledger:   newLedger(),
+	}
+}
+
+// MemoryStore is a store kept in memory, the records are copied in and out
+// so a caller never shares them with the store
+type MemoryStore struct {
+	mutex    sync.RWMutex
+	accounts map[string]Account
+	sessions map[string]LoginSession
+	games    map[string]*memoryGame
+	hands    []HandRecord
+	index    handIndex
+	matches  map[string]MatchResult
+	ratings  map[string]Rating
+	ledger   ledger
+	boards   []Board
+	archives []Board
+	revoked  []Revocation
+}
+
+type memoryGame struct {
+	header  LogHeader
+	records []LogRecord
+	result  []engine.GameResult
+}
+
+// Account returns the account of name
+func (store *MemoryStore) Account(name string) (Account, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	account, ok := store.accounts[name]
+	if !ok {
+		return Account{}, ErrNotFound
+	}
+	return account, nil
+}
+
+// PutAccount creates or replaces the account
+func (store *MemoryStore) PutAccount(account Account) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.accounts[account.Name] = account
+	return nil
+}
+
+// LoginSession returns the session of token
+func (store *MemoryStore) LoginSession(token string) (LoginSession, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	session, ok := store.sessions[token]
+	if !ok {
+		return LoginSession{}, ErrNotFound
+	}
+	return session, nil
+}
+
+// PutLoginSession creates or replaces the session, and drops the expired ones
+func (store *MemoryStore) PutLoginSession(session LoginSession) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.sessions[session.Token] = session
+	now := time.Now()
+	for token, session := range store.sessions {
+		if now.After(session.Expires) {
+			delete(store.sessions, token)
+		}
+	}
+	return nil
+}
+
+// DeleteLoginSession deletes the session of token
+func (store *MemoryStore) DeleteLoginSession(token string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.sessions, token)
+	return nil
+}
+
+// CreateGameLog creates the game log of the header
+func (store *MemoryStore) CreateGameLog(header LogHeader) (string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.games[header.GameID] = &memoryGame {header: header}
+	return "", nil
+}
+
+// AppendGameLog appends the record to the game log
+func (store *MemoryStore) AppendGameLog(gameID string, record LogRecord) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	game, ok := store.games[gameID]
+	if !ok {
+		return ErrNotFound
+	}
+	game.records = append(game.records, record)
+	return nil
+}
+
+// FinishGameLog appends the result to the game log
+func (store *MemoryStore) FinishGameLog(gameID string, result LogResult) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	game, ok := store.games[gameID]
+	if !ok {
+		return ErrNotFound
+	}
+	game.result = append([]engine.GameResult{}, result.Result...)
+	return nil
+}
+
+// GameLog returns the header, the records and the result of the game log,
+// the result is nil if the game isn't over
+func (store *MemoryStore) GameLog(gameID string) (LogHeader, []LogRecord, []engine.GameResult, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	game, ok := store.games[gameID]
+	if !ok {
+		return LogHeader{}, nil, nil, ErrNotFound
+	}
+	return game.header, append([]LogRecord{}, game.records...), game.result, nil
+}
+
+// PutHandRecord stores the record of a finished hand
+func (store *MemoryStore) PutHandRecord(record HandRecord) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.hands = append(store.hands, record)
+	store.index.add(record)
+	return nil
+}
+
+// HandRecords returns the page of the hands selected by the query
+func (store *MemoryStore) HandRecords(query HandQuery) (HandPage, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	query = query.normalize()
+	positions, total := store.index.query(query)
+	page := HandPage {total, query.Offset, []HandRecord{}}
+	for _, position := range positions {
+		page.Hands = append(page.Hands, store.hands[position])
+	}
+	return page, nil
+}
+
+// PutMatchResult stores the result of a match
+func (store *MemoryStore) PutMatchResult(result MatchResult) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	result.Hands = append([]HandSummary{}, result.Hands...)
+	store.matches[result.MatchID] = result
+	return nil
+}
+
+// MatchResult returns the result of the match
+func (store *MemoryStore) MatchResult(matchID string) (MatchResult, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	result, ok := store.matches[matchID]
+	if !ok {
+		return MatchResult{}, ErrNotFound
+	}
+	result.Hands = append([]HandSummary{}, result.Hands...)
+	return result, nil
+}
+
+// Ratings returns every rating with its history, in the order of the names
+func (store *MemoryStore) Ratings() ([]Rating, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	ratings := make([]Rating, 0, len(store.ratings))
+	for _, rating := range store.ratings {
+		rating.History = append([]RatingChange{}, rating.History...)
+		ratings = append(ratings, rating)
+	}
+	sort.Slice(ratings, func(i, j int) bool {
+		return ratings[i].Name < ratings[j].Name
+	})
+	return ratings, nil
+}
+
+// PutRatings stores the ratings and appends their changes to the history,
+// a rating without changes starts over
+func (store *MemoryStore) PutRatings(ratings []Rating) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for _, rating := range ratings {
+		changes := rating.History
+		rating.History = nil
+		if len(changes) > 0 {
+			rating.History = append(store.ratings[rating.Name].History, changes...)
+		}
+		store.ratings[rating.Name] = rating
+	}
+	return nil
+}
+
+// Balance returns the credit balance of the account
+func (store *MemoryStore) Balance(account string) (int, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.ledger.balances[account], nil
+}
+
+// PostSettlement posts the transactions of the settlement
+func (store *MemoryStore) PostSettlement(settlement Settlement) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if err := store.ledger.check(settlement); err != nil {
+		return err
+	}
+	store.ledger.post(settlement)
+	return nil
+}
+
+// Transactions returns the transactions of the account, the latest first
+func (store *MemoryStore) Transactions(account string, offset int, limit int) ([]Transaction, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.ledger.history(account, offset, limit), nil
+}
+
+// Leaderboards returns the boards of the periods in progress
+func (store *MemoryStore) Leaderboards() ([]Board, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return append([]Board{}, store.boards...), nil
+}
+
+// PutLeaderboards replaces the boards of the periods in progress
+func (store *MemoryStore) PutLeaderboards(boards []Board) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.boards = append([]Board{}, boards...)
+	return nil
+}
+
+// ArchiveBoard archives the final standings of a period, it does nothing if
+// the board is archived
+func (store *MemoryStore) ArchiveBoard(board Board) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if !store.archived(board) {
+		store.archives = append(store.archives, board)
+	}
+	return nil
+}
+
+// ArchivedBoards returns the boards of the period in the order archived
+func (store *MemoryStore) ArchivedBoards(period string) ([]Board, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	result := []Board{}
+	for _, board := range store.archives {
+		if board.Period == period {
+			result = append(result, board)
+		}
+	}
+	return result, nil
+}
+
+// archived is called with the mutex locked
+func (store *MemoryStore) archived(board Board) bool {
+	for _, archive := range store.archives {
+		if archive.Period == board.Period && archive.ID == board.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// Revocations returns the revocations which haven't expired
+func (store *MemoryStore) Revocations() ([]Revocation, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	now    := time.Now().UnixNano()
+	result := []Revocation{}
+	for _, revocation := range store.revoked {
+		if now < revocation.Expires {
+			result = append(result, revocation)
+		}
+	}
+	return result, nil
+}
+
+// PutRevocation stores the revocation, and drops the expired ones
+func (store *MemoryStore) PutRevocation(revocation Revocation) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	now  := time.Now().UnixNano()
+	kept := store.revoked[:0]
+	for _, revoked := range store.revoked {
+		if now < revoked.Expires {
+			kept = append(kept, revoked)
+		}
+	}
+	store.revoked = append(kept, revocation)
+	return nil
+}
+
+// Close does nothing, the data is gone with the store
+func (store *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,74 @@
+package mahjong
+
+import (
+	"mahjong/engine"
+)
+
+// PlayerAgent makes the decisions of a seat
+type PlayerAgent interface {
+	ChangeTiles(game engine.GameState, seat int, defaultTiles []engine.Tile) []engine.Tile
+	ChooseLack(game engine.GameState, seat int) int
+	Throw(game engine.GameState, seat int, defaultTile engine.Tile) engine.Tile
+	Command(game engine.GameState, seat int, actionSet engine.ActionSet, command int) engine.Action
+}
+
+// NewChannelAgent creates a new channel agent
+func NewChannelAgent() *ChannelAgent {
+	return &ChannelAgent {Prompt: make(chan Prompt)}
+}
+
+// ChannelAgent is a player agent which sends every decision to Prompt
+// and waits for the reply, it's used to seat a program or a test
+type ChannelAgent struct {
+	Prompt chan Prompt
+}
+
+// Prompt represents a decision asked by a channel agent, Event is the
+// name of the socket event asking the same decision
+type Prompt struct {
+	Event     string
+	Game      engine.GameState
+	Seat      int
+	Legal     []engine.Move
+	ActionSet engine.ActionSet
+	Command   int
+	Reply     chan engine.Move
+}
+
+// ChangeTiles asks the change tiles
+func (agent *ChannelAgent) ChangeTiles(game engine.GameState, seat int, defaultTiles []engine.Tile) []engine.Tile {
+	return agent.ask("change", game, seat, nil, 0).Tiles
+}
+
+// ChooseLack asks the lack
+func (agent *ChannelAgent) ChooseLack(game engine.GameState, seat int) int {
+	return agent.ask("lack", game, seat, nil, 0).Lack
+}
+
+// Throw asks the throw tile
+func (agent *ChannelAgent) Throw(game engine.GameState, seat int, defaultTile engine.Tile) engine.Tile {
+	return agent.ask("throw", game, seat, nil, 0).Tile
+}
+
+// Command asks the command
+func (agent *ChannelAgent) Command(game engine.GameState, seat int, actionSet engine.ActionSet, command int) engine.Action {
+	move := agent.ask("command", game, seat, actionSet, command)
+	return engine.NewAction(move.Command, move.Tile, 0)
+}
+
+func (agent *ChannelAgent) ask(event string, game engine.GameState, seat int, actionSet engine.ActionSet, command int) engine.Move {
+	var legal []engine.Move
+	for _, move := range engine.LegalActions(game, seat) {
+		if event == "throw" && move.Type != engine.MoveThrow || event == "command" && move.Type != engine.MoveCommand {
+			continue
+		}
+		legal = append(legal, move)
+	}
+	if event == "command" && game.Step == engine.StepDraw {
+		legal = append(legal, engine.NewCommandMove(seat, engine.COMMAND["NONE"], engine.NewTile(-1, 0)))
+	}
+
+	reply := make(chan engine.Move, 1)
+	agent.Prompt <- Prompt {event, game, seat, legal, actionSet, command, reply}
+	return <-reply
+}
@@ -1,6 +1,9 @@
 package mahjong
 
 import (
+	"sort"
+	"sync"
+
 	"github.com/googollee/go-socket.io"
 	"github.com/satori/go.uuid"
 )
@@ -12,26 +15,53 @@ const (
 	READY   = 2
 	PLAYING = 4
 	LEAVE   = 8
+	QUEUED  = 16
 )
 
-// IPlayer represents the player's info
+// IPlayer represents the player's info, Prompts is the dispatcher of the
+// player's connection
 type IPlayer struct {
-	Name   string
-	UUID   string
-	Room   string
-	Socket *socketio.Socket
-	State  int
-	Index  int
+	Name    string
+	UUID    string
+	Room    string
+	Socket  *socketio.Socket
+	Prompts *Dispatcher
+	State   int
+	Index   int
+	joined  uint64
+}
+
+// NewPlayerManager creates an empty player registry
+func NewPlayerManager() *PlayerManager {
+	return &PlayerManager {
+		byUUID:   make(map[string]*IPlayer),
+		byName:   make(map[string]*IPlayer),
+		bySocket: make(map[string]*IPlayer),
+		byRoom:   make(map[string]map[string]*IPlayer),
+		byState:  make(map[int]map[string]*IPlayer),
+	}
 }
 
-// PlayerManager represents the array of pointer of IPlayer
-type PlayerManager []*IPlayer
+// PlayerManager is the registry of the players, it's safe for concurrent use
+//
+// The players are indexed by UUID, name, socket ID, room and state. A lookup
+// returns a copy of the player's info, and the info is changed by Update so
+// that the indexes are kept. A list of players is in the order they joined
+type PlayerManager struct {
+	mutex    sync.RWMutex
+	joined   uint64
+	byUUID   map[string]*IPlayer
+	byName   map[string]*IPlayer
+	bySocket map[string]*IPlayer
+	byRoom   map[string]map[string]*IPlayer
+	byState  map[int]map[string]*IPlayer
+}
 
-// PlayerList is a PlayerManager
-var PlayerList PlayerManager
+// PlayerList is the registry of the players
+var PlayerList = NewPlayerManager()
 
 // GetNameList returns the list of player's name
-func GetNameList(list []*IPlayer) []string {
+func GetNameList(list []IPlayer) []string {
 	var nameList []string
 	for _, player := range list {
 		nameList = append(nameList, player.Name)
@@ -48,93 +78,138 @@ func GetUUIDList(list []IPlayer) []string {
 	return uuidList
 }
 
-// AddPlayer adds a new player into PlayerManager
-func AddPlayer(name string) (string, bool) {
-	if FindPlayerByName(name) != -1 {
+// Add adds a new player and returns the uuid, it fails if the name is used
+func (manager *PlayerManager) Add(name string) (string, bool) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	if manager.byName[name] != nil {
 		return "", true
 	}
 	var _uuid string
 	for {
 		_uuid = uuid.Must(uuid.NewV4()).String()
-		if FindPlayerByUUID(_uuid) == -1 {
+		if manager.byUUID[_uuid] == nil {
 			break
 		}
 	}
-	PlayerList = append(PlayerList, &IPlayer {name, _uuid, "", nil, WAITING, -1})
+	manager.joined++
+	manager.index(&IPlayer {name, _uuid, "", nil, nil, WAITING, -1, manager.joined})
 	return _uuid, false
 }
 
-// RemovePlayer remove a player from PlayerList
-func RemovePlayer(id int) {
-	if id >= 0 && id < len(PlayerList) {
-		PlayerList = append((PlayerList)[: id], (PlayerList)[id + 1: ]...)
+// Remove removes the player of uuid
+func (manager *PlayerManager) Remove(uuid string) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	if player := manager.byUUID[uuid]; player != nil {
+		manager.unindex(player)
 	}
 }
 
-// FindPlayerByName gets player's index by player's name
-func FindPlayerByName(name string) int {
-	for index, player := range PlayerList {
-		if player.Name == name {
-			return index
-		}
+// Get returns the player of uuid
+func (manager *PlayerManager) Get(uuid string) (IPlayer, bool) {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+	return copyPlayer(manager.byUUID[uuid])
+}
+
+// GetByName returns the player of name
+func (manager *PlayerManager) GetByName(name string) (IPlayer, bool) {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+	return copyPlayer(manager.byName[name])
+}
+
+// GetBySocket returns the player of the socket
+func (manager *PlayerManager) GetBySocket(socket socketio.Socket) (IPlayer, bool) {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+	return copyPlayer(manager.bySocket[socket.Id()])
+}
+
+// InRoom returns the players in the room
+func (manager *PlayerManager) InRoom(room string) []IPlayer {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+	return sortPlayers(manager.byRoom[room])
+}
+
+// InState returns the players of the state
+func (manager *PlayerManager) InState(state int) []IPlayer {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+	return sortPlayers(manager.byState[state])
+}
+
+// CountState returns the number of players of the state
+func (manager *PlayerManager) CountState(state int) int {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+	return len(manager.byState[state])
+}
+
+// Update changes the info of the player of uuid by fn, it returns false if
+// there is no such player. The UUID and the name can't be changed. fn is
+// called with the registry locked, so it must not call the registry or take
+// another lock, such as the match queue's
+func (manager *PlayerManager) Update(uuid string, fn func(player *IPlayer)) bool {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	player := manager.byUUID[uuid]
+	if player == nil {
+		return false
 	}
-	return -1
+	name := player.Name
+	manager.unindex(player)
+	fn(player)
+	player.UUID, player.Name = uuid, name
+	manager.index(player)
+	return true
 }
 
-// FindPlayerByUUID gets player's index by player's uuid
-func FindPlayerByUUID(uuid string) int {
-	for index, player := range PlayerList {
-		if player.UUID == uuid {
-			return index
-		}
+func (manager *PlayerManager) index(player *IPlayer) {
+	manager.byUUID[player.UUID] = player
+	manager.byName[player.Name] = player
+	if player.Socket != nil {
+		manager.bySocket[(*player.Socket).Id()] = player
 	}
-	return -1
+	if manager.byRoom[player.Room] == nil {
+		manager.byRoom[player.Room] = make(map[string]*IPlayer)
+	}
+	manager.byRoom[player.Room][player.UUID] = player
+	if manager.byState[player.State] == nil {
+		manager.byState[player.State] = make(map[string]*IPlayer)
+	}
+	manager.byState[player.State][player.UUID] = player
 }
 
-// FindPlayerBySocket gets player's index by player's socket
-func FindPlayerBySocket(socket socketio.Socket) int {
-	for index, player := range PlayerList {
-		if (*player.Socket).Id() == socket.Id() {
-			return index
-		}
+func (manager *PlayerManager) unindex(player *IPlayer) {
+	delete(manager.byUUID, player.UUID)
+	delete(manager.byName, player.Name)
+	if player.Socket != nil && manager.bySocket[(*player.Socket).Id()] == player {
+		delete(manager.bySocket, (*player.Socket).Id())
 	}
-	return -1
-}
-
-// FindPlayerListInRoom gets list of player which in the same room
-func FindPlayerListInRoom(room string) []*IPlayer {
-	var list []*IPlayer
-	for _, player := range PlayerList {
-		if player.Room == room {
-			list = append(list, player)
-			if len(list) == 4 {
-				break
-			}
-		}
+	delete(manager.byRoom[player.Room], player.UUID)
+	if len(manager.byRoom[player.Room]) == 0 {
+		delete(manager.byRoom, player.Room)
 	}
-	return list
+	delete(manager.byState[player.State], player.UUID)
 }
 
-// FindPlayerListIsSameState gets list of player which are same state
-func FindPlayerListIsSameState(state int) []*IPlayer {
-	var list []*IPlayer
-	for _, player := range PlayerList {
-		if player.State == state {
-			list = append(list, player)
-			if len(list) == 4 {
-				break
-			}
-		}
+func copyPlayer(player *IPlayer) (IPlayer, bool) {
+	if player == nil {
+		return IPlayer{}, false
 	}
-	return list
+	return *player, true
 }
 
-// Auth authenticates a player
-func Auth(room string, uuid string) bool {
-	for _, player := range PlayerList {
-		if player.Room == room && player.UUID == uuid {
-			return true
-		}
+func sortPlayers(players map[string]*IPlayer) []IPlayer {
+	list := make([]IPlayer, 0, len(players))
+	for _, player := range players {
+		list = append(list, *player)
 	}
-	return false
-}
\ No newline at end of file
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].joined < list[j].joined
+	})
+	return list
+}
@@ -0,0 +1,152 @@
+package mahjong
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Ratings are the ratings of the players, nil disables rating
+var Ratings *RatingBook
+
+// Rating model, a new player starts at InitialRating and RatingK is the
+// most a hand can change a rating
+const (
+	InitialRating = 1500.0
+	RatingK       = 32.0
+)
+
+// Rating represents the rating of a player and its history
+type Rating struct {
+	Name    string
+	Rating  float64
+	Hands   int
+	History []RatingChange
+}
+
+// RatingChange represents the change of a rating by a hand
+type RatingChange struct {
+	GameID string
+	Time   time.Time
+	Score  int
+	Delta  float64
+	Rating float64
+}
+
+// LoadRatings loads the ratings kept in the store
+func LoadRatings(store RatingStore) (*RatingBook, error) {
+	book := &RatingBook {store: store, ratings: make(map[string]*Rating)}
+	ratings, err := store.Ratings()
+	if err != nil {
+		return book, err
+	}
+	for i := range ratings {
+		book.ratings[ratings[i].Name] = &ratings[i]
+	}
+	return book, nil
+}
+
+// RatingBook keeps the ratings of the players by name, it's safe for
+// concurrent use and stores the changes after every update
+type RatingBook struct {
+	mutex   sync.RWMutex
+	store   RatingStore
+	ratings map[string]*Rating
+}
+
+// Get returns the rating of the player without the history, a player
+// who never played has InitialRating
+func (book *RatingBook) Get(name string) Rating {
+	book.mutex.RLock()
+	defer book.mutex.RUnlock()
+	rating, ok := book.ratings[name]
+	if !ok {
+		return Rating {Name: name, Rating: InitialRating}
+	}
+	return Rating {rating.Name, rating.Rating, rating.Hands, nil}
+}
+
+// History returns the last limit changes of the player's rating, the
+// latest first, limit <= 0 returns all of them
+func (book *RatingBook) History(name string, limit int) []RatingChange {
+	book.mutex.RLock()
+	defer book.mutex.RUnlock()
+	result := []RatingChange{}
+	if rating, ok := book.ratings[name]; ok {
+		for i := len(rating.History) - 1; i >= 0 && (limit <= 0 || len(result) < limit); i-- {
+			result = append(result, rating.History[i])
+		}
+	}
+	return result
+}
+
+// Forget forgets the rating and the history of the player, who starts at
+// InitialRating again
+func (book *RatingBook) Forget(name string) error {
+	book.mutex.Lock()
+	defer book.mutex.Unlock()
+	if _, ok := book.ratings[name]; !ok {
+		return nil
+	}
+	delete(book.ratings, name)
+	return book.store.PutRatings([]Rating {{Name: name, Rating: InitialRating}})
+}
+
+// Update rates a hand from the credits of the four seats, only the rated
+// seats are updated and the others count with InitialRating. It returns
+// the changes of the rated seats
+func (book *RatingBook) Update(gameID string, names [4]string, rated [4]bool, scores [4]int) ([4]RatingChange, error) {
+	book.mutex.Lock()
+	defer book.mutex.Unlock()
+	var ratings [4]float64
+	for i := 0; i < 4; i++ {
+		ratings[i] = InitialRating
+		if rating, ok := book.ratings[names[i]]; ok && rated[i] {
+			ratings[i] = rating.Rating
+		}
+	}
+	deltas := eloDeltas(ratings, scores, RatingK)
+	var changes [4]RatingChange
+	var updated []Rating
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		if !rated[i] {
+			continue
+		}
+		rating, ok := book.ratings[names[i]]
+		if !ok {
+			rating = &Rating {Name: names[i], Rating: InitialRating}
+			book.ratings[names[i]] = rating
+		}
+		rating.Rating += deltas[i]
+		rating.Hands++
+		changes[i] = RatingChange {gameID, now, scores[i], deltas[i], rating.Rating}
+		rating.History = append(rating.History, changes[i])
+		updated        = append(updated, Rating {rating.Name, rating.Rating, rating.Hands, []RatingChange {changes[i]}})
+	}
+	return changes, book.store.PutRatings(updated)
+}
+
+// eloDeltas rates a four-player hand as the six pairwise games between the
+// seats, a seat wins against another if it gets more credits. A seat's delta
+// is k times the sum of its results minus the expected ones, divided by the
+// number of its opponents, so the deltas always sum to 0
+func eloDeltas(ratings [4]float64, scores [4]int, k float64) [4]float64 {
+	var deltas [4]float64
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			if i == j {
+				continue
+			}
+			expected := 1 / (1 + math.Pow(10, (ratings[j] - ratings[i]) / 400))
+			result   := 0.5
+			if scores[i] > scores[j] {
+				result = 1
+			} else if scores[i] < scores[j] {
+				result = 0
+			}
+			deltas[i] += k * (result - expected) / 3
+		}
+	}
+	return deltas
+}
@@ -0,0 +1,118 @@
+package mahjong
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestEloDeltas(t *testing.T) {
+	cases := []struct {
+		name    string
+		ratings [4]float64
+		scores  [4]int
+		deltas  [4]float64
+	}{
+		{"tie",        [4]float64{1500, 1500, 1500, 1500}, [4]int{0, 0, 0, 0},   [4]float64{0, 0, 0, 0}},
+		{"pair tie",   [4]float64{1500, 1500, 1500, 1500}, [4]int{2, 2, -2, -2}, [4]float64{32.0 / 3, 32.0 / 3, -32.0 / 3, -32.0 / 3}},
+		{"one winner", [4]float64{1500, 1500, 1500, 1500}, [4]int{6, -2, -2, -2}, [4]float64{16, -16.0 / 3, -16.0 / 3, -16.0 / 3}},
+		{"ranked",     [4]float64{1500, 1500, 1500, 1500}, [4]int{3, 1, -1, -3},  [4]float64{16, 16.0 / 3, -16.0 / 3, -16}},
+	}
+	for _, c := range cases {
+		deltas := eloDeltas(c.ratings, c.scores, RatingK)
+		for i := range deltas {
+			if math.Abs(deltas[i] - c.deltas[i]) > 1e-9 {
+				t.Errorf("%s: deltas %v, want %v", c.name, deltas, c.deltas)
+				break
+			}
+		}
+	}
+
+	uneven := [][4]float64{{1900, 1500, 1500, 1500}, {1900, 1100, 1500, 1700}, {2400, 800, 1500, 1501}}
+	for _, ratings := range uneven {
+		for _, scores := range [][4]int{{-3, 1, 1, 1}, {10, -5, -3, -2}, {0, 0, 0, 0}} {
+			deltas := eloDeltas(ratings, scores, RatingK)
+			sum    := 0.0
+			for _, delta := range deltas {
+				sum += delta
+				if math.Abs(delta) > RatingK {
+					t.Errorf("%v %v: delta %v is more than K", ratings, scores, delta)
+				}
+			}
+			if math.Abs(sum) > 1e-9 {
+				t.Errorf("%v %v: deltas %v sum to %v", ratings, scores, deltas, sum)
+			}
+		}
+	}
+	if upset := eloDeltas(uneven[0], [4]int{-3, 1, 1, 1}, RatingK); upset[0] > -RatingK * 0.9 {
+		t.Errorf("the favourite loses %v by an upset, want nearly K", -upset[0])
+	}
+	if expected := eloDeltas(uneven[0], [4]int{3, -1, -1, -1}, RatingK); expected[0] > RatingK * 0.1 {
+		t.Errorf("the favourite wins %v by an expected win, want little", expected[0])
+	}
+}
+
+func TestRatingUpdate(t *testing.T) {
+	book, _ := LoadRatings(NewMemoryStore())
+	names   := [4]string{"a", "b", "c", BotName(1)}
+	rated   := [4]bool{true, true, true, false}
+	for i := 0; i < 3; i++ {
+		if _, err := book.Update(fmt.Sprint("hand-", i), names, rated, [4]int{4, -1, -1, -2}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rating := book.Get("a"); rating.Hands != 3 || rating.Rating <= InitialRating {
+		t.Errorf("rating %+v after three wins", rating)
+	}
+	if rating := book.Get(BotName(1)); rating.Hands != 0 || rating.Rating != InitialRating {
+		t.Errorf("the unrated seat has %+v", rating)
+	}
+
+	history := book.History("a", 2)
+	if len(history) != 2 || history[0].GameID != "hand-2" || history[1].GameID != "hand-1" {
+		t.Fatalf("history %v, want hand-2 and hand-1", history)
+	}
+	if history[0].Rating != book.Get("a").Rating || history[1].Rating + history[0].Delta != history[0].Rating {
+		t.Errorf("the changes %v don't add up to the rating", history)
+	}
+	if all := book.History("a", 0); len(all) != 3 || all[2].GameID != "hand-0" {
+		t.Errorf("the whole history is %v", all)
+	}
+	if none := book.History("nobody", 5); len(none) != 0 {
+		t.Errorf("a player who never played has the history %v", none)
+	}
+}
+
+func TestRatingJournal(t *testing.T) {
+	dir     := t.TempDir()
+	book, _ := LoadRatings(openStore(t, dir))
+	names   := [4]string{"a", "b", "c", "d"}
+	for i := 0; i < 5; i++ {
+		if _, err := book.Update(fmt.Sprint("hand-", i), names, [4]bool{true, true, true, true}, [4]int{i, -i, 1, -1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := book.Forget("d"); err != nil {
+		t.Fatal(err)
+	}
+	want := book.Get("a")
+
+	// the snapshot of the fourth hand, as if the store stopped before the fifth
+	if err := writeJSON(filepath.Join(dir, ratingsFile), []Rating {{"a", 1234, 4, nil}}); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadRatings(openStore(t, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.Get("a"); got.Rating != want.Rating || got.Hands != want.Hands {
+		t.Errorf("reloaded rating %+v, want %+v", got, want)
+	}
+	if history := reloaded.History("b", 0); len(history) != 5 || history[0].GameID != "hand-4" {
+		t.Errorf("reloaded history %v", history)
+	}
+	if got := reloaded.Get("d"); got.Hands != 0 || got.Rating != InitialRating || len(reloaded.History("d", 0)) != 0 {
+		t.Errorf("the forgotten player has %+v", got)
+	}
+}
@@ -0,0 +1,170 @@
+package mahjong
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"mahjong/engine"
+)
+
+// NewReplayer creates a replayer of the game log in the store
+func NewReplayer(store GameStore, gameID string) (*Replayer, error) {
+	header, records, result, err := store.GameLog(gameID)
+	if err != nil {
+		return nil, err
+	}
+	states := []engine.GameState{engine.NewGameState(header.Names, header.Seed, header.Rules, header.Dealer)}
+	if header.Stake > 0 {
+		states[0].SetStake(header.Stake, header.Balances)
+	}
+	for _, record := range records {
+		states = append(states, engine.ApplyEvent(states[len(states) - 1], record.Event))
+	}
+	return &Replayer {Header: header, Records: records, Result: result, states: states}, nil
+}
+
+// Replayer rebuilds the game state at any event index of a game log,
+// Index is the amount of events applied
+type Replayer struct {
+	Header  LogHeader
+	Records []LogRecord
+	Result  []engine.GameResult
+	Index   int
+	states  []engine.GameState
+}
+
+// ReplayView represents the game state at an event index seen by a seat
+type ReplayView struct {
+	Index   int
+	Total   int
+	Event   *LogRecord
+	Phase   int
+	Current int
+	Remain  int
+	Seats   []SeatView
+}
+
+// SeatView represents a seat seen by a seat, Hand is empty if it's hidden
+type SeatView struct {
+	Name         string
+	Hand         []string
+	HandCount    int
+	Door         []string
+	HiddenDoor   int
+	DiscardTiles []string
+	HuTiles      []string
+	Lack         int
+	Credit       int
+	ScoreLog     []engine.ScoreRecord
+}
+
+// Len returns the amount of events in the game log
+func (replayer *Replayer) Len() int {
+	return len(replayer.Records)
+}
+
+// State returns the whole game state at the current index
+func (replayer *Replayer) State() engine.GameState {
+	return replayer.states[replayer.Index]
+}
+
+// Seek moves to the state after index events applied
+func (replayer *Replayer) Seek(index int) engine.GameState {
+	if index < 0 {
+		index = 0
+	} else if index > replayer.Len() {
+		index = replayer.Len()
+	}
+	replayer.Index = index
+	return replayer.State()
+}
+
+// Forward applies the next event, it returns false at the end of game log
+func (replayer *Replayer) Forward() bool {
+	if replayer.Index >= replayer.Len() {
+		return false
+	}
+	replayer.Index++
+	return true
+}
+
+// Backward reverts the last applied event, it returns false at the beginning of game log
+func (replayer *Replayer) Backward() bool {
+	if replayer.Index <= 0 {
+		return false
+	}
+	replayer.Index--
+	return true
+}
+
+// Verify checks the final state rebuilt from the events against the game result in the game log
+func (replayer *Replayer) Verify() error {
+	if replayer.Result == nil {
+		return errors.New("game isn't over")
+	}
+	final := replayer.states[replayer.Len()].Result()
+	if len(final) != len(replayer.Result) {
+		return errors.New("amount of result mismatch")
+	}
+	for i := range final {
+		if !reflect.DeepEqual(final[i], replayer.Result[i]) {
+			return fmt.Errorf("result of seat %d mismatch", i)
+		}
+	}
+	return nil
+}
+
+// View returns the state at the current index seen by the seat, seat -1 sees everything
+func (replayer *Replayer) View(seat int) ReplayView {
+	state := replayer.State()
+	view  := ReplayView {
+		Index:   replayer.Index,
+		Total:   replayer.Len(),
+		Phase:   state.Phase,
+		Current: state.Current,
+		Remain:  state.RemainCount(),
+	}
+	if replayer.Index > 0 {
+		record    := replayer.Records[replayer.Index - 1]
+		record.Event = maskEvent(record.Event, seat)
+		view.Event   = &record
+	}
+	for i, s := range state.Seats {
+		seatView := SeatView {
+			Name:         s.Name,
+			HandCount:    int(s.Hand.Count()),
+			Door:         s.VisiableDoor.ToStringArray(),
+			HiddenDoor:   int(s.Door.Count() - s.VisiableDoor.Count()),
+			DiscardTiles: s.DiscardTiles.ToStringArray(),
+			HuTiles:      s.HuTiles.ToStringArray(),
+			Lack:         s.Lack,
+			Credit:       s.Credit,
+			ScoreLog:     s.ScoreLog,
+		}
+		if seat == -1 || seat == i {
+			seatView.Hand       = s.Hand.ToStringArray()
+			seatView.Door       = s.Door.ToStringArray()
+			seatView.HiddenDoor = 0
+		}
+		view.Seats = append(view.Seats, seatView)
+	}
+	return view
+}
+
+func maskEvent(event engine.Event, seat int) engine.Event {
+	if seat == -1 || seat == event.Seat {
+		return event
+	}
+	switch event.Type {
+	case engine.EventWall, engine.EventDeal, engine.EventChange, engine.EventAfterChange:
+		event.Tiles = nil
+	case engine.EventDraw:
+		event.Tile = engine.NewTile(-1, 0)
+	case engine.EventCommand:
+		if event.Command == engine.COMMAND["ONGON"] {
+			event.Tile = engine.NewTile(-1, 0)
+		}
+	}
+	return event
+}
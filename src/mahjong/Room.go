@@ -1,100 +1,173 @@
 package mahjong
 
 import (
+	"math/rand"
+	"sync"
 	"time"
 
 	socketio "github.com/googollee/go-socket.io"
+
+	"mahjong/engine"
 )
 
 // NewRoom creates a new room
 func NewRoom(name string) *Room {
-	return &Room {Name: name, Waiting: false, State: BeforeStart}
+	return &Room {Name: name, State: BeforeStart, Seed: rand.Int63(), Rules: DefaultRules, ready: make(chan readyRequest), done: make(chan struct{})}
 }
 
-// Room represents a round of mahjong
+// Room represents a round of mahjong, the zero Tier plays a unit of score
+// for a credit without a limit. Only the goroutine which runs the room
+// changes Players, Game and State and it does so under the mutex, which the
+// getters of other goroutines read them under
 type Room struct {
-	Players      []*Player
-	ChangedTiles [4][]Tile
-	ChoosedLack  [4]int
-	Deck         SuitSet
-	HuTiles      SuitSet
-	Waiting      bool
-	IO           *socketio.Server
-	Name         string
-	State        int
+	Players  []*Player
+	Game     engine.GameState
+	IO       *socketio.Server
+	Name     string
+	State    int
+	Seed     int64
+	Rules    engine.RuleSet
+	Tier     Tier
+	Session  *Session
+	GameID   string
+	Header   LogHeader
+	Log      *GameLog
+	accounts [4]string
+	leaving  []int
+	ready    chan readyRequest
+	done     chan struct{}
+	mutex    sync.RWMutex
 }
 
 // NumPlayer returns the number of player in the room
-func (room Room) NumPlayer() int {
-	list := FindPlayerListInRoom(room.Name)
+func (room *Room) NumPlayer() int {
+	list := PlayerList.InRoom(room.Name)
 	num  := 0
 	for _, player := range list {
 		if (player.State & (READY | PLAYING)) != 0 {
 			num++
 		}
 	}
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
+	for _, player := range room.Players {
+		if !player.IsHuman() {
+			num++
+		}
+	}
 	return num
 }
 
+// HasHuman returns if there is any human in the room
+func (room *Room) HasHuman() bool {
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
+	for _, player := range room.Players {
+		if player.IsHuman() {
+			return true
+		}
+	}
+	return false
+}
+
+// AddAgent seats a player agent which isn't a human into this room
+func (room *Room) AddAgent(name string, agent PlayerAgent) int {
+	idx := room.NumPlayer()
+	room.mutex.Lock()
+	room.Players = append(room.Players, NewAgentPlayer(room, idx, name, agent))
+	room.mutex.Unlock()
+	room.BroadcastReady(name)
+	return idx
+}
+
 // AddPlayer adds 4 player into this room
 func (room *Room) AddPlayer(playerList []string) {
 	for _, uuid := range playerList {
-		index := FindPlayerByUUID(uuid)
-		PlayerList[index].Room = room.Name
+		PlayerList.Update(uuid, func(player *IPlayer) {
+			player.Room = room.Name
+		})
 	}
-	playerLsit := FindPlayerListInRoom(room.Name)
+	playerLsit := PlayerList.InRoom(room.Name)
 	nameList   := GetNameList(playerLsit)
+	room.mutex.RLock()
+	for _, player := range room.Players {
+		if !player.IsHuman() {
+			nameList = append(nameList, player.Name())
+		}
+	}
+	room.mutex.RUnlock()
 	for _, player := range playerLsit {
-		(*player.Socket).Emit("readyToStart", room.Name, nameList)
+		if player.Socket != nil {
+			(*player.Socket).Emit("readyToStart", room.Name, nameList)
+		}
 	}
 }
 
 // RemovePlayer reomves id th player from this room
 func (room *Room) RemovePlayer(id int) {
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
 	room.Players = append(room.Players[:id], room.Players[id+1:]...)
 }
 
-// WaitToStart checks if all player in this room are ready
-// and run the mahjong logic
-func (room *Room) WaitToStart() {
-	room.Waiting = true
-	for room.NumPlayer() < 4 && room.Waiting {
-		time.Sleep(2 * time.Second)
-	}
+// ReadyTimeout is how long a room waits for the matched players to be ready
+var ReadyTimeout = 30 * time.Second
+
+type readyRequest struct {
+	uuid  string
+	reply chan int
+}
 
-	if !room.Waiting {
-		return
+// WaitToStart seats the players when they are ready and runs the mahjong
+// logic, it returns false if they aren't all ready in ReadyTimeout
+func (room *Room) WaitToStart() bool {
+	defer close(room.done)
+	timer := time.NewTimer(ReadyTimeout)
+	defer timer.Stop()
+	for room.NumPlayer() < 4 {
+		select {
+		case request := <-room.ready:
+			request.reply <- room.seat(request.uuid)
+		case <-timer.C:
+			room.StopWaiting()
+			return false
+		}
 	}
-	room.Waiting = false
 	room.BroadcastGameStart()
 	room.Run()
+	return true
 }
 
 // StopWaiting stops waiting
 func (room *Room) StopWaiting() {
 	room.BroadcastStopWaiting()
-	room.Waiting = false
-	for i := 0; i < room.NumPlayer(); i++ {
-		room.Players[i] = nil
-	}
 }
 
-// Accept checks player's info and constructs the player
+// Accept seats the player of uuid who is ready, callback gets the player's
+// id or -1 if the player can't be seated
 func (room *Room) Accept(uuid string, callback func(int)) {
-	if !room.Waiting {
+	reply := make(chan int, 1)
+	select {
+	case room.ready <- readyRequest {uuid, reply}:
+		callback(<-reply)
+	case <-room.done:
 		callback(-1)
-		return
 	}
-	index := FindPlayerByUUID(uuid)
-	if index == -1 {
-		callback(-1)
-		return
+}
+
+func (room *Room) seat(uuid string) int {
+	player, ok := PlayerList.Get(uuid)
+	if !ok || player.Room != room.Name || player.State != MATCHED {
+		return -1
 	}
-	player := PlayerList[index]
-	idx    := room.NumPlayer()
+	idx := room.NumPlayer()
 	room.BroadcastReady(player.Name)
-	callback(idx)
-	player.Index = idx
+	room.mutex.Lock()
 	room.Players = append(room.Players, NewPlayer(room, idx, player.UUID))
-	PlayerList[index].State = READY
+	room.mutex.Unlock()
+	PlayerList.Update(uuid, func(player *IPlayer) {
+		player.Index = idx
+		player.State = READY
+	})
+	return idx
 }
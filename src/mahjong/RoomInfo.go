@@ -1,7 +1,9 @@
 package mahjong
 
 // GetPlayerList returns the list of player's name
-func (room Room) GetPlayerList() []string {
+func (room *Room) GetPlayerList() []string {
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
 	var nameList []string
 	for _, player := range room.Players {
 		nameList = append(nameList, player.Name())
@@ -10,7 +12,9 @@ func (room Room) GetPlayerList() []string {
 }
 
 // GetReadyPlayers returns the name list of ready player
-func (room Room) GetReadyPlayers() []string {
+func (room *Room) GetReadyPlayers() []string {
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
 	var nameList []string
 	for _, player := range room.Players {
 		nameList = append(nameList, player.Name())
@@ -19,82 +23,96 @@ func (room Room) GetReadyPlayers() []string {
 }
 
 // GetLack returns each player's lack
-func (room Room) GetLack() []int {
+func (room *Room) GetLack() []int {
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
 	if room.State < ChooseLack {
 		return []int{}
 	}
 	var lacks []int
-	for _, player := range room.Players {
-		lacks = append(lacks, player.Lack)
+	for _, seat := range room.Game.Seats {
+		lacks = append(lacks, seat.Lack)
 	}
 	return lacks
 }
 
 // GetHandCount returns each player's amount of hand
-func (room Room) GetHandCount() []int {
+func (room *Room) GetHandCount() []int {
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
 	if room.State < IdxTurn {
 		return []int{}
 	}
 	var amounts []int
-	for _, player := range room.Players {
-		amounts = append(amounts, int(player.Hand.Count()))
+	for _, seat := range room.Game.Seats {
+		amounts = append(amounts, int(seat.Hand.Count()))
 	}
 	return amounts
 }
 
 // GetRemainCount returns amount of deck
-func (room Room) GetRemainCount() int {
+func (room *Room) GetRemainCount() int {
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
 	if room.State < IdxTurn {
 		return 56
 	}
-	return int(room.Deck.Count())
+	return room.Game.RemainCount()
 }
 
 // GetDoor returns each player's door
-func (room Room) GetDoor(id int) ([][]string, []int, bool) {
+func (room *Room) GetDoor(id int) ([][]string, []int, bool) {
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
 	if room.State < IdxTurn {
 		return [][]string{}, []int{}, true
 	}
 	var inVisibleList []int
 	var visibleList   [][]string
-	for _, player := range room.Players {
-		if id == player.ID {
-			visibleList   = append(visibleList, player.Door.ToStringArray())
+	for i, seat := range room.Game.Seats {
+		if id == i {
+			visibleList   = append(visibleList, seat.Door.ToStringArray())
 			inVisibleList = append(inVisibleList, 0)
 		} else {
-			visibleList   = append(visibleList, player.VisiableDoor.ToStringArray())
-			inVisibleList = append(inVisibleList, int(player.Door.Count() - player.VisiableDoor.Count()))
+			visibleList   = append(visibleList, seat.VisiableDoor.ToStringArray())
+			inVisibleList = append(inVisibleList, int(seat.Door.Count() - seat.VisiableDoor.Count()))
 		}
 	}
 	return visibleList, inVisibleList, false
 }
 
 // GetSea returns each player's discard tile
-func (room Room) GetSea() ([][]string, bool) {
+func (room *Room) GetSea() ([][]string, bool) {
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
 	if room.State < IdxTurn {
 		return [][]string{}, true
 	}
 	var discardTileList [][]string
-	for _, player := range room.Players {
-		discardTileList = append(discardTileList, player.DiscardTiles.ToStringArray())
+	for _, seat := range room.Game.Seats {
+		discardTileList = append(discardTileList, seat.DiscardTiles.ToStringArray())
 	}
 	return discardTileList, false
 }
 
 // GetHu returns each player's hu tile
-func (room Room) GetHu() ([][]string, bool) {
+func (room *Room) GetHu() ([][]string, bool) {
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
 	if room.State < IdxTurn {
 		return [][]string{}, true
 	}
 	var huList [][]string
-	for _, player := range room.Players {
-		huList = append(huList, player.HuTiles.ToStringArray())
+	for _, seat := range room.Game.Seats {
+		huList = append(huList, seat.HuTiles.ToStringArray())
 	}
 	return huList, false
 }
 
 // GetCurrentIdx returns current index
-func (room Room) GetCurrentIdx() int {
+func (room *Room) GetCurrentIdx() int {
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
 	id := -1
 	if room.State >= IdxTurn {
 		id = room.State - IdxTurn
@@ -103,10 +121,29 @@ func (room Room) GetCurrentIdx() int {
 }
 
 // GetScore returns each player's score
-func (room Room) GetScore() []int {
+func (room *Room) GetScore() []int {
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
 	var scoreList []int
-	for _, player := range room.Players {
-		scoreList = append(scoreList, player.Credit)
+	for _, seat := range room.Game.Seats {
+		scoreList = append(scoreList, seat.Credit)
 	}
 	return scoreList
 }
+
+// GetState returns the state of the room
+func (room *Room) GetState() int {
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
+	return room.State
+}
+
+// GetHand returns id th player's hand, empty before the tiles are dealt
+func (room *Room) GetHand(id int) []string {
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
+	if room.State < DealTile || id < 0 || id >= len(room.Players) {
+		return []string{}
+	}
+	return room.Game.Seats[id].Hand.ToStringArray()
+}
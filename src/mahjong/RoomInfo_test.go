@@ -0,0 +1,56 @@
+package mahjong
+
+import (
+	"sync"
+	"testing"
+
+	"mahjong/engine"
+)
+
+// TestGettersWhileRunning polls the getters of a room while it runs, run it
+// with -race to catch an unguarded read
+func TestGettersWhileRunning(t *testing.T) {
+	room      := NewRoom("test-getters")
+	room.Rules = engine.RulePresets["simple"]
+	for i := 0; i < 4; i++ {
+		room.AddAgent(BotName(i + 1), NewBotAgent(0))
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for polls := 0; ; polls++ {
+				select {
+				case <-done:
+					if polls == 0 {
+						t.Error("the getters aren't polled")
+					}
+					return
+				default:
+				}
+				room.GetPlayerList()
+				room.GetReadyPlayers()
+				room.GetLack()
+				room.GetHandCount()
+				room.GetRemainCount()
+				room.GetDoor(id)
+				room.GetSea()
+				room.GetHu()
+				room.GetCurrentIdx()
+				room.GetScore()
+				room.GetState()
+				room.GetHand(id)
+				room.NumPlayer()
+				room.HasHuman()
+			}
+		}(i)
+	}
+	room.Run()
+	close(done)
+	wg.Wait()
+	if hands := len(room.Session.Summaries); hands != room.Rules.Hands {
+		t.Errorf("%d hands are played, want %d", hands, room.Rules.Hands)
+	}
+}
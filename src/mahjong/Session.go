@@ -0,0 +1,77 @@
+package mahjong
+
+import (
+	"math/rand"
+
+	"github.com/satori/go.uuid"
+
+	"mahjong/engine"
+)
+
+// NewSession creates a new session of a match whose hands are seeded from seed
+func NewSession(seed int64, hands int) *Session {
+	if hands < 1 {
+		hands = 1
+	}
+	return &Session {ID: uuid.Must(uuid.NewV4()).String(), Hands: hands, random: rand.New(rand.NewSource(seed))}
+}
+
+// Session represents a match, the hands played by the same four players,
+// Dealer is the dealer of the current hand and Totals are the credits so far
+type Session struct {
+	ID        string
+	Hands     int
+	Hand      int
+	Dealer    int
+	Totals    [4]int
+	Summaries []HandSummary
+	random    *rand.Rand
+}
+
+// HandSummary represents the result of a hand in a match
+type HandSummary struct {
+	Hand       int
+	GameID     string
+	Dealer     int
+	NextDealer int
+	Scores     [4]int
+	Totals     [4]int
+}
+
+// MatchResult represents the final result of a match
+type MatchResult struct {
+	MatchID string
+	Names   [4]string
+	Hands   []HandSummary
+	Totals  [4]int
+}
+
+// IsOver returns if every hand of the match is played
+func (session Session) IsOver() bool {
+	return session.Hand >= session.Hands
+}
+
+// NextSeed returns the seed of the next hand
+func (session *Session) NextSeed() int64 {
+	return session.random.Int63()
+}
+
+// Record adds the credits of a finished hand, passes the dealer and
+// moves to the next hand
+func (session *Session) Record(gameID string, game engine.GameState) HandSummary {
+	summary := HandSummary {Hand: session.Hand, GameID: gameID, Dealer: game.Dealer, NextDealer: game.NextDealer()}
+	for i := 0; i < 4; i++ {
+		summary.Scores[i]  = game.Seats[i].Credit
+		session.Totals[i] += game.Seats[i].Credit
+	}
+	summary.Totals    = session.Totals
+	session.Summaries = append(session.Summaries, summary)
+	session.Dealer    = summary.NextDealer
+	session.Hand++
+	return summary
+}
+
+// Result returns the final result of the match
+func (session Session) Result(names [4]string) MatchResult {
+	return MatchResult {session.ID, names, session.Summaries, session.Totals}
+}
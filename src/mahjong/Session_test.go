@@ -0,0 +1,100 @@
+package mahjong
+
+import (
+	"fmt"
+	"testing"
+
+	"mahjong/engine"
+)
+
+// sessionHand is a finished hand, the seats of won are those which won and
+// first is the seat which won first, -1 for a draw
+type sessionHand struct {
+	first   int
+	won     []int
+	credits [4]int
+	dealer  int
+}
+
+func TestDealerRotation(t *testing.T) {
+	cases := []struct {
+		name  string
+		rule  int
+		hands []sessionHand
+	}{
+		{"dealer stays", engine.DealerStay, []sessionHand {
+			{0,  []int{0},    [4]int{6, -2, -2, -2}, 0},
+			{0,  []int{0},    [4]int{3, -3, 0, 0},   0},
+			{2,  []int{2},    [4]int{-1, 0, 1, 0},   0},
+			{-1, nil,         [4]int{16, -16, 0, 0}, 1},
+			{3,  []int{3, 2}, [4]int{-4, -4, 4, 4},  2},
+			{1,  []int{1},    [4]int{-2, 6, -2, -2}, 2},
+		}},
+		{"first winner", engine.DealerFirstWinner, []sessionHand {
+			{2,  []int{2, 0}, [4]int{1, -2, 2, -1},  0},
+			{-1, nil,         [4]int{0, 0, 0, 0},    2},
+			{2,  []int{2},    [4]int{-2, -2, 6, -2}, 2},
+			{1,  []int{1, 3}, [4]int{-3, 2, -1, 2},  2},
+		}},
+		{"next seat", engine.DealerNext, []sessionHand {
+			{0,  []int{0},    [4]int{3, -1, -1, -1}, 0},
+			{-1, nil,         [4]int{0, 0, 0, 0},    1},
+			{3,  []int{3},    [4]int{0, 0, -1, 1},   2},
+			{2,  []int{2},    [4]int{0, -1, 1, 0},   3},
+			{0,  []int{0},    [4]int{1, 0, 0, -1},   0},
+		}},
+	}
+	for _, c := range cases {
+		rules           := engine.RulePresets["standard"]
+		rules.DealerRule = c.rule
+		session         := NewSession(1, len(c.hands))
+		var totals [4]int
+		for i, hand := range c.hands {
+			if session.IsOver() || session.Hand != i || session.Dealer != hand.dealer {
+				t.Fatalf("%s: hand %d is dealt by %d, want %d", c.name, session.Hand, session.Dealer, hand.dealer)
+			}
+			game        := engine.NewGameState([4]string{"a", "b", "c", "d"}, session.NextSeed(), rules, session.Dealer)
+			game.FirstHu = hand.first
+			for _, id := range hand.won {
+				game.Seats[id].IsHu = true
+			}
+			for id, credit := range hand.credits {
+				game.Seats[id].Credit = credit
+				totals[id]           += credit
+			}
+			summary := session.Record(fmt.Sprint("hand-", i), game)
+			next    := session.Dealer
+			if i + 1 < len(c.hands) && next != c.hands[i + 1].dealer {
+				t.Errorf("%s: hand %d passes the dealer to %d, want %d", c.name, i, next, c.hands[i + 1].dealer)
+			}
+			if summary.Hand != i || summary.Dealer != hand.dealer || summary.NextDealer != next || summary.Scores != hand.credits {
+				t.Errorf("%s: hand %d is summed up as %+v", c.name, i, summary)
+			}
+			if summary.Totals != totals || session.Totals != totals {
+				t.Errorf("%s: the totals after hand %d are %v, want %v", c.name, i, summary.Totals, totals)
+			}
+		}
+		if !session.IsOver() {
+			t.Errorf("%s: the match isn't over after %d hands", c.name, len(c.hands))
+		}
+		result := session.Result([4]string{"a", "b", "c", "d"})
+		if result.MatchID != session.ID || len(result.Hands) != len(c.hands) || result.Totals != totals {
+			t.Errorf("%s: the match result is %+v", c.name, result)
+		}
+	}
+}
+
+func TestSessionSeeds(t *testing.T) {
+	session, again, other := NewSession(7, 4), NewSession(7, 4), NewSession(8, 4)
+	differ := false
+	for i := 0; i < 4; i++ {
+		seed := session.NextSeed()
+		if again.NextSeed() != seed {
+			t.Errorf("hand %d of the same match seed is seeded differently", i)
+		}
+		differ = differ || other.NextSeed() != seed
+	}
+	if !differ {
+		t.Error("two match seeds seed the same hands")
+	}
+}
@@ -1,8 +1,11 @@
 package mahjong
 
 import (
-	"github.com/googollee/go-socket.io"
+	"encoding/json"
 	"log"
+	"sort"
+
+	"github.com/googollee/go-socket.io"
 )
 
 // SocketError is callback of socket error event
@@ -15,56 +18,182 @@ func SocketConnect(so socketio.Socket) {
 	log.Println("on connection")
 
 	so.Emit("auth")
-	
+
+	prompts := NewDispatcher()
+	for _, event := range ResponseEvents {
+		event := event
+		so.On(event, func(id int, val interface{}) {
+			if code := prompts.Deliver(event, id, val); code != "" {
+				log.Println("reject response:", so.Id(), event, id, code)
+				so.Emit("invalidResponse", id, code)
+			}
+		})
+	}
+
 	so.On("join", func(name string) (string, bool) {
-		if name == "" {
-			return "", true
+		session, err := GuestLogin(name)
+		token, message := enter(so, prompts, session, err)
+		return token, message != ""
+	})
+
+	so.On("register", func(name string, password string) (string, string) {
+		session, err := Register(name, password)
+		return enter(so, prompts, session, err)
+	})
+
+	so.On("login", func(name string, password string) (string, string) {
+		session, err := Authenticate(name, password)
+		return enter(so, prompts, session, err)
+	})
+
+	so.On("resume", func(token string) (string, string) {
+		session, err := Resume(token)
+		return enter(so, prompts, session, err)
+	})
+
+	so.On("upgrade", func(token string, password string) string {
+		if err := Upgrade(token, password); err != nil {
+			return err.Error()
 		}
-		_uuid, _err := Login(name, &so)
-		return _uuid, _err
+		return ""
 	})
 
-	so.On("auth", func(uuid string, room string) int {
-		if uuid == "" {
-			return -1
+	so.On("logout", func(token string, session string) bool {
+		claims, err := Tokens.Verify(token)
+		if err != nil || EndSession(claims.Account, session) != nil || Tokens.Revoke(token) != nil {
+			return false
 		}
+		Logout(so)
+		return true
+	})
 
-		index := FindPlayerByUUID(uuid)
-		if index == -1 {
+	so.On("auth", func(token string, room string) int {
+		_player, ok := authorize(token, room)
+		if !ok {
 			return -1
 		}
 
-		player := PlayerList[index]
-		if (player.State & (MATCHED | READY | PLAYING)) != 0 && !(room == "") && player.Room == room {
+		state := -1
+		join  := false
+		PlayerList.Update(_player.UUID, func(player *IPlayer) {
+			join           = (player.State & (MATCHED | READY | PLAYING)) != 0 && !(room == "") && player.Room == room
+			player.Socket  = &so
+			player.Prompts = prompts
+			state          = player.State
+		})
+		if join {
 			so.Join(room)
 		}
-		player.Socket = &so
-		return player.State
+		return state
 	})
 
-	so.On("ready",          socketReady)
-	so.On("getRoomInfo",    getRoomInfo)
-	so.On("getID",          getID)
-	so.On("getReadyPlayer", getReadyPlayer)
-	so.On("getHand",        getHand)
-	so.On("getPlayerList",  getPlayerList)
-	so.On("getLack",        getLack)
-	so.On("getHandCount",   getHandCount)
-	so.On("getRemainCount", getRemainCount)
-	so.On("getDoor",        getDoor)
-	so.On("getSea",         getSea)
-	so.On("getHu",          getHu)
-	so.On("getCurrentIdx",  getCurrentIdx)
-	so.On("getScore",       getScore)
+	so.On("refreshToken",     refreshToken)
+	so.On("roomToken",        roomToken)
+	so.On("ready",            socketReady)
+	so.On("joinQueue",        joinQueue)
+	so.On("cancelQueue",      cancelQueue)
+	so.On("getQueue",         getQueue)
+	so.On("getTiers",         getTiers)
+	so.On("getRating",        getRating)
+	so.On("getRatingHistory", getRatingHistory)
+	so.On("getRoomInfo",      getRoomInfo)
+	so.On("getID",            getID)
+	so.On("getReadyPlayer",   getReadyPlayer)
+	so.On("getHand",          getHand)
+	so.On("getPlayerList",    getPlayerList)
+	so.On("getLack",          getLack)
+	so.On("getHandCount",     getHandCount)
+	so.On("getRemainCount",   getRemainCount)
+	so.On("getDoor",          getDoor)
+	so.On("getSea",           getSea)
+	so.On("getHu",            getHu)
+	so.On("getCurrentIdx",    getCurrentIdx)
+	so.On("getScore",         getScore)
+	so.On("getReplay",        getReplay)
+	so.On("getHands",         getHands)
+	so.On("getBalance",       getBalance)
+	so.On("getTransactions",  getTransactions)
+	so.On("getLeaderboard",   getLeaderboard)
+	so.On("getArchives",      getArchives)
 
 	so.On("disconnection", func() {
 		log.Println("on disconnect")
+		prompts.Close()
 		Logout(so)
 	})
 }
 
-func socketReady(uuid string, room string) int {
-	if !Auth(room, uuid) {
+// enter logs the socket in by the login session and sends the session to
+// the client, it returns the token acting for the player or the error
+// message. The tokens issued before are revoked
+func enter(so socketio.Socket, prompts *Dispatcher, session LoginSession, err error) (string, string) {
+	if err != nil {
+		return "", err.Error()
+	}
+	account, err := Storage.Account(session.Name)
+	if err != nil {
+		return "", err.Error()
+	}
+	if !account.Guest {
+		if err := grantStartingCredits(session.Name); err != nil {
+			log.Println("grant error:", err)
+		}
+	}
+	if _, _err := Login(session.Name, &so, prompts); _err {
+		return "", "login failed"
+	}
+	if err := Tokens.RevokeAccount(session.Name); err != nil {
+		return "", err.Error()
+	}
+	token, _, err := Tokens.Issue(session.Name, "", -1)
+	if err != nil {
+		return "", err.Error()
+	}
+	so.Emit("session", session.Token, session.Name, account.Guest)
+	return token, ""
+}
+
+// authorize returns the player on whose behalf the token acts, room is the
+// room of the event, "" if the event isn't in a room
+func authorize(token string, room string) (IPlayer, bool) {
+	claims, err := Tokens.Verify(token)
+	if err != nil {
+		return IPlayer{}, false
+	}
+	player, ok := PlayerList.GetByName(claims.Account)
+	if !ok || claims.Room != "" && (claims.Room != room || player.Room != room || player.Index != claims.Seat) {
+		return IPlayer{}, false
+	}
+	return player, true
+}
+
+func refreshToken(token string) (string, string) {
+	rotated, err := Tokens.Rotate(token)
+	if err != nil {
+		return "", err.Error()
+	}
+	return rotated, ""
+}
+
+func roomToken(token string) (string, string) {
+	player, ok := authorize(token, "")
+	if !ok {
+		return "", ErrInvalidToken.Error()
+	}
+	if player.Room == "" || player.Index < 0 {
+		return "", "not seated"
+	}
+	scoped, _, err := Tokens.Issue(player.Name, player.Room, player.Index)
+	if err != nil {
+		return "", err.Error()
+	}
+	return scoped, ""
+}
+
+func socketReady(token string, room string) int {
+	player, ok := authorize(token, room)
+	_room     := game.Room(room)
+	if !ok || player.Room != room || _room == nil {
 		return -1
 	}
 
@@ -72,111 +201,246 @@ func socketReady(uuid string, room string) int {
 	fn := func(id int) {
 		c<-id
 	}
-	go game.Rooms[room].Accept(uuid, fn)
+	go _room.Accept(player.UUID, fn)
 	return <-c
 }
 
-func getRoomInfo(uuid string) (string, []string, bool) {
-	if uuid == "" {
-		return "", []string{}, true
+func joinQueue(token string, rules string, tier string) bool {
+	player, ok := authorize(token, "")
+	return ok && GetQueue().Join(player.UUID, rules, tier)
+}
+
+func getTiers() []Tier {
+	var tiers []Tier
+	for _, tier := range Tiers {
+		tiers = append(tiers, tier)
+	}
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].Stake < tiers[j].Stake
+	})
+	return tiers
+}
+
+func cancelQueue(token string) bool {
+	player, ok := authorize(token, "")
+	return ok && GetQueue().Cancel(player.UUID)
+}
+
+func getQueue(token string) (QueueStatus, bool) {
+	player, ok := authorize(token, "")
+	if !ok {
+		return QueueStatus{}, true
+	}
+	status, ok := GetQueue().Position(player.UUID)
+	return status, !ok
+}
+
+func getRating(name string) (Rating, bool) {
+	if Ratings == nil || name == "" {
+		return Rating{}, true
 	}
-	index := FindPlayerByUUID(uuid)
-	if index == -1 {
+	return Ratings.Get(name), false
+}
+
+func getRatingHistory(name string, limit int) ([]RatingChange, bool) {
+	if Ratings == nil || name == "" {
+		return []RatingChange{}, true
+	}
+	return Ratings.History(name, limit), false
+}
+
+func getRoomInfo(token string) (string, []string, bool) {
+	player, ok := authorize(token, "")
+	if !ok {
 		return "", []string{}, true
 	}
-	player := PlayerList[index]
-	room   := player.Room
-	return room, GetNameList(FindPlayerListInRoom(room)), false
+	room := player.Room
+	return room, GetNameList(PlayerList.InRoom(room)), false
 }
 
 func getReadyPlayer(room string) []string {
-	if game.Rooms[room] == nil {
+	_room := game.Room(room)
+	if _room == nil {
 		return []string{}
 	}
-	return game.Rooms[room].GetReadyPlayers()
+	return _room.GetReadyPlayers()
 }
 
-func getHand(uuid string, room string) []string {
-	if !Auth(room, uuid) || game.Rooms[room].State < DealTile {
+func getHand(token string, room string) []string {
+	player, ok := authorize(token, room)
+	_room     := game.Room(room)
+	if !ok || player.Room != room || _room == nil {
 		return []string{}
 	}
-	index := FindPlayerByUUID(uuid)
-	id    := PlayerList[index].Index
-	return game.Rooms[room].Players[id].Hand.ToStringArray()
+	return _room.GetHand(player.Index)
 }
 
-func getID(uuid string, room string) int {
-	if !Auth(room, uuid) {
+func getID(token string, room string) int {
+	player, ok := authorize(token, room)
+	if !ok || player.Room != room || player.State != READY {
 		return -1
 	}
-	index := FindPlayerByUUID(uuid)
-	if PlayerList[index].State != READY {
-		return -1
-	}
-	return PlayerList[index].Index
+	return player.Index
 }
 
 func getPlayerList(room string) []string {
-	if game.Rooms[room] == nil {
+	_room := game.Room(room)
+	if _room == nil {
 		return []string{}
 	}
-	return game.Rooms[room].GetPlayerList()
+	return _room.GetPlayerList()
 }
 
 func getLack(room string) []int {
-	if game.Rooms[room] == nil {
+	_room := game.Room(room)
+	if _room == nil {
 		return []int{}
 	}
-	return game.Rooms[room].GetLack()
+	return _room.GetLack()
 }
 
 func getHandCount(room string) []int {
-	if game.Rooms[room] == nil {
+	_room := game.Room(room)
+	if _room == nil {
 		return []int{}
 	}
-	return game.Rooms[room].GetHandCount()
+	return _room.GetHandCount()
 }
 
 func getRemainCount(room string) int {
-	if game.Rooms[room] == nil {
+	_room := game.Room(room)
+	if _room == nil {
 		return 56
 	}
-	return game.Rooms[room].GetRemainCount()
+	return _room.GetRemainCount()
 }
 
-func getDoor(uuid string, room string) ([][]string, []int, bool) {
-	if !Auth(room, uuid) {
+func getDoor(token string, room string) ([][]string, []int, bool) {
+	player, ok := authorize(token, room)
+	_room     := game.Room(room)
+	if !ok || player.Room != room || _room == nil {
 		return [][]string{}, []int{}, true
 	}
-	index := FindPlayerByUUID(uuid)
-	id    := PlayerList[index].Index
-	return game.Rooms[room].GetDoor(id)
+	return _room.GetDoor(player.Index)
 }
 
 func getSea(room string) ([][]string, bool) {
-	if game.Rooms[room] == nil {
+	_room := game.Room(room)
+	if _room == nil {
 		return [][]string{}, true
 	}
-	return game.Rooms[room].GetSea()
+	return _room.GetSea()
 }
 
 func getHu(room string) ([][]string, bool) {
-	if game.Rooms[room] == nil {
+	_room := game.Room(room)
+	if _room == nil {
 		return [][]string{}, true
 	}
-	return game.Rooms[room].GetHu()
+	return _room.GetHu()
 }
 
 func getCurrentIdx(room string) int {
-	if game.Rooms[room] == nil {
+	_room := game.Room(room)
+	if _room == nil {
 		return -1
 	}
-	return game.Rooms[room].GetCurrentIdx()
+	return _room.GetCurrentIdx()
 }
 
 func getScore(room string) []int {
-	if game.Rooms[room] == nil {
+	_room := game.Room(room)
+	if _room == nil {
 		return []int{}
 	}
-	return game.Rooms[room].GetScore()
-}
\ No newline at end of file
+	return _room.GetScore()
+}
+func getHands(query HandQuery) (HandPage, bool) {
+	if query.Player == "" {
+		return HandPage{}, true
+	}
+	page, err := Storage.HandRecords(query.own())
+	if err != nil {
+		log.Println("hand records error:", err)
+		return HandPage{}, true
+	}
+	return page, false
+}
+
+func getBalance(token string) (int, bool) {
+	player, ok := authorize(token, "")
+	if !ok {
+		return 0, true
+	}
+	balance, err := Storage.Balance(player.Name)
+	return balance, err != nil
+}
+
+func getTransactions(token string, offset int, limit int) ([]Transaction, bool) {
+	player, ok := authorize(token, "")
+	if !ok {
+		return []Transaction{}, true
+	}
+	if limit <= 0 || limit > maxHandPage {
+		limit = maxHandPage
+	}
+	transactions, err := Storage.Transactions(player.Name, offset, limit)
+	start := historyStart(player.Name)
+	for i, transaction := range transactions {
+		if transaction.Time.Before(start) {
+			transactions = transactions[:i]
+			break
+		}
+	}
+	return transactions, err != nil
+}
+
+func getReplay(gameID string, index int, seat int) (string, bool) {
+	if !isValidGameID(gameID) || seat < -1 || seat >= 4 {
+		return "", true
+	}
+	replayer, err := NewReplayer(Storage, gameID)
+	if err != nil || replayer.Result == nil {
+		return "", true
+	}
+	if err := replayer.Verify(); err != nil {
+		log.Println("replay", gameID, "error:", err)
+	}
+	replayer.Seek(index)
+	view, _ := json.Marshal(replayer.View(seat))
+	return string(view), false
+}
+
+func isValidGameID(gameID string) bool {
+	if gameID == "" {
+		return false
+	}
+	for _, c := range gameID {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func getLeaderboard(query LeaderboardQuery) (LeaderboardPage, bool) {
+	if Leaderboards == nil {
+		return LeaderboardPage{}, true
+	}
+	page, err := Leaderboards.Query(query)
+	if err != nil {
+		return LeaderboardPage{}, true
+	}
+	return page, false
+}
+
+func getArchives(period string) ([]Board, bool) {
+	if Leaderboards == nil {
+		return []Board{}, true
+	}
+	archives, err := Leaderboards.Archives(period)
+	if err != nil {
+		return []Board{}, true
+	}
+	return archives, false
+}
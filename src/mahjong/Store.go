@@ -0,0 +1,111 @@
+package mahjong
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"mahjong/engine"
+)
+
+// ErrNotFound is returned by a store if there is no such record
+var ErrNotFound = errors.New("not found")
+
+// Storage is the store of the server
+var Storage Store
+
+// Store stores the data which outlives the process: the accounts and their
+// login sessions, the game logs, the results of the matches, the ratings,
+// the ledger of the credits, the leaderboards and the revoked tokens. It's
+// safe for concurrent use
+type Store interface {
+	AccountStore
+	GameStore
+	RatingStore
+	LedgerStore
+	LeaderboardStore
+	RevocationStore
+	Close() error
+}
+
+// AccountStore stores the accounts and their login sessions
+type AccountStore interface {
+	Account(name string) (Account, error)
+	PutAccount(account Account) error
+	LoginSession(token string) (LoginSession, error)
+	PutLoginSession(session LoginSession) error
+	DeleteLoginSession(token string) error
+}
+
+// GameStore stores the game logs, the finished hands and the results of the
+// matches, a game log is created by its header, appended to by the events and
+// finished by the result. CreateGameLog returns where the log is stored, ""
+// if it's in memory
+type GameStore interface {
+	CreateGameLog(header LogHeader) (string, error)
+	AppendGameLog(gameID string, record LogRecord) error
+	FinishGameLog(gameID string, result LogResult) error
+	GameLog(gameID string) (LogHeader, []LogRecord, []engine.GameResult, error)
+	PutHandRecord(record HandRecord) error
+	HandRecords(query HandQuery) (HandPage, error)
+	PutMatchResult(result MatchResult) error
+	MatchResult(matchID string) (MatchResult, error)
+}
+
+// RatingStore stores the ratings and their history, PutRatings stores the
+// ratings changed by a hand at once. The history of a rating given to
+// PutRatings is the changes after the stored ones, and a rating without
+// history starts the player over without the stored history
+type RatingStore interface {
+	Ratings() ([]Rating, error)
+	PutRatings(ratings []Rating) error
+}
+
+// LedgerStore stores the ledger of the credits, a settlement is posted at
+// once or not at all and only once for a game. A balance is the sum of the
+// postings to the account, and Transactions returns the latest first
+type LedgerStore interface {
+	Balance(account string) (int, error)
+	PostSettlement(settlement Settlement) error
+	Transactions(account string, offset int, limit int) ([]Transaction, error)
+}
+
+// LeaderboardStore stores the boards of the periods in progress and the
+// final standings of the periods which ended, PutLeaderboards replaces the
+// boards at once. A board of the same period and ID is archived only once,
+// and ArchivedBoards returns the boards of a period in the order archived
+type LeaderboardStore interface {
+	Leaderboards() ([]Board, error)
+	PutLeaderboards(boards []Board) error
+	ArchiveBoard(board Board) error
+	ArchivedBoards(period string) ([]Board, error)
+}
+
+// RevocationStore stores the revocations of the tokens until they expire,
+// Revocations returns the ones which haven't expired
+type RevocationStore interface {
+	Revocations() ([]Revocation, error)
+	PutRevocation(revocation Revocation) error
+}
+
+// writeAtomic writes data to a temporary file and renames it to path, so
+// path is never left half written
+func writeAtomic(path string, data []byte) error {
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
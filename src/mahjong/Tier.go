@@ -0,0 +1,78 @@
+package mahjong
+
+import (
+	"time"
+
+	"mahjong/engine"
+)
+
+// Tier is a class of tables, a unit of score is Stake credits (底分) and a
+// player needs MinBalance credits to queue for the tier
+type Tier struct {
+	Name       string
+	Stake      int
+	MinBalance int
+}
+
+// PracticeTier is the tier of the tables whose hands aren't settled, rated
+// or ranked, it's the only tier a guest can queue for
+const PracticeTier = "practice"
+
+// Tiers are the tiers of tables a player can queue for
+var Tiers = map[string]Tier {
+	"practice":    {"practice",    1,   0},
+	"beginner":    {"beginner",    1,   100},
+	"standard":    {"standard",    10,  2000},
+	"high-roller": {"high-roller", 100, 50000},
+}
+
+// DefaultTier is the tier of a player who doesn't ask for one
+var DefaultTier = "beginner"
+
+// Bankruptcy rules, what happens to a player who can't cover a payment
+const (
+	BankruptcyCap   = "cap"
+	BankruptcyLeave = "leave"
+)
+
+// BankruptcyRule decides what happens to a player who can't cover a
+// payment. Every payment of the player is capped at the balance either way,
+// by BankruptcyCap the player plays the rest of the match, by
+// BankruptcyLeave the player leaves the room once the moves in progress
+// are made and a bot plays the seat
+var BankruptcyRule = BankruptcyCap
+
+// StartingCredits are granted to an account once when it's registered, a
+// guest gets them when it's upgraded
+var StartingCredits = 2000
+
+// GetTier returns the tier of name, "" is the default tier and a guest only
+// gets the practice tier
+func GetTier(name string, guest bool) (Tier, bool) {
+	if name == "" {
+		name = engine.IF(guest, PracticeTier, DefaultTier).(string)
+	}
+	if guest && name != PracticeTier {
+		return Tier{}, false
+	}
+	tier, ok := Tiers[name]
+	return tier, ok
+}
+
+// grantStartingCredits grants StartingCredits to the account, the grant is
+// posted as the settlement "grant/<account>" so it's never posted twice
+func grantStartingCredits(account string) error {
+	if StartingCredits <= 0 {
+		return nil
+	}
+	now   := time.Now()
+	id    := "grant/" + account
+	grant := Settlement {id, now, []Transaction {{id + "/0", id, "grant", "起始點數", now, []Posting {
+		{account, BankAccount, StartingCredits},
+		{BankAccount, account, -StartingCredits},
+	}}}}
+	if err := Storage.PostSettlement(grant); err != ErrPosted {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package mahjong
+
+import (
+	"fmt"
+	"testing"
+
+	"mahjong/engine"
+)
+
+// stakedRoom seats four human players played by bots at a table of the tier
+// without pauses or delays, the players are funded with the credits in a store which
+// is used until the test ends
+func stakedRoom(t *testing.T, seed int64, tier string, credits [4]int) *Room {
+	store, pauses, delay := Storage, Pauses, BotDelay
+	t.Cleanup(func() {
+		Storage, Pauses, BotDelay = store, pauses, delay
+	})
+	Storage, Pauses, BotDelay = NewMemoryStore(), false, 0
+
+	room      := NewRoom(fmt.Sprint(t.Name(), "/", seed))
+	room.Seed  = seed
+	room.Rules = engine.RulePresets["standard"]
+	room.Tier  = Tiers[tier]
+	for i, amount := range credits {
+		name      := fmt.Sprint(room.Name, "/", i)
+		uuid, err := PlayerList.Add(name)
+		if err {
+			t.Fatalf("the player %s is added twice", name)
+		}
+		PlayerList.Update(uuid, func(player *IPlayer) {
+			player.Room = room.Name
+		})
+		t.Cleanup(func() {
+			PlayerList.Remove(uuid)
+		})
+		room.Players = append(room.Players, &Player {room: room, ID: i, UUID: uuid, Agent: NewBotAgent(0)})
+		if err := Storage.PostSettlement(transfer("fund/" + name, HouseAccount, name, amount)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return room
+}
+
+// bankrupt plays a hand of the seeds in turn until the first seat, which
+// can't cover a payment, is bankrupt
+func bankrupt(t *testing.T) *Room {
+	t.Helper()
+	for seed := int64(1); seed <= 50; seed++ {
+		room := stakedRoom(t, seed, "standard", [4]int{5, 1000, 1000, 1000})
+		room.Run()
+		if room.Game.Seats[0].Bankrupt {
+			return room
+		}
+	}
+	t.Fatal("the first seat is never bankrupt")
+	return nil
+}
+
+func TestGetTier(t *testing.T) {
+	cases := []struct {
+		name  string
+		guest bool
+		tier  string
+		ok    bool
+	}{
+		{"",           false, DefaultTier,  true},
+		{"",           true,  PracticeTier, true},
+		{"standard",   false, "standard",   true},
+		{"standard",   true,  "",           false},
+		{"beginner",   true,  "",           false},
+		{PracticeTier, true,  PracticeTier, true},
+		{"unknown",    false, "",           false},
+	}
+	for _, c := range cases {
+		if tier, ok := GetTier(c.name, c.guest); tier.Name != c.tier || ok != c.ok {
+			t.Errorf("GetTier(%q, %v) = %q, %v, want %q, %v", c.name, c.guest, tier.Name, ok, c.tier, c.ok)
+		}
+	}
+}
+
+func TestQueueMinBalance(t *testing.T) {
+	test  := newQueue(t, 4, 0, 0)
+	alice := test.player("alice", InitialRating)
+	test.balances[alice] = Tiers["standard"].MinBalance - 1
+	if test.queue.Join(alice, "", "standard") || test.state(alice) != WAITING {
+		t.Error("a player under the minimum balance joins")
+	}
+	test.balances[alice] = Tiers["standard"].MinBalance
+	if !test.queue.Join(alice, "", "standard") {
+		t.Error("a player with the minimum balance can't join")
+	}
+}
+
+func TestQueueGuestTier(t *testing.T) {
+	test  := newQueue(t, 4, 0, 0)
+	guest := test.player("guest", InitialRating)
+	test.guests[guest] = true
+	for _, tier := range []string{DefaultTier, "standard", "high-roller"} {
+		if test.queue.Join(guest, "", tier) {
+			t.Errorf("a guest joins the tier %s", tier)
+		}
+	}
+	if !test.queue.Join(guest, "", "") {
+		t.Fatal("a guest can't join")
+	}
+	if status, ok := test.queue.Position(guest); !ok || status.Tier != PracticeTier {
+		t.Errorf("a guest is queued for %+v", status)
+	}
+}
+
+func TestPaymentsCappedAtBalance(t *testing.T) {
+	room    := bankrupt(t)
+	funded  := [4]int{5, 1000, 1000, 1000}
+	results := room.Game.Result()
+	credits := balances(t, Storage, room.Header.Names[:]...)
+	if room.Header.Balances != funded || room.Game.Stake != Tiers["standard"].Stake {
+		t.Errorf("the hand is staked %d with the balances %v, want the ledger's %v", room.Game.Stake, room.Header.Balances, funded)
+	}
+	total := 0
+	for i, result := range results {
+		total += credits[i]
+		if credits[i] < 0 {
+			t.Errorf("seat %d is left with %d credits", i, credits[i])
+		}
+		if credits[i] - funded[i] != result.Score {
+			t.Errorf("seat %d is settled %d for the credits %d", i, credits[i] - funded[i], result.Score)
+		}
+	}
+	if total != 3005 {
+		t.Errorf("the balances sum to %d, want 3005", total)
+	}
+	if results[0].Capped <= 0 {
+		t.Errorf("the bankrupt seat has %d credits capped", results[0].Capped)
+	}
+}
+
+func TestBankruptcyRule(t *testing.T) {
+	defer func(rule string) { BankruptcyRule = rule }(BankruptcyRule)
+	for _, rule := range []string{BankruptcyCap, BankruptcyLeave} {
+		BankruptcyRule = rule
+		t.Run(rule, func(t *testing.T) {
+			room    := bankrupt(t)
+			seat    := room.Players[0]
+			info, _ := PlayerList.GetByName(room.Header.Names[0])
+			if rule == BankruptcyCap && (!seat.IsHuman() || info.Room != room.Name) {
+				t.Error("the bankrupt player leaves the room")
+			}
+			if rule == BankruptcyLeave && (seat.IsHuman() || info.Room != "" || info.State != WAITING) {
+				t.Errorf("the bankrupt player stays in the room %q", info.Room)
+			}
+			if seat.Name() != room.Header.Names[0] {
+				t.Errorf("the seat is played as %s", seat.Name())
+			}
+			if !room.Players[1].IsHuman() {
+				t.Error("a player who isn't bankrupt leaves")
+			}
+		})
+	}
+}
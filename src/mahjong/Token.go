@@ -0,0 +1,230 @@
+package mahjong
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretPath is where the key signing the tokens is stored
+var SecretPath = "secret.key"
+
+// Tokens signs and checks the tokens acting on behalf of the players
+var Tokens *TokenSigner
+
+// TokenTTL is how long a token lasts, the client rotates it before it
+// expires by refreshToken, or gets a new one by resuming the login session
+var TokenTTL = time.Hour
+
+// Token errors
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+	ErrRevokedToken = errors.New("token revoked")
+)
+
+// Claims are carried by a token, a token of a room only acts in the room
+// and for the seat, Seat is -1 if Room is ""
+type Claims struct {
+	ID      string `json:"id"`
+	Account string `json:"account"`
+	Issued  int64  `json:"issued"`
+	Expires int64  `json:"expires"`
+	Room    string `json:"room,omitempty"`
+	Seat    int    `json:"seat"`
+}
+
+// Revocation revokes the token of ID, or every token of Account issued
+// until Before if ID is "". It's kept until Expires, when the tokens it
+// revokes have expired
+type Revocation struct {
+	ID      string
+	Account string
+	Before  int64
+	Expires int64
+}
+
+// LoadTokenSigner loads the key stored at path and the revocations kept in
+// the store, a new key is created and stored if there is no such file, and
+// "" keeps the key in memory so the tokens don't survive a restart
+func LoadTokenSigner(path string, store RevocationStore) (*TokenSigner, error) {
+	signer := &TokenSigner {store: store, revoked: make(map[string]int64), before: make(map[string]int64)}
+	revocations, err := store.Revocations()
+	if err != nil {
+		return signer, err
+	}
+	for _, revocation := range revocations {
+		if revocation.ID != "" {
+			signer.revoked[revocation.ID] = revocation.Expires
+		} else if revocation.Before > signer.before[revocation.Account] {
+			signer.before[revocation.Account] = revocation.Before
+		}
+	}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err == nil {
+			signer.key, err = hex.DecodeString(string(bytes.TrimSpace(data)))
+			if err == nil && len(signer.key) < 32 {
+				err = errors.New("key is too short")
+			}
+			return signer, err
+		}
+		if !os.IsNotExist(err) {
+			return signer, err
+		}
+	}
+	signer.key = make([]byte, 32)
+	if _, err := rand.Read(signer.key); err != nil {
+		return signer, err
+	}
+	if path == "" {
+		return signer, nil
+	}
+	return signer, writeAtomic(path, []byte(hex.EncodeToString(signer.key)))
+}
+
+// TokenSigner issues the tokens signed by HMAC-SHA256 and keeps the revoked
+// ones until they expire, it's safe for concurrent use
+//
+// A token is the base64 of the JSON claims and the base64 of their
+// signature joined by a dot. The revocations are stored, so a revoked token
+// stays revoked after a restart. A revocation which isn't stored is kept in
+// memory and the error is returned
+type TokenSigner struct {
+	key     []byte
+	mutex   sync.Mutex
+	store   RevocationStore
+	revoked map[string]int64
+	before  map[string]int64
+}
+
+// Issue issues a token of the account, room "" issues a token acting
+// outside of a room
+func (signer *TokenSigner) Issue(account string, room string, seat int) (string, Claims, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", Claims{}, err
+	}
+	if room == "" {
+		seat = -1
+	}
+	now    := time.Now()
+	claims := Claims {hex.EncodeToString(id), account, now.UnixNano(), now.Add(TokenTTL).UnixNano(), room, seat}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", Claims{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signer.sign(encoded)), claims, nil
+}
+
+// Verify checks the signature, the expiry and the revocation of the token
+// and returns its claims
+func (signer *TokenSigner) Verify(token string) (Claims, error) {
+	signer.mutex.Lock()
+	defer signer.mutex.Unlock()
+	return signer.verify(token)
+}
+
+// Rotate revokes the token and issues a new one with the same account, room
+// and seat
+func (signer *TokenSigner) Rotate(token string) (string, error) {
+	signer.mutex.Lock()
+	claims, err := signer.verify(token)
+	if err == nil {
+		err = signer.revoke(claims)
+	}
+	signer.mutex.Unlock()
+	if err != nil {
+		return "", err
+	}
+	rotated, _, err := signer.Issue(claims.Account, claims.Room, claims.Seat)
+	return rotated, err
+}
+
+// Revoke revokes the token, it fails if the token isn't valid
+func (signer *TokenSigner) Revoke(token string) error {
+	signer.mutex.Lock()
+	defer signer.mutex.Unlock()
+	claims, err := signer.verify(token)
+	if err != nil {
+		return err
+	}
+	return signer.revoke(claims)
+}
+
+// RevokeAccount revokes every token of the account issued so far
+func (signer *TokenSigner) RevokeAccount(account string) error {
+	signer.mutex.Lock()
+	defer signer.mutex.Unlock()
+	now := time.Now().UnixNano()
+	signer.before[account] = now
+	signer.purge()
+	return signer.store.PutRevocation(Revocation {"", account, now, now + int64(TokenTTL)})
+}
+
+// verify is called with the mutex locked
+func (signer *TokenSigner) verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return Claims{}, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, signer.sign(parts[0])) {
+		return Claims{}, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Account == "" {
+		return Claims{}, ErrInvalidToken
+	}
+	if time.Now().UnixNano() >= claims.Expires {
+		return Claims{}, ErrExpiredToken
+	}
+	if _, ok := signer.revoked[claims.ID]; ok || claims.Issued <= signer.before[claims.Account] {
+		return Claims{}, ErrRevokedToken
+	}
+	return claims, nil
+}
+
+// revoke is called with the mutex locked
+func (signer *TokenSigner) revoke(claims Claims) error {
+	signer.revoked[claims.ID] = claims.Expires
+	signer.purge()
+	return signer.store.PutRevocation(Revocation {claims.ID, claims.Account, 0, claims.Expires})
+}
+
+// purge drops the revocations of the tokens which expired, it's called with
+// the mutex locked
+func (signer *TokenSigner) purge() {
+	now := time.Now().UnixNano()
+	for id, expires := range signer.revoked {
+		if now >= expires {
+			delete(signer.revoked, id)
+		}
+	}
+	for account, before := range signer.before {
+		if now >= before + int64(TokenTTL) {
+			delete(signer.before, account)
+		}
+	}
+}
+
+func (signer *TokenSigner) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, signer.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
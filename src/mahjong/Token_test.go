@@ -0,0 +1,191 @@
+package mahjong
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSigner(t *testing.T) *TokenSigner {
+	signer, err := LoadTokenSigner("", NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestTokenVerify(t *testing.T) {
+	signer := newSigner(t)
+	token, issued, err := signer.Issue("alice", "room", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := signer.Verify(token)
+	if err != nil || claims != issued || claims.Account != "alice" || claims.Room != "room" || claims.Seat != 2 {
+		t.Errorf("verified %v, %v", claims, err)
+	}
+	if _, claims, _ := signer.Issue("alice", "", 2); claims.Seat != -1 {
+		t.Errorf("a token outside of a room has the seat %d", claims.Seat)
+	}
+
+	parts        := strings.Split(token, ".")
+	forged, _, _ := signer.Issue("mallory", "", -1)
+	other, _, _  := newSigner(t).Issue("alice", "", -1)
+	cases        := map[string]string {
+		"empty":           "",
+		"no signature":    parts[0],
+		"bad signature":   parts[0] + "." + parts[0],
+		"swapped payload": strings.Split(forged, ".")[0] + "." + parts[1],
+		"other key":       other,
+		"trailing part":   token + ".x",
+	}
+	for name, token := range cases {
+		if _, err := signer.Verify(token); err != ErrInvalidToken {
+			t.Errorf("%s: got %v, want %v", name, err, ErrInvalidToken)
+		}
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	signer := newSigner(t)
+	ttl    := TokenTTL
+	defer func() {
+		TokenTTL = ttl
+	}()
+	TokenTTL = -time.Second
+	expired, _, _ := signer.Issue("alice", "", -1)
+	if _, err := signer.Verify(expired); err != ErrExpiredToken {
+		t.Errorf("got %v, want %v", err, ErrExpiredToken)
+	}
+	if _, err := signer.Rotate(expired); err != ErrExpiredToken {
+		t.Errorf("an expired token is rotated: %v", err)
+	}
+	TokenTTL = time.Hour
+	token, _, _ := signer.Issue("alice", "", -1)
+	if _, err := signer.Verify(token); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTokenRevoke(t *testing.T) {
+	signer := newSigner(t)
+	token, _, _ := signer.Issue("alice", "", -1)
+	other, _, _ := signer.Issue("alice", "", -1)
+	if err := signer.Revoke(token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signer.Verify(token); err != ErrRevokedToken {
+		t.Errorf("got %v, want %v", err, ErrRevokedToken)
+	}
+	if err := signer.Revoke(token); err != ErrRevokedToken {
+		t.Errorf("a revoked token is revoked again: %v", err)
+	}
+	if _, err := signer.Verify(other); err != nil {
+		t.Errorf("another token is revoked: %v", err)
+	}
+
+	rotated, err := signer.Rotate(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signer.Verify(other); err != ErrRevokedToken {
+		t.Errorf("the rotated token: got %v, want %v", err, ErrRevokedToken)
+	}
+	if claims, err := signer.Verify(rotated); err != nil || claims.Account != "alice" {
+		t.Errorf("the new token: %v, %v", claims, err)
+	}
+
+	bob, _, _ := signer.Issue("bob", "", -1)
+	if err := signer.RevokeAccount("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signer.Verify(rotated); err != ErrRevokedToken {
+		t.Errorf("a token of the revoked account: got %v, want %v", err, ErrRevokedToken)
+	}
+	if _, err := signer.Verify(bob); err != nil {
+		t.Errorf("a token of another account is revoked: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	fresh, _, _ := signer.Issue("alice", "", -1)
+	if _, err := signer.Verify(fresh); err != nil {
+		t.Errorf("a token issued after the revocation: %v", err)
+	}
+}
+
+func TestTokenSignerKey(t *testing.T) {
+	path   := t.TempDir() + "/secret.key"
+	store  := NewMemoryStore()
+	signer, err := LoadTokenSigner(path, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, _ := signer.Issue("alice", "", -1)
+	loaded, err := LoadTokenSigner(path, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loaded.Verify(token); err != nil {
+		t.Errorf("a token doesn't survive a restart: %v", err)
+	}
+}
+
+func TestTokenRevocationsSurviveRestart(t *testing.T) {
+	dir    := t.TempDir()
+	key    := dir + "/secret.key"
+	signer, err := LoadTokenSigner(key, openStore(t, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loggedOut, _, _ := signer.Issue("alice", "", -1)
+	kept, _, _      := signer.Issue("alice", "", -1)
+	replaced, _, _  := signer.Issue("bob", "", -1)
+	if err := signer.Revoke(loggedOut); err != nil {
+		t.Fatal(err)
+	}
+	if err := signer.RevokeAccount("bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := LoadTokenSigner(key, openStore(t, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string {"logged out": loggedOut, "replaced": replaced} {
+		if _, err := restarted.Verify(token); err != ErrRevokedToken {
+			t.Errorf("the %s token after a restart: got %v, want %v", name, err, ErrRevokedToken)
+		}
+	}
+	if _, err := restarted.Verify(kept); err != nil {
+		t.Errorf("a token which isn't revoked: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	fresh, _, _ := restarted.Issue("bob", "", -1)
+	if _, err := restarted.Verify(fresh); err != nil {
+		t.Errorf("a token issued after the restart: %v", err)
+	}
+}
+
+func TestRevocationsExpire(t *testing.T) {
+	defer func(lines int) { compactLines = lines }(compactLines)
+	compactLines = 4
+	dir   := t.TempDir()
+	store := openStore(t, dir)
+	now   := time.Now().UnixNano()
+	for i := 0; i < 3; i++ {
+		if err := store.PutRevocation(Revocation {fmt.Sprint("expired-", i), "alice", 0, now - 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.PutRevocation(Revocation {"live", "alice", 0, now + int64(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if lines := countLines(t, filepath.Join(dir, revokedFile)); lines != 1 {
+		t.Errorf("%d lines after the compaction, want 1", lines)
+	}
+	revocations, err := openStore(t, dir).Revocations()
+	if err != nil || len(revocations) != 1 || revocations[0].ID != "live" {
+		t.Errorf("revocations %v, %v", revocations, err)
+	}
+}
@@ -0,0 +1,201 @@
+package ai
+
+import (
+	"math"
+
+	"mahjong/engine"
+)
+
+// ChangeTiles chooses three tiles of the weakest suit to change
+func ChangeTiles(seat engine.Seat) []engine.Tile {
+	suit := -1
+	for s := 0; s < 3; s++ {
+		if seat.Hand[s].Count() >= 3 && (suit == -1 || isWeaker(seat.Hand[s], seat.Hand[suit])) {
+			suit = s
+		}
+	}
+	var result []engine.Tile
+	hand := seat.Hand[suit]
+	for i := 0; i < 3; i++ {
+		value := leastUseful(hand)
+		result = append(result, engine.NewTile(suit, value))
+		hand.Sub(value)
+	}
+	return result
+}
+
+// ChooseLack chooses the weakest suit as lack
+func ChooseLack(seat engine.Seat) int {
+	lack := 0
+	for s := 1; s < 3; s++ {
+		if isWeaker(seat.Hand[s], seat.Hand[lack]) {
+			lack = s
+		}
+	}
+	return lack
+}
+
+// Throw chooses the tile to throw which keeps the hand closest to hu
+func Throw(state engine.GameState, id int) engine.Tile {
+	seat := state.Seats[id]
+	if seat.Hand[seat.Lack].Count() > 0 {
+		return engine.NewTile(seat.Lack, leastUseful(seat.Hand[seat.Lack]))
+	}
+
+	visible := visibleTiles(state, id)
+	best    := engine.NewTile(-1, 0)
+	max    := math.MinInt32
+	for s := 0; s < 3; s++ {
+		for v := uint(0); v < 9; v++ {
+			tile := engine.NewTile(s, v)
+			if !seat.Hand.Have(tile) {
+				continue
+			}
+			seat.Hand.Sub(tile)
+			value := evaluate(seat, visible)
+			seat.Hand.Add(tile)
+			if value > max {
+				max  = value
+				best = tile
+			}
+		}
+	}
+	return best
+}
+
+// Command chooses a command from the action set
+func Command(state engine.GameState, id int, actionSet engine.ActionSet, command int) engine.Action {
+	seat := state.Seats[id]
+	for _, name := range []string{"ZIMO", "HU"} {
+		if (command & engine.COMMAND[name]) != 0 {
+			return engine.NewAction(engine.COMMAND[name], actionSet[engine.COMMAND[name]][0], 0)
+		}
+	}
+
+	isTing := isTing(seat)
+	for _, name := range []string{"ONGON", "PONGON", "GON"} {
+		for _, tile := range actionSet[engine.COMMAND[name]] {
+			if !isTing || keepTing(seat, tile, engine.COMMAND[name]) {
+				return engine.NewAction(engine.COMMAND[name], tile, 0)
+			}
+		}
+	}
+
+	if (command & engine.COMMAND["PON"]) != 0 && !isTing && improvePon(seat, actionSet[engine.COMMAND["PON"]][0]) {
+		return engine.NewAction(engine.COMMAND["PON"], actionSet[engine.COMMAND["PON"]][0], 0)
+	}
+	return engine.NewAction(engine.COMMAND["NONE"], engine.NewTile(-1, 0), 0)
+}
+
+func evaluate(seat engine.Seat, visible engine.SuitSet) int {
+	shanten, ukeire := engine.Ukeire(seat.Hand, seat.Door, seat.Lack, visible)
+	value := 0
+	if shanten == 0 {
+		tDoor := seat.Door.Translate(seat.Lack)
+		for _, tile := range ukeire {
+			hand := seat.Hand
+			hand.Add(tile.Tile)
+			if tai := engine.CalTai(hand.Translate(seat.Lack), tDoor); tai > 0 {
+				value += tile.Unseen * int(math.Pow(2, float64(tai - 1)))
+			}
+		}
+		if value > 0 {
+			return 1000000 + value
+		}
+	}
+	for _, tile := range ukeire {
+		value += tile.Unseen
+	}
+	value = (8 - shanten) * 100000 + value * 1000
+	for s := 0; s < 3; s++ {
+		value += structure(seat.Hand[s])
+	}
+	return value
+}
+
+func isTing(seat engine.Seat) bool {
+	var max int
+	return seat.CheckTing(&max)
+}
+
+func keepTing(seat engine.Seat, tile engine.Tile, command int) bool {
+	count := seat.Hand[tile.Suit].GetIndex(tile.Value)
+	for i := uint(0); i < count; i++ {
+		seat.Hand.Sub(tile)
+		seat.Door.Add(tile)
+	}
+	if command == engine.COMMAND["GON"] {
+		seat.Door.Add(tile)
+	}
+	return isTing(seat)
+}
+
+func improvePon(seat engine.Seat, tile engine.Tile) bool {
+	shanten := engine.Shanten(seat.Hand, seat.Door, seat.Lack)
+	seat.Hand.Sub(tile)
+	seat.Hand.Sub(tile)
+	seat.Door.Add(tile)
+	seat.Door.Add(tile)
+	seat.Door.Add(tile)
+	return engine.Shanten(seat.Hand, seat.Door, seat.Lack) < shanten
+}
+
+func visibleTiles(state engine.GameState, id int) engine.SuitSet {
+	var visible engine.SuitSet
+	visible.Add(state.HuTiles.ToTileArray())
+	for i, seat := range state.Seats {
+		visible.Add(seat.DiscardTiles.ToTileArray())
+		if i != id {
+			visible.Add(seat.VisiableDoor.ToTileArray())
+		}
+	}
+	return visible
+}
+
+func usefulness(suit engine.Suit, v uint) int {
+	value := 3 * (int(suit.GetIndex(v)) - 1)
+	for d := uint(1); d <= 2; d++ {
+		weight := int(3 - d)
+		if v >= d {
+			value += weight * int(suit.GetIndex(v - d))
+		}
+		if v + d < 9 {
+			value += weight * int(suit.GetIndex(v + d))
+		}
+	}
+	return value
+}
+
+func leastUseful(suit engine.Suit) uint {
+	best := uint(0)
+	min  := math.MaxInt32
+	for v := uint(0); v < 9; v++ {
+		if suit.GetIndex(v) == 0 {
+			continue
+		}
+		value := usefulness(suit, v)
+		if v == 0 || v == 8 {
+			value--
+		}
+		if value < min {
+			min  = value
+			best = v
+		}
+	}
+	return best
+}
+
+func isWeaker(a engine.Suit, b engine.Suit) bool {
+	if a.Count() != b.Count() {
+		return a.Count() < b.Count()
+	}
+	return structure(a) < structure(b)
+}
+
+func structure(suit engine.Suit) int {
+	value := 0
+	for v := uint(0); v < 9; v++ {
+		value += int(suit.GetIndex(v)) * usefulness(suit, v)
+	}
+	return value
+}
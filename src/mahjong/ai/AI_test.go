@@ -0,0 +1,136 @@
+package ai
+
+import (
+	"os"
+	"testing"
+
+	"mahjong/engine"
+)
+
+func TestMain(m *testing.M) {
+	engine.InitHuTable("")
+	os.Exit(m.Run())
+}
+
+// ting is a hand of 平胡 which waits for d2, d5 and d8
+var ting = []string{"c2", "c3", "c4", "c5", "c6", "c7", "d2", "d3", "d4", "d5", "d6", "d7", "d8"}
+
+func suitSet(tiles ...string) engine.SuitSet {
+	return engine.ArrayToSuitSet(engine.StringArrayToTileArray(tiles))
+}
+
+// playing returns a hand in progress where the first seat holds the hand at
+// the step and every seat lacks b
+func playing(step int, hand []string) engine.GameState {
+	state           := engine.NewGameState([4]string{"A", "B", "C", "D"}, 1, engine.RulePresets["standard"], 0)
+	state.Phase      = engine.Playing
+	state.Step       = step
+	state.Wall       = engine.Wall {Tiles: make([]engine.Tile, 108), Tail: 50}
+	state.HuTiles    = engine.NewSuitSet(false)
+	state.Waiting[0] = true
+	state.SetStake(1, [4]int{-1, -1, -1, -1})
+	for i := 0; i < 4; i++ {
+		state.Seats[i].Lack = 2
+	}
+	state.Seats[0].Hand = suitSet(hand...)
+	return state
+}
+
+// decide decides the move of the seat as a bot seated by the server does
+func decide(state engine.GameState, id int) engine.Move {
+	legal := engine.LegalActions(state, id)
+	seat  := state.Seats[id]
+	switch legal[0].Type {
+	case engine.MoveChange:
+		return engine.NewChangeMove(id, ChangeTiles(seat))
+	case engine.MoveLack:
+		return engine.NewLackMove(id, ChooseLack(seat))
+	}
+	actionSet, command := engine.ToActionSet(legal)
+	if command != engine.COMMAND["NONE"] {
+		act := Command(state, id, actionSet, command)
+		if act.Command != engine.COMMAND["NONE"] || state.Step != engine.StepDraw {
+			return engine.NewCommandMove(id, act.Command, act.Tile)
+		}
+	}
+	return engine.NewThrowMove(id, Throw(state, id))
+}
+
+func TestThrowLackFirst(t *testing.T) {
+	state := playing(engine.StepThrow, append([]string{"b5"}, ting...))
+	tile  := Throw(state, 0)
+	if tile.ToString() != "b5" {
+		t.Errorf("throws %s, want the lack tile b5", tile.ToString())
+	}
+	if !engine.IsLegal(state, engine.NewThrowMove(0, tile)) {
+		t.Errorf("throwing %s isn't legal", tile.ToString())
+	}
+}
+
+func TestThrowKeepsTing(t *testing.T) {
+	state := playing(engine.StepThrow, append([]string{"c9"}, ting...))
+	tile  := Throw(state, 0)
+	if !engine.IsLegal(state, engine.NewThrowMove(0, tile)) {
+		t.Fatalf("throwing %s isn't legal", tile.ToString())
+	}
+	seat := state.Seats[0]
+	seat.Hand.Sub(tile)
+	if shanten := engine.Shanten(seat.Hand, seat.Door, seat.Lack); shanten != 0 {
+		t.Errorf("throwing %s leaves the hand %d from ting", tile.ToString(), shanten)
+	}
+}
+
+func TestCommandHu(t *testing.T) {
+	zimo          := playing(engine.StepDraw, append([]string{"d5"}, ting...))
+	zimo.DrawTile  = engine.StringToTile("d5")
+	react         := playing(engine.StepReact, ting)
+	react.Current  = 1
+	react.Tile     = engine.StringToTile("d8")
+	cases := []struct {
+		name    string
+		state   engine.GameState
+		command string
+	}{
+		{"zimo", zimo,  "ZIMO"},
+		{"hu",   react, "HU"},
+	}
+	for _, c := range cases {
+		move := decide(c.state, 0)
+		if move.Type != engine.MoveCommand || move.Command != engine.COMMAND[c.command] {
+			t.Errorf("%s: decided %+v, want %s", c.name, move, c.command)
+		}
+		if !engine.IsLegal(c.state, move) {
+			t.Errorf("%s: %+v isn't legal", c.name, move)
+		}
+	}
+}
+
+func TestLegalMoves(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		state, _ := engine.Deal(engine.NewGameState([4]string{"A", "B", "C", "D"}, seed, engine.RulePresets["standard"], 0))
+		started  := false
+		for moves := 0; !state.IsOver(); moves++ {
+			seats := state.WaitingSeats()
+			if len(seats) == 0 && !started {
+				state, _ = engine.Start(state)
+				started  = true
+				continue
+			}
+			if len(seats) == 0 || moves > 1000 {
+				t.Fatalf("seed %d: the hand is stuck after %d moves", seed, moves)
+			}
+			game := state
+			for _, id := range seats {
+				move := decide(game, id)
+				if err := engine.Check(game, move); err != nil {
+					t.Fatalf("seed %d: %+v is illegal: %v", seed, move, err)
+				}
+				next, _, err := engine.Apply(state, move)
+				if err != nil {
+					t.Fatalf("seed %d: %v", seed, err)
+				}
+				state = next
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"encoding/json"
+)
+
+// COMMAND is a map of command type
+var COMMAND = map[string]int{
+	"NONE":   0,
+	"PON":    1,
+	"GON":    2,
+	"ONGON":  4,
+	"PONGON": 8,
+	"HU":     16,
+	"ZIMO":   32,
+}
+
+// NewAction creates a new action
+func NewAction(command int, tile Tile, score int) Action {
+	return Action{command, tile, score}
+}
+
+// NewActionSet creates a new action set
+func NewActionSet() ActionSet {
+	return make(ActionSet)
+}
+
+// Action represent a command made by player
+type Action struct {
+	Command int
+	Tile    Tile
+	Score   int
+}
+
+// ToJSON converts action to json string
+func (act Action) ToJSON() string {
+	type Tmp struct {
+		Command int
+		Tile    string
+		Score   int
+	}
+	tmp     := Tmp {act.Command, act.Tile.ToString(), act.Score}
+	JSON, _ := json.Marshal(tmp)
+	return string(JSON)
+}
+
+// JSONToAction converts json string to action
+func JSONToAction(actionStr string) Action {
+	type Tmp struct {
+		Command int
+		Tile    string
+		Score   int
+	}
+	var t Tmp
+	json.Unmarshal([]byte(actionStr), &t)
+	return NewAction(t.Command, StringToTile(t.Tile), t.Score)
+}
+
+// ActionSet represents a set of action
+type ActionSet map[int][]Tile
+
+// ToJSON converts action set to json string
+func (set ActionSet) ToJSON() string {
+	type Tmp struct {
+		Key   int
+		Value []string
+	}
+	var tmpSet []Tmp
+	for key, value := range set {
+		t     := ArrayToSuitSet(value)
+		tmp   := Tmp {key, t.ToStringArray()}
+		tmpSet = append(tmpSet, tmp)
+	}
+	JSON, _ := json.Marshal(tmpSet)
+	return string(JSON)
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"fmt"
+)
+
+// Rejection code of an illegal move
+const (
+	ErrNotWaiting  = "notWaiting"
+	ErrWrongMove   = "wrongMove"
+	ErrTileCount   = "tileCount"
+	ErrMixedSuit   = "mixedSuit"
+	ErrNotHeld     = "notHeld"
+	ErrInvalidLack = "invalidLack"
+	ErrLackFirst   = "lackFirst"
+	ErrNotOffered  = "notOffered"
+	ErrTileOffered = "tileNotOffered"
+	ErrIllegal     = "illegal"
+)
+
+// MoveError tells why a move is rejected
+type MoveError struct {
+	Code    string `json:"code"`
+	Seat    int    `json:"seat"`
+	Message string `json:"message"`
+	Move    Move   `json:"-"`
+}
+
+// Error returns the message of the error
+func (err *MoveError) Error() string {
+	return fmt.Sprintf("illegal move of seat %d: %s", err.Seat, err.Message)
+}
+
+// Is makes every move error match ErrIllegalMove
+func (err *MoveError) Is(target error) bool {
+	return target == ErrIllegalMove
+}
+
+// Check returns nil if the move is legal, else a MoveError which tells why
+func Check(state GameState, move Move) error {
+	if IsLegal(state, move) {
+		return nil
+	}
+	code, message := diagnose(state, move)
+	return &MoveError {code, move.Seat, message, move}
+}
+
+func diagnose(state GameState, move Move) (string, string) {
+	legal := LegalActions(state, move.Seat)
+	if len(legal) == 0 {
+		return ErrNotWaiting, "not waiting for the seat"
+	}
+	expected := false
+	for _, other := range legal {
+		expected = expected || other.Type == move.Type
+	}
+	if !expected {
+		return ErrWrongMove, "the move is not expected now"
+	}
+
+	seat := state.Seats[move.Seat]
+	switch move.Type {
+	case MoveChange:
+		if len(move.Tiles) != 3 {
+			return ErrTileCount, "three tiles have to be changed"
+		}
+		for _, tile := range move.Tiles {
+			if tile.Suit != move.Tiles[0].Suit {
+				return ErrMixedSuit, "the tiles to change have to be of one suit"
+			}
+		}
+		if !seat.holds(move.Tiles) {
+			return ErrNotHeld, "the tiles to change are not in hand"
+		}
+	case MoveLack:
+		if move.Lack < 0 || move.Lack >= 3 {
+			return ErrInvalidLack, "lack has to be a suit"
+		}
+	case MoveThrow:
+		if !seat.holds([]Tile{move.Tile}) {
+			return ErrNotHeld, "the tile " + move.Tile.ToString() + " is not in hand"
+		}
+		if seat.Lack >= 0 && seat.Hand[seat.Lack].Count() > 0 && move.Tile.Suit != seat.Lack {
+			return ErrLackFirst, "the tiles of lack have to be thrown first"
+		}
+	case MoveCommand:
+		actionSet, command := ToActionSet(legal)
+		if move.Command == COMMAND["NONE"] {
+			return ErrWrongMove, "a tile has to be thrown to pass"
+		}
+		if command & move.Command == 0 {
+			return ErrNotOffered, "the command is not offered"
+		}
+		for _, tile := range actionSet[move.Command] {
+			if tile == move.Tile {
+				return ErrIllegal, "the move is illegal"
+			}
+		}
+		return ErrTileOffered, "the tile " + move.Tile.ToString() + " is not offered for the command"
+	}
+	return ErrIllegal, "the move is illegal"
+}
+
+func (seat Seat) holds(tiles []Tile) bool {
+	need := NewSuitSet(false)
+	for _, tile := range tiles {
+		if tile.Suit < 0 || tile.Suit >= 3 || tile.Value >= 9 {
+			return false
+		}
+		need.Add(tile)
+		if need[tile.Suit].GetIndex(tile.Value) > seat.Hand[tile.Suit].GetIndex(tile.Value) {
+			return false
+		}
+	}
+	return true
+}
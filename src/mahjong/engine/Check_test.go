@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	InitHuTable("")
+	os.Exit(m.Run())
+}
+
+var testNames = [4]string{"A", "B", "C", "D"}
+
+// dealt returns a hand of the standard rules after the deal
+func dealt() GameState {
+	state, _ := Deal(NewGameState(testNames, 7, RulePresets["standard"], 0))
+	return state
+}
+
+// apply applies the move and fails the test if it's illegal
+func apply(t *testing.T, state GameState, move Move) GameState {
+	next, _, err := Apply(state, move)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return next
+}
+
+// changed returns the hand after every seat changed tiles
+func changed(t *testing.T) GameState {
+	state := dealt()
+	for i := 0; i < 4; i++ {
+		state = apply(t, state, LegalActions(state, i)[0])
+	}
+	return state
+}
+
+// lacked returns the hand after every seat chose lack, the dealer chooses
+// a suit it holds with other tiles
+func lacked(t *testing.T) GameState {
+	state := changed(t)
+	for i := 0; i < 4; i++ {
+		lack := 0
+		for i == 0 && state.Seats[0].Hand[lack].Count() == 0 {
+			lack++
+		}
+		state = apply(t, state, NewLackMove(i, lack))
+	}
+	return state
+}
+
+// missing returns a tile which isn't in the hand
+func missing(hand SuitSet) Tile {
+	for s := 0; s < 3; s++ {
+		for v := uint(0); v < 9; v++ {
+			if hand[s].GetIndex(v) == 0 {
+				return NewTile(s, v)
+			}
+		}
+	}
+	return NewTile(-1, 0)
+}
+
+func TestCheck(t *testing.T) {
+	change     := dealt()
+	hand       := change.Seats[0].Hand.ToTileArray()
+	lack       := changed(t)
+	playing, _ := Start(lacked(t))
+	dealer     := playing.Seats[0]
+	var other Tile
+	for _, tile := range dealer.Hand.ToTileArray() {
+		if tile.Suit != dealer.Lack {
+			other = tile
+		}
+	}
+
+	cases := []struct {
+		name  string
+		state GameState
+		move  Move
+		code  string
+	}{
+		{"change two tiles",   change,  NewChangeMove(0, hand[:2]), ErrTileCount},
+		{"change mixed suits", change,  NewChangeMove(0, []Tile{NewTile(0, 0), NewTile(1, 0), NewTile(2, 0)}), ErrMixedSuit},
+		{"throw while change", change,  NewThrowMove(0, hand[0]), ErrWrongMove},
+		{"wrong lack",         lack,    NewLackMove(0, 3), ErrInvalidLack},
+		{"negative lack",      lack,    NewLackMove(1, -1), ErrInvalidLack},
+		{"wrong turn",         playing, NewThrowMove(1, playing.Seats[1].Hand.At(0)), ErrNotWaiting},
+		{"not in hand",        playing, NewThrowMove(0, missing(dealer.Hand)), ErrNotHeld},
+		{"lack first",         playing, NewThrowMove(0, other), ErrLackFirst},
+		{"pass on draw",       playing, NewCommandMove(0, COMMAND["NONE"], NewTile(-1, 0)), ErrWrongMove},
+		{"unknown seat",       playing, NewThrowMove(4, dealer.Hand.At(0)), ErrNotWaiting},
+	}
+	for _, c := range cases {
+		err := Check(c.state, c.move)
+		var moveErr *MoveError
+		if !errors.As(err, &moveErr) {
+			t.Errorf("%s: got %v, want a MoveError", c.name, err)
+			continue
+		}
+		if moveErr.Code != c.code {
+			t.Errorf("%s: got code %s, want %s", c.name, moveErr.Code, c.code)
+		}
+		if !errors.Is(err, ErrIllegalMove) {
+			t.Errorf("%s: the error doesn't match ErrIllegalMove", c.name)
+		}
+		if _, _, err := Apply(c.state, c.move); err == nil {
+			t.Errorf("%s: the move is applied", c.name)
+		}
+	}
+
+	for _, move := range LegalActions(playing, 0) {
+		if err := Check(playing, move); err != nil {
+			t.Errorf("legal move %v is rejected: %v", move, err)
+		}
+	}
+}
+
+func TestApplyKeepsState(t *testing.T) {
+	state  := dealt()
+	before := state.Seats[0].Hand
+	next   := apply(t, state, LegalActions(state, 0)[0])
+	if state.Seats[0].Hand != before || !state.Waiting[0] {
+		t.Error("the given state is modified")
+	}
+	if next.Seats[0].Hand.Count() != before.Count() - 3 || next.Waiting[0] {
+		t.Error("the change isn't applied")
+	}
+}
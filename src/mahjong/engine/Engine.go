@@ -0,0 +1,370 @@
+package engine
+
+// Apply applies the move to the game state and returns the next state
+// and the events happened, the given state is never modified. An illegal
+// move returns a MoveError
+func Apply(state GameState, move Move) (GameState, []Event, error) {
+	if err := Check(state, move); err != nil {
+		return state, nil, err
+	}
+	next := state.Clone()
+	switch move.Type {
+	case MoveChange:
+		next.change(move.Seat, move.Tiles)
+	case MoveLack:
+		next.chooseLack(move.Seat, move.Lack)
+	case MoveThrow:
+		next.throw(move.Seat, move.Tile)
+	case MoveCommand:
+		next.command(move)
+	}
+	result, events := next.flush()
+	return result, events, nil
+}
+
+func (state *GameState) change(id int, tiles []Tile) {
+	state.Seats[id].Hand.Sub(tiles)
+	state.Seats[id].ChangedTiles = tiles
+	state.Waiting[id]            = false
+	state.emit(Event {Type: EventChange, Seat: id, From: -1, Tile: NewTile(-1, 0), Tiles: tiles})
+	if !state.isAllResponsed() {
+		return
+	}
+
+	state.Offset = int(state.random().Int31n(3))
+	for i := 0; i < 4; i++ {
+		to := (i + state.Offset + 1) % 4
+		state.Seats[to].Hand.Add(state.Seats[i].ChangedTiles)
+	}
+	for i := 0; i < 4; i++ {
+		from := (i + 3 - state.Offset) % 4
+		state.emit(Event {Type: EventAfterChange, Seat: i, From: from, Tile: NewTile(-1, 0), Tiles: state.Seats[from].ChangedTiles, Value: state.Offset})
+	}
+	state.startLack()
+}
+
+// startLack waits for every seat to choose lack, or chooses the suit
+// with least tiles for them if the rules skip it
+func (state *GameState) startLack() {
+	state.Phase = ChangeTile
+	if state.Rules.ChooseLack {
+		state.setWaiting(true)
+		return
+	}
+	state.setWaiting(false)
+	for i := 0; i < 4; i++ {
+		lack := 0
+		for s := 1; s < 3; s++ {
+			if state.Seats[i].Hand[s].Count() < state.Seats[i].Hand[lack].Count() {
+				lack = s
+			}
+		}
+		state.chooseLack(i, lack)
+	}
+}
+
+func (state *GameState) chooseLack(id int, lack int) {
+	state.Seats[id].Lack = lack
+	state.Waiting[id]    = false
+	state.emit(Event {Type: EventLack, Seat: id, From: -1, Tile: NewTile(-1, 0), Value: lack})
+	if state.isAllResponsed() {
+		state.Phase = ChooseLack
+	}
+}
+
+func (state *GameState) turn(id int) {
+	seat    := &state.Seats[id]
+	command := COMMAND["NONE"]
+	var tile Tile
+	if seat.JustGon {
+		command = COMMAND["GON"]
+		tile    = state.Wall.DrawBack()
+	} else {
+		tile = state.Wall.Draw()
+	}
+	state.Current  = id
+	state.Step     = StepDraw
+	state.DrawTile = tile
+	state.Tile     = NewTile(-1, 0)
+	seat.Hand.Add(tile)
+	state.setWaiting(false)
+	state.emit(Event {Type: EventDraw, Seat: id, From: -1, Tile: tile, Command: command, Value: state.RemainCount()})
+
+	if !seat.IsHu {
+		state.Waiting[id] = true
+		return
+	}
+	actionSet, command := state.drawActions(id)
+	if (command & COMMAND["ZIMO"]) != 0 {
+		state.zimo(id)
+	} else if (command & COMMAND["ONGON"]) != 0 {
+		state.onGon(id, actionSet[COMMAND["ONGON"]][0])
+	} else if (command & COMMAND["PONGON"]) != 0 {
+		state.ponGon(id, actionSet[COMMAND["PONGON"]][0])
+	} else {
+		state.throw(id, tile)
+	}
+}
+
+func (state *GameState) advance(next int, onlyThrow bool) {
+	if next != state.Current {
+		state.Seats[state.Current].JustGon = false
+	}
+	if state.Wall.IsEmpty() || state.Rules.Mode == BloodBattle && state.huCount() >= 3 {
+		state.end()
+		return
+	}
+	for !state.inHand(next) {
+		next = (next + 1) % 4
+	}
+	if onlyThrow {
+		state.Current  = next
+		state.Step     = StepThrow
+		state.DrawTile = NewTile(-1, 0)
+		state.Tile     = NewTile(-1, 0)
+		state.setWaiting(false)
+		state.Waiting[next] = true
+	} else {
+		state.turn(next)
+	}
+}
+
+func (state *GameState) throw(id int, tile Tile) {
+	state.Seats[id].Hand.Sub(tile)
+	state.emit(Event {Type: EventThrow, Seat: id, From: -1, Tile: tile})
+
+	state.Step = StepReact
+	state.Tile = tile
+	state.setWaiting(false)
+	for i := 1; i < 4; i++ {
+		otherID := (i + id) % 4
+		_, command := state.reactActions(otherID, tile)
+		if command == COMMAND["NONE"] {
+			continue
+		}
+		if state.Seats[otherID].IsHu {
+			if (command & COMMAND["HU"]) != 0 {
+				state.Responses[otherID] = NewCommandMove(otherID, COMMAND["HU"], tile)
+			} else {
+				state.Responses[otherID] = NewCommandMove(otherID, COMMAND["GON"], tile)
+			}
+		} else {
+			state.Waiting[otherID] = true
+		}
+	}
+	if state.isAllResponsed() {
+		state.resolveThrow()
+	}
+}
+
+func (state *GameState) command(move Move) {
+	if state.Step == StepReact || state.Step == StepRobGon {
+		state.Responses[move.Seat] = move
+		state.Waiting[move.Seat]   = false
+		if !state.isAllResponsed() {
+			return
+		}
+		if state.Step == StepReact {
+			state.resolveThrow()
+		} else {
+			state.resolveRobGon()
+		}
+		return
+	}
+
+	switch move.Command {
+	case COMMAND["ZIMO"]:
+		state.zimo(move.Seat)
+	case COMMAND["ONGON"]:
+		state.onGon(move.Seat, move.Tile)
+	case COMMAND["PONGON"]:
+		state.ponGon(move.Seat, move.Tile)
+	}
+}
+
+func (state *GameState) zimo(id int) {
+	tile  := state.DrawTile
+	score := state.hu(id, tile, COMMAND["ZIMO"], false, true, -1)
+	state.success(id, id, COMMAND["ZIMO"], tile, score)
+	state.advance((id + 1) % 4, false)
+}
+
+func (state *GameState) onGon(id int, tile Tile) {
+	score := state.gon(id, tile, COMMAND["ONGON"], -1)
+	state.success(id, id, COMMAND["ONGON"], tile, score)
+	state.advance(id, false)
+}
+
+func (state *GameState) ponGon(id int, tile Tile) {
+	state.Step = StepRobGon
+	state.Tile = tile
+	state.setWaiting(false)
+	for i := 1; i < 4; i++ {
+		otherID := (i + id) % 4
+		_, command := state.robActions(otherID, tile)
+		if command == COMMAND["NONE"] {
+			continue
+		}
+		if state.Seats[otherID].IsHu {
+			state.Responses[otherID] = NewCommandMove(otherID, COMMAND["HU"], tile)
+		} else {
+			state.Waiting[otherID] = true
+		}
+	}
+	if state.isAllResponsed() {
+		state.resolveRobGon()
+	}
+}
+
+func (state *GameState) resolveThrow() {
+	currentIdx := state.Current
+	tile       := state.Tile
+	ponIdx, gonIdx, huIdx := -1, -1, -1
+	for i := 1; i < 4; i++ {
+		playerID  := (i + currentIdx) % 4
+		playerAct := state.Responses[playerID]
+		if (playerAct.Command & COMMAND["HU"]) != 0 {
+			score := state.hu(playerID, tile, COMMAND["HU"], false, huIdx == -1, currentIdx)
+			huIdx  = playerID
+			state.success(currentIdx, playerID, COMMAND["HU"], tile, score)
+		} else if (playerAct.Command & COMMAND["GON"]) != 0 {
+			if huIdx == -1 && gonIdx == -1 {
+				gonIdx = playerID
+			} else {
+				state.fail(playerID, playerAct.Command)
+			}
+		} else if (playerAct.Command & COMMAND["PON"]) != 0 {
+			if huIdx == -1 && gonIdx == -1 && ponIdx == -1 {
+				ponIdx = playerID
+			} else {
+				state.fail(playerID, playerAct.Command)
+			}
+		}
+	}
+
+	if huIdx != -1 {
+		if gonIdx != -1 {
+			state.fail(gonIdx, COMMAND["GON"])
+		}
+		if ponIdx != -1 {
+			state.fail(ponIdx, COMMAND["PON"])
+		}
+		state.advance((huIdx + 1) % 4, false)
+	} else if gonIdx != -1 {
+		score := state.gon(gonIdx, tile, COMMAND["GON"], currentIdx)
+		state.success(currentIdx, gonIdx, COMMAND["GON"], tile, score)
+		state.advance(gonIdx, false)
+	} else if ponIdx != -1 {
+		state.success(currentIdx, ponIdx, COMMAND["PON"], tile, 0)
+		state.pon(ponIdx, tile)
+		state.advance(ponIdx, true)
+	} else {
+		state.Seats[currentIdx].DiscardTiles.Add(tile)
+		state.emit(Event {Type: EventDiscard, Seat: currentIdx, From: -1, Tile: tile})
+		state.advance((currentIdx + 1) % 4, false)
+	}
+}
+
+func (state *GameState) resolveRobGon() {
+	currentIdx := state.Current
+	tile       := state.Tile
+	huIdx      := -1
+	for i := 1; i < 4; i++ {
+		playerID := (i + currentIdx) % 4
+		if (state.Responses[playerID].Command & COMMAND["HU"]) != 0 {
+			score := state.hu(playerID, tile, COMMAND["HU"], true, huIdx == -1, currentIdx)
+			state.success(currentIdx, playerID, COMMAND["HU"], tile, score)
+			if huIdx == -1 {
+				state.Seats[currentIdx].Hand.Sub(tile)
+			}
+			huIdx = playerID
+		}
+	}
+
+	if huIdx != -1 {
+		state.fail(currentIdx, COMMAND["PONGON"])
+		state.emit(Event {Type: EventRobGon, Seat: currentIdx, From: huIdx, Tile: tile})
+		state.advance((huIdx + 1) % 4, false)
+	} else {
+		score := state.gon(currentIdx, tile, COMMAND["PONGON"], -1)
+		state.success(currentIdx, currentIdx, COMMAND["PONGON"], tile, score)
+		state.advance(currentIdx, false)
+	}
+}
+
+func (state *GameState) success(from int, to int, command int, tile Tile, score int) {
+	state.emit(Event {Type: EventCommand, Seat: to, From: from, Tile: tile, Command: command, Score: score})
+}
+
+func (state *GameState) fail(id int, command int) {
+	state.emit(Event {Type: EventFail, Seat: id, From: -1, Tile: NewTile(-1, 0), Command: command})
+}
+
+func (state *GameState) drawActions(id int) (ActionSet, int) {
+	seat      := &state.Seats[id]
+	actionSet := NewActionSet()
+	command   := 0
+	tai       := 0
+	if seat.CheckHu(NewTile(-1, 0), &tai) {
+		command |= COMMAND["ZIMO"]
+		actionSet[COMMAND["ZIMO"]] = append(actionSet[COMMAND["ZIMO"]], state.DrawTile)
+	}
+	for s := 0; s < 3 && !state.Wall.IsEmpty(); s++ {
+		for v := uint(0); v < 9; v++ {
+			tmpTile := NewTile(s, v)
+
+			if seat.Hand[s].GetIndex(v) == 4 {
+				seat.Hand.Sub(tmpTile)
+				if seat.CheckGon(tmpTile) {
+					command |= COMMAND["ONGON"]
+					actionSet[COMMAND["ONGON"]] = append(actionSet[COMMAND["ONGON"]], tmpTile)
+				}
+				seat.Hand.Add(tmpTile)
+			} else if seat.Hand[s].GetIndex(v) == 1 && seat.Door[s].GetIndex(v) == 3 {
+				seat.Hand.Sub(tmpTile)
+				if seat.CheckGon(tmpTile) {
+					command |= COMMAND["PONGON"]
+					actionSet[COMMAND["PONGON"]] = append(actionSet[COMMAND["PONGON"]], tmpTile)
+				}
+				seat.Hand.Add(tmpTile)
+			}
+		}
+	}
+	return actionSet, command
+}
+
+func (state *GameState) reactActions(id int, tile Tile) (ActionSet, int) {
+	seat      := &state.Seats[id]
+	actionSet := NewActionSet()
+	command   := 0
+	tai       := 0
+	if !state.inHand(id) {
+		return actionSet, command
+	}
+	if seat.CheckHu(tile, &tai) {
+		command |= COMMAND["HU"]
+		actionSet[COMMAND["HU"]] = append(actionSet[COMMAND["HU"]], tile)
+	}
+	if seat.Hand[tile.Suit].GetIndex(tile.Value) == 3 && !state.Wall.IsEmpty() {
+		if seat.CheckGon(tile) {
+			command |= COMMAND["GON"]
+			actionSet[COMMAND["GON"]] = append(actionSet[COMMAND["GON"]], tile)
+		}
+	}
+	if seat.CheckPon(tile) {
+		command |= COMMAND["PON"]
+		actionSet[COMMAND["PON"]] = append(actionSet[COMMAND["PON"]], tile)
+	}
+	return actionSet, command
+}
+
+func (state *GameState) robActions(id int, tile Tile) (ActionSet, int) {
+	actionSet := NewActionSet()
+	command   := 0
+	tai       := 0
+	if state.inHand(id) && state.Seats[id].CheckHu(tile, &tai) {
+		command |= COMMAND["HU"]
+		actionSet[COMMAND["HU"]] = append(actionSet[COMMAND["HU"]], tile)
+	}
+	return actionSet, command
+}
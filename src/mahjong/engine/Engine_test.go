@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"testing"
+)
+
+// eager returns a hu or a zimo of the seat if it can win, else it lacks the
+// suit it has fewest of, throws the tile which keeps the hand closest to hu
+// and passes every other command
+func eager(state GameState, id int) Move {
+	legal := LegalActions(state, id)
+	seat  := state.Seats[id]
+	best  := legal[0]
+	least := 99
+	for _, move := range legal {
+		switch move.Type {
+		case MoveCommand:
+			if move.Command == COMMAND["HU"] || move.Command == COMMAND["ZIMO"] {
+				return move
+			}
+		case MoveLack:
+			if count := int(seat.Hand[move.Lack].Count()); count < least {
+				best, least = move, count
+			}
+		case MoveThrow:
+			hand := seat.Hand
+			hand.Sub(move.Tile)
+			if shanten := Shanten(hand, seat.Door, seat.Lack); shanten < least {
+				best, least = move, shanten
+			}
+		}
+	}
+	return best
+}
+
+func TestHandEnds(t *testing.T) {
+	cases := []struct {
+		name  string
+		rules string
+		won   []int
+		empty bool
+		over  bool
+		next  int
+	}{
+		{"battle, two won",    "chengdu",  []int{1, 2},    false, false, 3},
+		{"battle, three won",  "chengdu",  []int{1, 2, 3}, false, true,  -1},
+		{"battle, wall empty", "chengdu",  []int{1},       true,  true,  -1},
+		{"flow, two won",      "standard", []int{1, 2},    false, false, 1},
+		{"flow, three won",    "standard", []int{1, 2, 3}, false, false, 1},
+		{"flow, wall empty",   "standard", []int{1},       true,  true,  -1},
+	}
+	for _, c := range cases {
+		state := playing(RulePresets[c.rules], 1)
+		for _, id := range c.won {
+			state.Seats[id].IsHu = true
+		}
+		if c.empty {
+			state.Wall.Head = state.Wall.Tail
+		}
+		state.advance(1, false)
+		_, events := state.flush()
+		if state.IsOver() != c.over {
+			t.Errorf("%s: the hand is over %v, want %v", c.name, state.IsOver(), c.over)
+			continue
+		}
+		if c.over {
+			if len(events) == 0 || events[len(events) - 1].Type != EventEnd || len(state.WaitingSeats()) != 0 {
+				t.Errorf("%s: the hand ends with the events %+v", c.name, events)
+			}
+			continue
+		}
+		if events[0].Type != EventDraw || events[0].Seat != c.next {
+			t.Errorf("%s: the events %+v, want seat %d to draw", c.name, events, c.next)
+		}
+	}
+}
+
+func TestWinnerGetsNoPrompts(t *testing.T) {
+	for _, name := range []string{"chengdu", "standard"} {
+		rules := RulePresets[name]
+		ended := map[string]bool{}
+		for seed := int64(1); seed <= 50; seed++ {
+			state, _ := Deal(NewGameState(testNames, seed, rules, 0))
+			for len(state.WaitingSeats()) > 0 {
+				state = apply(t, state, eager(state, state.WaitingSeats()[0]))
+			}
+			state, _ = Start(state)
+			for !state.IsOver() {
+				seats := state.WaitingSeats()
+				if len(seats) == 0 {
+					t.Fatalf("%s seed %d: the hand is stuck", name, seed)
+				}
+				for _, id := range seats {
+					if state.Seats[id].IsHu {
+						t.Fatalf("%s seed %d: seat %d is prompted after winning", name, seed, id)
+					}
+				}
+				state = apply(t, state, eager(state, seats[0]))
+			}
+
+			won := state.huCount()
+			switch {
+			case rules.Mode == BloodBattle && won >= 3 && !state.Wall.IsEmpty():
+				ended["three won"] = true
+			case state.Wall.IsEmpty():
+				ended["wall empty"] = true
+			default:
+				t.Errorf("%s seed %d: the hand ends with %d won and %d tiles left", name, seed, won, state.RemainCount())
+			}
+			if rules.Mode == BloodBattle && won > 3 {
+				t.Errorf("%s seed %d: %d seats won", name, seed, won)
+			}
+		}
+		if rules.Mode == BloodBattle && !ended["three won"] {
+			t.Errorf("%s: no hand ends by three wins", name)
+		}
+		if rules.Mode == BloodFlow && (len(ended) != 1 || !ended["wall empty"]) {
+			t.Errorf("%s: the hands end by %v", name, ended)
+		}
+	}
+}
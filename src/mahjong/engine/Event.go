@@ -0,0 +1,49 @@
+package engine
+
+// Event type
+const (
+	EventWall        = "wall"
+	EventDeal        = "deal"
+	EventChange      = "change"
+	EventAfterChange = "afterChange"
+	EventLack        = "lack"
+	EventDraw        = "draw"
+	EventThrow       = "throw"
+	EventDiscard     = "discard"
+	EventCommand     = "command"
+	EventFail        = "fail"
+	EventRobGon      = "robGon"
+	EventPay         = "pay"
+	EventScore       = "score"
+	EventBankrupt    = "bankrupt"
+	EventEnd         = "end"
+)
+
+// Event represents something happened in a hand of mahjong
+//
+// Seat is the seat the event is about, From is the other side of a
+// command or a payment, and Value carries the event's number such as
+// the lack, the change offset, the break of the wall or the remain count
+// of the wall. A draw with Command GON is a replacement from the back.
+// A pay event moves credit between two seats and a score event adds a
+// record to the seat's score log, whose counterparty is From. The Fans of
+// a hu, the Reason of the payment and the capped part of it in Value are
+// carried by both. A bankrupt event tells the seat couldn't cover a payment
+type Event struct {
+	Type    string
+	Seat    int
+	From    int
+	Tile    Tile
+	Tiles   []Tile
+	Command int
+	Score   int
+	Value   int
+	Message string
+	Reason  string
+	Fans    []Fan
+	Dice    [2]int
+}
+
+func (state *GameState) emit(event Event) {
+	state.events = append(state.events, event)
+}
@@ -0,0 +1,72 @@
+package engine
+
+// Fan represents a named pattern of a winning hand and the tai it adds
+type Fan struct {
+	Name string
+	Tai  int
+}
+
+// huFan is a pattern of the hu table, a later one overrides the former,
+// Root means the pattern needs four same tiles
+type huFan struct {
+	Mask uint
+	Root bool
+	Fan  Fan
+}
+
+var normalFans = []huFan {
+	{7,   false, Fan {"平胡", 1}},
+	{32,  false, Fan {"對對胡", 2}},
+	{16,  false, Fan {"清一色", 3}},
+	{64,  false, Fan {"金鉤釣", 3}},
+	{256, false, Fan {"帶么九", 3}},
+	{48,  false, Fan {"清對", 4}},
+	{160, false, Fan {"將對", 4}},
+	{272, false, Fan {"清帶么九", 5}},
+	{80,  false, Fan {"清金鉤釣", 5}},
+	{192, false, Fan {"將金鉤釣", 5}},
+}
+
+var pairFans = []huFan {
+	{8,  false, Fan {"七對", 3}},
+	{24, false, Fan {"清七對", 5}},
+	{8,  true,  Fan {"龍七對", 4}},
+	{24, true,  Fan {"清龍七對", 5}},
+}
+
+// CalFan returns the named patterns which make up CalTai of the hand
+func CalFan(hand uint64, door uint64) []Fan {
+	return tableFan(huIndex(hand, door), RuleSet{})
+}
+
+// tableFan returns the patterns of an index of the tai table which count
+// in the rules, a root (根) adds one tai for each four same tiles
+func tableFan(idx uint, rules RuleSet) []Fan {
+	var patterns []huFan
+	if (idx & 7) == 7 {
+		patterns = normalFans
+	} else if (idx & 8) != 0 {
+		patterns = pairFans
+	} else {
+		return nil
+	}
+
+	var result []Fan
+	for _, pattern := range patterns {
+		if (idx & pattern.Mask) == pattern.Mask && (!pattern.Root || (idx >> 9) != 0) && rules.Count(pattern.Fan.Name) {
+			result = []Fan {pattern.Fan}
+		}
+	}
+	for i := uint(0); i < (idx >> 9) && rules.Count("根"); i++ {
+		result = append(result, Fan {"根", 1})
+	}
+	return result
+}
+
+func sumTai(fans []Fan) int {
+	tai := 0
+	for _, fan := range fans {
+		tai += fan.Tai
+	}
+	return tai
+}
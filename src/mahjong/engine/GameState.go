@@ -0,0 +1,209 @@
+package engine
+
+import (
+	"math/rand"
+)
+
+// Game phase
+const (
+	BeforeStart = iota
+	DealTile
+	ChangeTile
+	ChooseLack
+	Playing
+	GameOver
+)
+
+// Turn step
+const (
+	StepDraw = iota
+	StepThrow
+	StepReact
+	StepRobGon
+)
+
+// NewGameState creates a new game state with the name of each seat,
+// every random decision of the hand is made from the seed
+func NewGameState(names [4]string, seed int64, rules RuleSet, dealer int) GameState {
+	var state GameState
+	state.Seed    = seed
+	state.Rules   = rules
+	state.Dealer  = dealer
+	state.FirstHu = -1
+	state.Stake   = 1
+	for i := 0; i < 4; i++ {
+		state.Seats[i].Name = names[i]
+		state.Seats[i].Lack = -1
+	}
+	state.Phase    = BeforeStart
+	state.DrawTile = NewTile(-1, 0)
+	state.Tile     = NewTile(-1, 0)
+	return state
+}
+
+// GameState represents the whole state of a hand of mahjong
+//
+// Current is the seat whose turn it is, DrawTile is the tile it drew
+// this turn and Tile is the tile which waits for the response of other
+// seats in StepReact and StepRobGon, Rolls is the amount of random
+// decisions made from Seed. Rules are shared by the clones and never change.
+// Dealer is the seat which draws first and FirstHu is the seat which won
+// first, -1 if nobody won yet. Stake is the credits of a unit of score (底分)
+type GameState struct {
+	Seats     [4]Seat
+	Wall      Wall
+	HuTiles   SuitSet
+	Phase     int
+	Step      int
+	Current   int
+	DrawTile  Tile
+	Tile      Tile
+	Offset    int
+	Waiting   [4]bool
+	Responses [4]Move
+	Rules     RuleSet
+	Dealer    int
+	FirstHu   int
+	Seed      int64
+	Rolls     int64
+	Stake     int
+	events    []Event
+}
+
+// SetStake sets the credits of a unit of score and the balances of the
+// seats before the hand starts, a seat of balance -1 pays without a limit
+func (state *GameState) SetStake(stake int, balances [4]int) {
+	if stake < 1 {
+		stake = 1
+	}
+	state.Stake = stake
+	for i := 0; i < 4; i++ {
+		state.Seats[i].Balance = balances[i]
+		state.Seats[i].Limited = balances[i] >= 0
+	}
+}
+
+// Clone returns a deep copy of the game state
+func (state GameState) Clone() GameState {
+	result := state
+	for i := 0; i < 4; i++ {
+		result.Seats[i] = state.Seats[i].clone()
+		result.Responses[i].Tiles = append([]Tile(nil), state.Responses[i].Tiles...)
+	}
+	result.events = nil
+	return result
+}
+
+// WaitingSeats returns the seats whose move is waited
+func (state GameState) WaitingSeats() []int {
+	var seats []int
+	for i := 0; i < 4; i++ {
+		if state.Waiting[i] {
+			seats = append(seats, i)
+		}
+	}
+	return seats
+}
+
+// IsOver returns if the hand is over
+func (state GameState) IsOver() bool {
+	return state.Phase == GameOver
+}
+
+// RemainCount returns amount of the wall
+func (state GameState) RemainCount() int {
+	return state.Wall.Remain()
+}
+
+// Deal deals 13 tiles to each seat
+func Deal(state GameState) (GameState, []Event) {
+	next := state.Clone()
+	next.Wall    = NewWall(next.random(), next.Dealer)
+	next.HuTiles = NewSuitSet(false)
+	next.emit(Event {Type: EventWall, Seat: -1, From: -1, Tile: NewTile(-1, 0), Tiles: next.Wall.Tiles, Value: next.Wall.Break, Dice: next.Wall.Dice})
+	for k := 0; k < 4; k++ {
+		i := (next.Dealer + k) % 4
+		for j := 0; j < 13; j++ {
+			next.Seats[i].Hand.Add(next.Wall.Draw())
+		}
+		next.emit(Event {Type: EventDeal, Seat: i, From: -1, Tile: NewTile(-1, 0), Tiles: next.Seats[i].Hand.ToTileArray()})
+	}
+	next.Phase = DealTile
+	if next.Rules.ChangeTile {
+		next.setWaiting(true)
+	} else {
+		next.startLack()
+	}
+	return next.flush()
+}
+
+// Start lets the first seat draw after every seat chose lack
+func Start(state GameState) (GameState, []Event) {
+	next := state.Clone()
+	if next.Phase == ChooseLack {
+		next.Phase = Playing
+		next.turn(next.Dealer)
+	}
+	return next.flush()
+}
+
+func (state *GameState) random() *rand.Rand {
+	source := rand.NewSource(state.Seed + state.Rolls)
+	state.Rolls++
+	return rand.New(source)
+}
+
+// NextDealer returns the dealer of the next hand by the rules
+func (state GameState) NextDealer() int {
+	switch state.Rules.DealerRule {
+	case DealerNext:
+		return (state.Dealer + 1) % 4
+	case DealerStay:
+		if state.Seats[state.Dealer].IsHu {
+			return state.Dealer
+		}
+		return (state.Dealer + 1) % 4
+	}
+	if state.FirstHu == -1 {
+		return state.Dealer
+	}
+	return state.FirstHu
+}
+
+// inHand returns whether the seat still plays, a seat which won leaves
+// the hand in BloodBattle
+func (state GameState) inHand(id int) bool {
+	return state.Rules.Mode != BloodBattle || !state.Seats[id].IsHu
+}
+
+func (state GameState) huCount() int {
+	count := 0
+	for i := 0; i < 4; i++ {
+		if state.Seats[i].IsHu {
+			count++
+		}
+	}
+	return count
+}
+
+func (state *GameState) setWaiting(waiting bool) {
+	for i := 0; i < 4; i++ {
+		state.Waiting[i]   = waiting
+		state.Responses[i] = Move{}
+	}
+}
+
+func (state *GameState) isAllResponsed() bool {
+	for i := 0; i < 4; i++ {
+		if state.Waiting[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (state *GameState) flush() (GameState, []Event) {
+	events      := state.events
+	state.events = nil
+	return *state, events
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+// played plays a hand of the seed by eager and returns the final state and
+// every event of the hand
+func played(t *testing.T, seed int64) (GameState, []Event) {
+	state, events := Deal(NewGameState(testNames, seed, RulePresets["standard"], 1))
+	started       := false
+	for !state.IsOver() {
+		seats := state.WaitingSeats()
+		if len(seats) == 0 && started {
+			t.Fatalf("seed %d: the hand is stuck", seed)
+		}
+		var more []Event
+		if len(seats) == 0 {
+			state, more = Start(state)
+			started     = true
+		} else {
+			var err error
+			if state, more, err = Apply(state, eager(state, seats[0])); err != nil {
+				t.Fatal(err)
+			}
+		}
+		events = append(events, more...)
+	}
+	return state, events
+}
+
+func TestSeedReproducible(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		state, events := played(t, seed)
+		again, replay := played(t, seed)
+		if !reflect.DeepEqual(state.Wall, again.Wall) || state.Rolls != again.Rolls {
+			t.Errorf("seed %d: the walls differ", seed)
+		}
+		if !reflect.DeepEqual(events, replay) {
+			t.Errorf("seed %d: the events differ", seed)
+		}
+		if !reflect.DeepEqual(state.Result(), again.Result()) {
+			t.Errorf("seed %d: the results differ", seed)
+		}
+	}
+
+	walls := make(map[string]int64)
+	for seed := int64(1); seed <= 20; seed++ {
+		state, _ := Deal(NewGameState(testNames, seed, RulePresets["standard"], 0))
+		tiles    := ""
+		for _, tile := range state.Wall.Tiles {
+			tiles += tile.ToString()
+		}
+		if other, ok := walls[tiles]; ok {
+			t.Errorf("the seeds %d and %d shuffle the same wall", other, seed)
+		}
+		walls[tiles] = seed
+	}
+}
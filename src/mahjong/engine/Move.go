@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"errors"
+)
+
+// Move type
+const (
+	MoveChange = iota
+	MoveLack
+	MoveThrow
+	MoveCommand
+)
+
+// ErrIllegalMove is matched by every error returned when a move is not in
+// the legal actions
+var ErrIllegalMove = errors.New("illegal move")
+
+// NewChangeMove creates a move which changes three tiles
+func NewChangeMove(seat int, tiles []Tile) Move {
+	return Move {Type: MoveChange, Seat: seat, Tiles: ArrayToSuitSet(tiles).ToTileArray(), Tile: NewTile(-1, 0)}
+}
+
+// NewLackMove creates a move which chooses lack
+func NewLackMove(seat int, lack int) Move {
+	return Move {Type: MoveLack, Seat: seat, Tile: NewTile(-1, 0), Lack: lack}
+}
+
+// NewThrowMove creates a move which throws a tile
+func NewThrowMove(seat int, tile Tile) Move {
+	return Move {Type: MoveThrow, Seat: seat, Tile: tile}
+}
+
+// NewCommandMove creates a move which makes a command
+func NewCommandMove(seat int, command int, tile Tile) Move {
+	return Move {Type: MoveCommand, Seat: seat, Tile: tile, Command: command}
+}
+
+// Move represents a decision made by a seat
+type Move struct {
+	Type    int
+	Seat    int
+	Tiles   []Tile
+	Tile    Tile
+	Lack    int
+	Command int
+}
+
+// Equal returns if two moves are the same
+func (move Move) Equal(other Move) bool {
+	if move.Type != other.Type || move.Seat != other.Seat {
+		return false
+	}
+	switch move.Type {
+	case MoveChange:
+		return ArrayToSuitSet(move.Tiles) == ArrayToSuitSet(other.Tiles) && len(move.Tiles) == len(other.Tiles)
+	case MoveLack:
+		return move.Lack == other.Lack
+	case MoveThrow:
+		return move.Tile == other.Tile
+	default:
+		return move.Command == other.Command && (move.Command == COMMAND["NONE"] || move.Tile == other.Tile)
+	}
+}
+
+// LegalActions returns every move the seat can make in the game state
+func LegalActions(state GameState, seat int) []Move {
+	if seat < 0 || seat >= 4 || !state.Waiting[seat] {
+		return nil
+	}
+	var moves []Move
+	switch state.Phase {
+	case DealTile:
+		for _, tiles := range state.Seats[seat].changeCandidates() {
+			moves = append(moves, NewChangeMove(seat, tiles))
+		}
+	case ChangeTile:
+		for lack := 0; lack < 3; lack++ {
+			moves = append(moves, NewLackMove(seat, lack))
+		}
+	case Playing:
+		switch state.Step {
+		case StepDraw:
+			actionSet, _ := state.drawActions(seat)
+			moves = append(moves, commandMoves(seat, actionSet)...)
+			moves = append(moves, throwMoves(seat, state.Seats[seat])...)
+		case StepThrow:
+			moves = append(moves, throwMoves(seat, state.Seats[seat])...)
+		case StepReact:
+			actionSet, _ := state.reactActions(seat, state.Tile)
+			moves = append(moves, NewCommandMove(seat, COMMAND["NONE"], NewTile(-1, 0)))
+			moves = append(moves, commandMoves(seat, actionSet)...)
+		case StepRobGon:
+			actionSet, _ := state.robActions(seat, state.Tile)
+			moves = append(moves, NewCommandMove(seat, COMMAND["NONE"], NewTile(-1, 0)))
+			moves = append(moves, commandMoves(seat, actionSet)...)
+		}
+	}
+	return moves
+}
+
+// IsLegal checks if the move is in the legal actions
+func IsLegal(state GameState, move Move) bool {
+	for _, legal := range LegalActions(state, move.Seat) {
+		if legal.Equal(move) {
+			return true
+		}
+	}
+	return false
+}
+
+// ToActionSet converts the command moves to an action set and a command mask
+func ToActionSet(moves []Move) (ActionSet, int) {
+	actionSet := NewActionSet()
+	command   := 0
+	for _, move := range moves {
+		if move.Type == MoveCommand && move.Command != COMMAND["NONE"] {
+			command |= move.Command
+			actionSet[move.Command] = append(actionSet[move.Command], move.Tile)
+		}
+	}
+	return actionSet, command
+}
+
+func commandMoves(seat int, actionSet ActionSet) []Move {
+	var moves []Move
+	for _, name := range []string{"ZIMO", "HU", "ONGON", "PONGON", "GON", "PON"} {
+		for _, tile := range actionSet[COMMAND[name]] {
+			moves = append(moves, NewCommandMove(seat, COMMAND[name], tile))
+		}
+	}
+	return moves
+}
+
+func throwMoves(id int, seat Seat) []Move {
+	var moves []Move
+	hand := seat.Hand
+	for s := 0; s < 3; s++ {
+		if seat.Lack >= 0 && hand[seat.Lack].Count() > 0 && s != seat.Lack {
+			continue
+		}
+		for v := uint(0); v < 9; v++ {
+			if hand[s].GetIndex(v) > 0 {
+				moves = append(moves, NewThrowMove(id, NewTile(s, v)))
+			}
+		}
+	}
+	return moves
+}
+
+func (seat *Seat) changeCandidates() [][]Tile {
+	var result [][]Tile
+	for s := 0; s < 3; s++ {
+		if seat.Hand[s].Count() < 3 {
+			continue
+		}
+		for a := uint(0); a < 9; a++ {
+			for b := a; b < 9; b++ {
+				for c := b; c < 9; c++ {
+					tiles := []Tile{NewTile(s, a), NewTile(s, b), NewTile(s, c)}
+					need  := ArrayToSuitSet(tiles)
+					if need[s].GetIndex(a) <= seat.Hand[s].GetIndex(a) &&
+						need[s].GetIndex(b) <= seat.Hand[s].GetIndex(b) &&
+						need[s].GetIndex(c) <= seat.Hand[s].GetIndex(c) {
+						result = append(result, tiles)
+					}
+				}
+			}
+		}
+	}
+	return result
+}
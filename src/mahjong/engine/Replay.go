@@ -0,0 +1,87 @@
+package engine
+
+// ApplyEvent rebuilds the game state by the event, applying the events
+// of a hand in order to NewGameState gives the same state as the hand
+func ApplyEvent(state GameState, event Event) GameState {
+	next := state.Clone()
+	seat := &next.Seats[IF(event.Seat >= 0 && event.Seat < 4, event.Seat, 0).(int)]
+	switch event.Type {
+	case EventWall:
+		next.Wall    = Wall {Tiles: event.Tiles, Dice: event.Dice, Break: event.Value, Tail: len(event.Tiles)}
+		next.HuTiles = NewSuitSet(false)
+		next.Phase   = DealTile
+	case EventDeal:
+		seat.Hand.Add(event.Tiles)
+		next.Wall.Head += len(event.Tiles)
+	case EventChange:
+		seat.Hand.Sub(event.Tiles)
+		seat.ChangedTiles = event.Tiles
+	case EventAfterChange:
+		seat.Hand.Add(event.Tiles)
+		next.Offset = event.Value
+		next.Phase  = ChangeTile
+	case EventLack:
+		seat.Lack = event.Value
+		if next.Seats[0].Lack >= 0 && next.Seats[1].Lack >= 0 && next.Seats[2].Lack >= 0 && next.Seats[3].Lack >= 0 {
+			next.Phase = ChooseLack
+		}
+	case EventDraw:
+		seat.Hand.Add(event.Tile)
+		if event.Command == COMMAND["GON"] {
+			next.Wall.Tail--
+		} else {
+			next.Wall.Head++
+		}
+		next.Phase    = Playing
+		next.Current  = event.Seat
+		next.Step     = StepDraw
+		next.DrawTile = event.Tile
+		next.Tile     = event.Tile
+	case EventThrow:
+		seat.Hand.Sub(event.Tile)
+		next.Step = StepReact
+		next.Tile = event.Tile
+	case EventDiscard:
+		seat.DiscardTiles.Add(event.Tile)
+	case EventCommand:
+		next.applyCommandEvent(event)
+	case EventRobGon:
+		seat.Hand.Sub(event.Tile)
+	case EventScore:
+		seat.Credit  += event.Score
+		seat.Balance += event.Score
+		seat.ScoreLog = append(seat.ScoreLog, ScoreRecord {event.Message, event.Tile.ToString(), event.Score, event.Fans, event.Reason, event.From, event.Value})
+	case EventBankrupt:
+		seat.Bankrupt = true
+	case EventEnd:
+		next.Phase = GameOver
+		next.setWaiting(false)
+	}
+	return next
+}
+
+func (state *GameState) applyCommandEvent(event Event) {
+	seat := &state.Seats[event.Seat]
+	tile := event.Tile
+	switch event.Command {
+	case COMMAND["PON"]:
+		state.pon(event.Seat, tile)
+		state.Current = event.Seat
+		state.Step    = StepThrow
+	case COMMAND["GON"], COMMAND["ONGON"], COMMAND["PONGON"]:
+		seat.gonTile(tile, event.Command)
+	case COMMAND["HU"], COMMAND["ZIMO"]:
+		seat.IsHu = true
+		seat.HuTiles.Add(tile)
+		if state.FirstHu == -1 {
+			state.FirstHu = event.Seat
+		}
+		if event.Command == COMMAND["ZIMO"] {
+			seat.Hand.Sub(tile)
+		}
+		if state.Tile.Suit != -1 {
+			state.HuTiles.Add(tile)
+			state.Tile = NewTile(-1, 0)
+		}
+	}
+}
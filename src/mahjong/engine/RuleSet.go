@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"math"
+)
+
+// Zimo bonus
+const (
+	ZimoAddTai = iota
+	ZimoAddBase
+)
+
+// Mode of a hand, in BloodFlow (血流成河) a seat which won keeps playing until
+// the deck is empty, in BloodBattle (血戰到底) it leaves the hand and the hand
+// ends as soon as three seats won
+const (
+	BloodFlow = iota
+	BloodBattle
+)
+
+// Dealer rule, DealerFirstWinner passes the dealer to the seat which won
+// first and keeps it if nobody won, DealerNext passes it to the next seat,
+// DealerStay keeps it if the dealer won and passes it to the next seat else
+const (
+	DealerFirstWinner = iota
+	DealerNext
+	DealerStay
+)
+
+// RuleSet represents the rules of a hand
+//
+// A hu of tai scores Base * 2^(tai-1), and tai is capped by MaxTai (封頂)
+// unless it is 0. A match has Hands hands, and the dealer of the next hand
+// is decided by DealerRule. ZimoBonus tells whether a zimo adds one tai (加番) or
+// Base (加底). A seat with tiles of lack at the end pays LackPenalty (花豬).
+// If ChooseLack is false, the suit with least tiles becomes the lack.
+// OptionalFans tells whether an optional pattern counts, a pattern not
+// in it always counts. The times are the seconds a seat has to decide
+type RuleSet struct {
+	Name         string
+	Mode         int
+	Hands        int
+	DealerRule   int
+	ChangeTile   bool
+	ChooseLack   bool
+	LackPenalty  int
+	Base         int
+	MaxTai       int
+	ZimoBonus    int
+	HeavenTai    int
+	OptionalFans map[string]bool
+	ChangeTime   int
+	LackTime     int
+	ThrowTime    int
+	CommandTime  int
+}
+
+// DefaultRule is the name of the rule set which keeps the original rules
+const DefaultRule = "standard"
+
+// RulePresets are the named rule sets
+var RulePresets = map[string]RuleSet {
+	"standard": RuleSet {
+		Name: "standard", Mode: BloodFlow, Hands: 1, DealerRule: DealerFirstWinner, ChangeTile: true, ChooseLack: true, LackPenalty: 16, Base: 1, MaxTai: 0,
+		ZimoBonus: ZimoAddTai, HeavenTai: 6, OptionalFans: optionalFans(true),
+		ChangeTime: 30, LackTime: 10, ThrowTime: 10, CommandTime: 10,
+	},
+	"chengdu": RuleSet {
+		Name: "chengdu", Mode: BloodBattle, Hands: 8, DealerRule: DealerFirstWinner, ChangeTile: true, ChooseLack: true, LackPenalty: 8, Base: 1, MaxTai: 4,
+		ZimoBonus: ZimoAddBase, HeavenTai: 4, OptionalFans: optionalFans(true),
+		ChangeTime: 30, LackTime: 10, ThrowTime: 10, CommandTime: 10,
+	},
+	"simple": RuleSet {
+		Name: "simple", Mode: BloodBattle, Hands: 4, DealerRule: DealerNext, ChangeTile: false, ChooseLack: false, LackPenalty: 8, Base: 1, MaxTai: 3,
+		ZimoBonus: ZimoAddTai, HeavenTai: 3, OptionalFans: optionalFans(false),
+		ChangeTime: 30, LackTime: 10, ThrowTime: 15, CommandTime: 15,
+	},
+}
+
+// GetRuleSet returns the preset of name
+func GetRuleSet(name string) (RuleSet, bool) {
+	rules, ok := RulePresets[name]
+	return rules, ok
+}
+
+func optionalFans(count bool) map[string]bool {
+	fans := make(map[string]bool)
+	for _, name := range []string{"金鉤釣", "帶么九", "將對", "清帶么九", "清金鉤釣", "將金鉤釣", "槓上炮", "海底撈月", "海底炮", "天胡", "地胡"} {
+		fans[name] = count
+	}
+	return fans
+}
+
+// Count returns whether the pattern counts
+func (rules RuleSet) Count(name string) bool {
+	count, ok := rules.OptionalFans[name]
+	return !ok || count
+}
+
+// Score returns the score of a hu of tai
+func (rules RuleSet) Score(tai int) int {
+	if rules.MaxTai > 0 && tai > rules.MaxTai {
+		tai = rules.MaxTai
+	}
+	return rules.Base * int(math.Pow(2, float64(tai - 1)))
+}
+
+// CalFan returns the named patterns of the hand which count
+func (rules RuleSet) CalFan(hand uint64, door uint64) []Fan {
+	return tableFan(huIndex(hand, door), rules)
+}
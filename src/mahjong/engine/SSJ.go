@@ -0,0 +1,309 @@
+package engine
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// CalTai cals tai
+func CalTai(hand uint64, door uint64) int {
+	return int(taiTable[huIndex(hand, door)])
+}
+
+func huIndex(hand uint64, door uint64) uint {
+	return uint(((data(hand & 134217727) | 4) &
+		(data(hand >> 27) | 4) &
+		(data(door & 134217727) | 64) &
+		(data(door >> 27) | 64) &
+		(484 | ((data(door & 134217727) & data(door >> 27) & 16) >> 1))) |
+		(((data(hand & 134217727) & (data(door & 134217727) | 3)) | (data(hand >> 27) & (data(door >> 27) | 3))) & 19) |
+		((data((hand & 134217727) + (door & 134217727)) & 3584) + (data((hand >> 27) + (door >> 27)) & 3584)))
+}
+
+// InitHuTable loads the hu table from path, the table is built and
+// saved to path if it can not be loaded, an empty path only builds it
+func InitHuTable(path string) error {
+	if path != "" {
+		if table, err := readHuTable(path); err == nil {
+			huTable = table
+			println("Initialization Completed!")
+			return nil
+		}
+	}
+	huTable = BuildHuTable()
+	println("Initialization Completed!")
+	if path != "" {
+		return writeHuTable(path, huTable)
+	}
+	return nil
+}
+
+// BuildHuTable builds the hu table by brute force
+func BuildHuTable() []uint16 {
+	var i uint
+	gData  = make([]uint16, tableSize + 1)
+	gGroup = []int{0}
+	gEye   = []int{}
+	for i = 0; i < 9; i++ {
+		gGroup = append(gGroup, 3 << (i * 3))
+	}
+	for i = 0; i < 7; i++ {
+		gGroup = append(gGroup, 73 << (i * 3))
+	}
+	for i = 0; i < 9; i++ {
+		gEye = append(gEye, 2 << (i * 3))
+	}
+	b01(4, 0, size)
+	b2(4, 0, 1)
+	b3(7, 0, 1)
+	b4()
+	b5(4, 0, 1)
+	b6()
+	b7()
+	b8(4, 0, size)
+	b9UP()
+	table := gData
+	gData  = nil
+	return table
+}
+
+// size is every tile of a suit having four, the largest valid suit
+const size = 76695844
+// tableSize is the number of suits whose tiles are at most four each,
+// the table has an extra zero entry for invalid suits
+const tableSize = 1953125
+
+var huTable  []uint16
+var taiTable [4096]uint8
+var gData    []uint16
+var gGroup   []int
+var gEye     []int
+
+// lowIndex and highIndex map the low five and high four values of a suit
+// from base 8 to base 5
+var lowIndex  [1 << 15]int32
+var highIndex [1 << 12]int32
+
+func init() {
+	for i := range lowIndex {
+		lowIndex[i] = toBase5(i, 5)
+	}
+	for i := range highIndex {
+		highIndex[i] = toBase5(i, 4) * 3125
+	}
+	t()
+}
+
+func toBase5(suit int, digit uint) int32 {
+	result := int32(0)
+	for j := int(digit) - 1; j >= 0; j-- {
+		value := (suit >> (uint(j) * 3)) & 7
+		if value > 4 {
+			return -1
+		}
+		result = result * 5 + int32(value)
+	}
+	return result
+}
+
+func compact(suit uint64) int {
+	low  := lowIndex[suit & 32767]
+	high := highIndex[(suit >> 15) & 4095]
+	if low < 0 || high < 0 || suit >> 27 != 0 {
+		return tableSize
+	}
+	return int(low + high)
+}
+
+func expand(index int) int {
+	result := 0
+	for j := uint(0); j < 9; j++ {
+		result |= (index % 5) << (j * 3)
+		index /= 5
+	}
+	return result
+}
+
+func data(suit uint64) int {
+	return int(huTable[compact(suit)])
+}
+
+func mark(d int, flag int) {
+	if index := compact(uint64(d)); index != tableSize {
+		gData[index] |= uint16(flag)
+	}
+}
+
+func readHuTable(path string) ([]uint16, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	table := make([]uint16, tableSize + 1)
+	if err = binary.Read(reader, binary.LittleEndian, table); err != nil {
+		return nil, err
+	}
+	// the checksum of the table is only verified at the end of the stream
+	rest, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("hu table is too long")
+	}
+	return table, nil
+}
+
+func writeHuTable(path string, table []uint16) error {
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	writer := gzip.NewWriter(file)
+	err     = binary.Write(writer, binary.LittleEndian, table)
+	if err == nil {
+		err = writer.Close()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func have(m int, s int) bool {
+	for i := uint(0); i < 9; i++ {
+		if ((m >> (i * 3)) & 7) < ((s >> (i * 3)) & 7) {
+			return false
+		}
+	}
+	return true
+}
+
+func b01(n int, d int, p int) {
+	var i int
+	if n != 0 {
+		for i = 0; i < 17; i++ {
+			if have(p, gGroup[i]) {
+				b01(n - 1, d + gGroup[i], p - gGroup[i])
+			}
+		}
+	} else {
+		mark(d, 1)
+		for i = 0; i < 9; i++ {
+			if have(p, gEye[i]) {
+				mark(d + gEye[i], 2)
+			}
+		}
+	}
+}
+
+func b2(n int, d int, c int) {
+	mark(d, 4);
+	mark(d, 32);
+	if (d & 16777208) == 0 {
+		mark(d, 256);
+	}
+	if n != 0 {
+		for i := c; i <= 9; i++ {
+			b2(n - 1, d + gGroup[i], i + 1);
+			b2(n - 1, d + gGroup[i] / 3 * 4, i + 1);
+		}
+	}
+}
+
+func b3(n int, d int, c int) {
+	mark(d, 8);
+	if n != 0 {
+		for i := c; i <= 9; i++ {
+			b3(n - 1, d + gGroup[i] / 3 * 2, i + 1);
+			b3(n - 2, d + gGroup[i] / 3 * 4, i + 1);
+		}
+	}
+}
+
+func b4() {
+	mark(0, 16)
+}
+
+func b5(n int, d int, c int) {
+	var i int;
+	mark(d, 32);
+	for i = 0; i < 9; i++ {
+		if have(size - d, gEye[i]) {
+			mark(d + gEye[i], 32);
+		}
+	}
+	if n != 0 {
+		for i = c; i <= 9; i++ {
+			b5(n - 1, d + gGroup[i], i + 1);
+		}
+	}
+}
+
+func b6() {
+	mark(0, 64);
+	for i := 0; i < 9; i++ {
+		mark(gEye[i], 64);
+	}
+}
+
+func b7() {
+	for i := 0; i < tableSize; i++ {
+		if (expand(i) & 119508935) == 0 {
+			gData[i] |= 128;
+		}
+	}
+}
+
+func b8(n int, d int, p int) {
+	var i int;
+	if n != 0 {
+		for i = 0; i < 17; i++ {
+			if have(p, gGroup[i]) && (i == 0 || i == 1 || i == 9 || i == 10 || i == 16) {
+				b8(n - 1, d + gGroup[i], p - gGroup[i]);
+			}
+		}
+	} else {
+		mark(d, 256);
+		for i = 0; i < 9; i++ {
+			if have(p, gEye[i]) && (i == 0 || i == 8) {
+				mark(d + gEye[i], 256);
+			}
+		}
+	}
+}
+
+func b9UP() {
+	for i := 0; i < tableSize; i++ {
+		k := 0;
+		d := expand(i);
+		for j := uint(0); j < 9; j++ {
+			if (d & (4 << (j * 3))) != 0 {
+				k++;
+			}
+		}
+		if k > 7 {
+			k = 7;
+		}
+		gData[i] |= uint16(k << 9);
+	}
+}
+
+func t() {
+	for i := uint(0); i < 4095; i++ {
+		taiTable[i] = uint8(sumTai(tableFan(i, RuleSet{})))
+	}
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// huCases are hands of 14 tiles which lack b, and if they are hu
+var huCases = []struct {
+	name string
+	hand []string
+	door []string
+	hu   bool
+}{
+	{"平胡",        []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7", "c8", "c9", "d1", "d2", "d3", "d5", "d5"}, nil, true},
+	{"七對",        []string{"c1", "c1", "c3", "c3", "c5", "c5", "c7", "c7", "d2", "d2", "d4", "d4", "d6", "d6"}, nil, true},
+	{"清一色",      []string{"c1", "c1", "c1", "c2", "c3", "c4", "c5", "c6", "c7", "c8", "c9", "c9", "c9", "c5"}, nil, true},
+	{"with door",   []string{"c2", "c3", "c4", "c5", "c6", "c7", "d2", "d3", "d4", "d5", "d5"}, []string{"c1", "c1", "c1"}, true},
+	{"no eye",      []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7", "c8", "c9", "d1", "d2", "d3", "d5", "d6"}, nil, false},
+	{"one away",    []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7", "c8", "d1", "d2", "d5", "d5", "d9", "d9"}, nil, false},
+	{"six pairs",   []string{"c1", "c1", "c3", "c3", "c5", "c5", "c7", "c7", "d2", "d2", "d4", "d4", "d6", "d7"}, nil, false},
+	{"broken door", []string{"c2", "c3", "c4", "c5", "c6", "c7", "d2", "d3", "d4", "d5", "d7"}, []string{"c1", "c1", "c1"}, false},
+}
+
+// checkHuTable fails the test if the loaded table differs from the baseline
+// or gives a wrong answer for a case
+func checkHuTable(t *testing.T, baseline []uint16) {
+	t.Helper()
+	if len(huTable) != len(baseline) {
+		t.Fatalf("the table has %d entries, want %d", len(huTable), len(baseline))
+	}
+	for i := range baseline {
+		if huTable[i] != baseline[i] {
+			t.Fatalf("entry %d is %d, want %d", i, huTable[i], baseline[i])
+		}
+	}
+	for _, c := range huCases {
+		if tai := CalTai(suitSet(c.hand...).Translate(2), suitSet(c.door...).Translate(2)); (tai > 0) != c.hu {
+			t.Errorf("%s: %d tai, want hu %v", c.name, tai, c.hu)
+		}
+	}
+}
+
+func TestHuTableCache(t *testing.T) {
+	baseline := huTable
+	defer func() { huTable = baseline }()
+	checkHuTable(t, baseline)
+
+	path := filepath.Join(t.TempDir(), "hutable.bin")
+	if err := writeHuTable(path, baseline); err != nil {
+		t.Fatal(err)
+	}
+	huTable = nil
+	if err := InitHuTable(path); err != nil {
+		t.Fatal(err)
+	}
+	checkHuTable(t, baseline)
+}
+
+func TestCorruptHuTableCache(t *testing.T) {
+	baseline := huTable
+	defer func() { huTable = baseline }()
+	path := filepath.Join(t.TempDir(), "hutable.bin")
+	if err := writeHuTable(path, baseline); err != nil {
+		t.Fatal(err)
+	}
+	valid, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]func(data []byte) []byte {
+		"empty":     func(data []byte) []byte { return nil },
+		"truncated": func(data []byte) []byte { return data[:len(data) / 2] },
+		"payload":   func(data []byte) []byte { data[len(data) / 2] ^= 0xff; return data },
+		"checksum":  func(data []byte) []byte { data[len(data) - 8] ^= 0xff; return data },
+		"too long":  func(data []byte) []byte { return append(data, valid...) },
+	}
+	for name, corrupt := range cases {
+		data := corrupt(append([]byte{}, valid...))
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readHuTable(path); err == nil {
+			t.Errorf("%s: the corrupt table is read", name)
+		}
+		huTable = nil
+		if err := InitHuTable(path); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		checkHuTable(t, baseline)
+		if _, err := readHuTable(path); err != nil {
+			t.Errorf("%s: the rebuilt table isn't saved: %v", name, err)
+		}
+	}
+}
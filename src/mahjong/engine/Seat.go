@@ -0,0 +1,191 @@
+package engine
+
+import (
+	"strings"
+)
+
+// NewScoreRecord creates a new scoreRecord, the reason is the code of the
+// message and the counterparty is -1
+func NewScoreRecord(message string, direct string, player string, tile string, score int) ScoreRecord {
+	record := ScoreRecord {Message: message, Tile: tile, Score: score, Reason: Reasons[message], Counterparty: -1}
+	if direct != "" {
+		record.Message = strings.Join([]string{message, direct, player}, " ")
+	}
+	return record
+}
+
+// ScoreRecord represents the record of score, Reason is the code of the
+// payment and Counterparty is the other seat of it, -1 if there are several.
+// Capped is the part of a payment which the payer couldn't cover
+type ScoreRecord struct {
+	Message      string
+	Tile         string
+	Score        int
+	Fans         []Fan
+	Reason       string
+	Counterparty int
+	Capped       int
+}
+
+// Seat represents a player's state in a hand of mahjong, a Limited seat
+// pays at most its Balance and is Bankrupt once a payment of it is capped
+type Seat struct {
+	Name         string
+	Hand         SuitSet
+	Door         SuitSet
+	VisiableDoor SuitSet
+	DiscardTiles SuitSet
+	HuTiles      SuitSet
+	ChangedTiles []Tile
+	GonRecord    [4]int
+	ScoreLog     []ScoreRecord
+	Lack         int
+	Credit       int
+	Balance      int
+	Limited      bool
+	Bankrupt     bool
+	MaxTai       int
+	IsHu         bool
+	IsTing       bool
+	JustGon      bool
+	IsPenalize   bool
+}
+
+// CheckGon checks if the seat can gon
+func (seat *Seat) CheckGon(tile Tile) bool {
+	if tile.Suit == seat.Lack {
+		return false
+	}
+	if !seat.IsHu {
+		return true
+	}
+
+	huTile := seat.HuTiles.At(0)
+	seat.Hand.Add(huTile)
+	oldTai := CalTai(seat.Hand.Translate(seat.Lack), seat.Door.Translate(seat.Lack))
+	seat.Hand.Sub(huTile)
+
+	count  := int(seat.Hand[tile.Suit].GetIndex(tile.Value))
+	for i := 0; i < count; i++ {
+		seat.Hand.Sub(tile)
+		seat.Door.Add(tile)
+	}
+	seat.Door.Add(tile)
+	seat.Hand.Add(huTile)
+	newTai := CalTai(seat.Hand.Translate(seat.Lack), seat.Door.Translate(seat.Lack))
+	seat.Hand.Sub(huTile)
+	if newTai > 0 {
+		newTai--
+	}
+	for i := 0; i < count; i++ {
+		seat.Hand.Add(tile)
+		seat.Door.Sub(tile)
+	}
+	seat.Door.Sub(tile)
+	return oldTai == newTai
+}
+
+// CheckPon checks if the seat can pon
+func (seat *Seat) CheckPon(tile Tile) bool {
+	if tile.Suit == seat.Lack || seat.IsHu {
+		return false
+	}
+	return seat.Hand[tile.Suit].GetIndex(tile.Value) >= 2
+}
+
+// CheckHu checks if the seat can hu
+func (seat *Seat) CheckHu(tile Tile, tai *int) bool {
+	*tai = 0
+	if seat.Hand[seat.Lack].Count() > 0 {
+		return false
+	}
+	if tile.Suit == -1 {
+		*tai = CalTai(seat.Hand.Translate(seat.Lack), seat.Door.Translate(seat.Lack))
+	} else {
+		if tile.Suit == seat.Lack {
+			return false
+		}
+		seat.Hand.Add(tile)
+		*tai = CalTai(seat.Hand.Translate(seat.Lack), seat.Door.Translate(seat.Lack))
+		seat.Hand.Sub(tile)
+	}
+	return *tai > 0
+}
+
+// Fans returns the patterns of the seat's hand with tile in the rules,
+// a tile of suit -1 means the tile is already in the hand
+func (seat *Seat) Fans(tile Tile, rules RuleSet) []Fan {
+	hand := seat.Hand
+	if tile.Suit != -1 {
+		hand.Add(tile)
+	}
+	return rules.CalFan(hand.Translate(seat.Lack), seat.Door.Translate(seat.Lack))
+}
+
+// TingTai returns the max tai the seat can hu in the rules, 0 means the
+// seat is not ting
+func (seat *Seat) TingTai(rules RuleSet) int {
+	max := 0
+	if seat.Lack >= 0 && seat.Hand[seat.Lack].Count() > 0 {
+		return max
+	}
+	for s := 0; s < 3; s++ {
+		for v := uint(0); v < 9 && s != seat.Lack; v++ {
+			if seat.Hand[s].GetIndex(v) + seat.Door[s].GetIndex(v) >= 4 {
+				continue
+			}
+			if tai := sumTai(seat.Fans(NewTile(s, v), rules)); tai > max {
+				max = tai
+			}
+		}
+	}
+	return max
+}
+
+// CheckTing checks if the seat is ting
+func (seat *Seat) CheckTing(max *int) bool {
+	*max = 0
+	tHand := seat.Hand.Translate(seat.Lack)
+	tDoor := seat.Door.Translate(seat.Lack)
+	total := tHand + tDoor
+	for i := uint(0); i < 18; i++ {
+		if ((total >> (i * 3)) & 7) < 4 {
+			newHand := tHand + (1 << (i * 3))
+			tai     := CalTai(newHand, tDoor)
+			if tai > *max {
+				*max = tai
+			}
+		}
+	}
+	return *max > 0
+}
+
+// Tai cals the tai
+func (seat *Seat) Tai(tile Tile) int {
+	seat.Hand.Add(tile)
+	result := CalTai(seat.Hand.Translate(seat.Lack), seat.Door.Translate(seat.Lack))
+	seat.Hand.Sub(tile)
+	return result
+}
+
+// gonTile moves the tiles of a gon from the hand to the door, a GON takes 3
+// from the hand and the discarded one, an ONGON takes 4 from the hand and a
+// PONGON takes 1 to the pon on the door
+func (seat *Seat) gonTile(tile Tile, Type int) {
+	for i := 0; i < IF(Type == COMMAND["PONGON"], 1, 4).(int); i++ {
+		seat.Door.Add(tile)
+		if Type != COMMAND["ONGON"] {
+			seat.VisiableDoor.Add(tile)
+		}
+		if Type != COMMAND["GON"] || i < 3 {
+			seat.Hand.Sub(tile)
+		}
+	}
+}
+
+func (seat *Seat) clone() Seat {
+	result             := *seat
+	result.ChangedTiles = append([]Tile(nil), seat.ChangedTiles...)
+	result.ScoreLog     = append([]ScoreRecord(nil), seat.ScoreLog...)
+	return result
+}
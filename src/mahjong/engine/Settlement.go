@@ -0,0 +1,269 @@
+package engine
+
+// GameResult represents the result of mahjong, Capped is the credits the
+// seat couldn't pay and the capped payments are those of ScoreLog with
+// Capped above 0. HuTiles are the tiles of every hu of the seat, a hu whose
+// payments are capped to nothing has no Fans but its tile
+type GameResult struct {
+	Hand     []string
+	Door     []string
+	Score    int
+	ScoreLog []ScoreRecord
+	Fans     [][]Fan
+	Capped   int
+	Bankrupt bool
+	HuTiles  []string
+}
+
+// Result returns the result of each seat, Fans are the patterns of
+// every hu of the seat
+func (state GameState) Result() []GameResult {
+	var data []GameResult
+	for _, seat := range state.Seats {
+		var fans [][]Fan
+		capped := 0
+		for _, record := range seat.ScoreLog {
+			if record.Fans != nil && record.Score > 0 {
+				fans = append(fans, record.Fans)
+			}
+			capped += record.Capped
+		}
+		data = append(data, GameResult {seat.Hand.ToStringArray(), seat.Door.ToStringArray(), seat.Credit, seat.ScoreLog, fans, capped, seat.Bankrupt, seat.HuTiles.ToStringArray()})
+	}
+	return data
+}
+
+// Reasons of a payment
+const (
+	ReasonHu     = "hu"
+	ReasonZimo   = "zimo"
+	ReasonGon    = "gon"
+	ReasonPonGon = "ponGon"
+	ReasonOnGon  = "onGon"
+	ReasonLack   = "lackPenalty"
+	ReasonNoTing = "noTingPenalty"
+	ReasonRefund = "gonRefund"
+)
+
+// Reasons maps the message of a payment to its reason
+var Reasons = map[string]string {
+	"胡":   ReasonHu,
+	"自摸": ReasonZimo,
+	"槓":   ReasonGon,
+	"碰槓": ReasonPonGon,
+	"暗槓": ReasonOnGon,
+	"花豬": ReasonLack,
+	"大叫": ReasonNoTing,
+	"退稅": ReasonRefund,
+}
+
+// transfer pays the credits from a seat to another, a limited seat pays at
+// most its balance and the rest is capped. It returns the credits paid
+func (state *GameState) transfer(from int, to int, amount int, message string, tile Tile, fans []Fan) int {
+	payer  := &state.Seats[from]
+	capped := 0
+	if payer.Limited && amount > payer.Balance {
+		capped  = amount - IF(payer.Balance > 0, payer.Balance, 0).(int)
+		amount -= capped
+	}
+	state.emit(Event {Type: EventPay, Seat: to, From: from, Tile: tile, Score: amount, Value: capped, Message: message, Reason: Reasons[message], Fans: fans})
+	record := NewScoreRecord(message, "to", state.Seats[to].Name, tile.ToString(), -amount)
+	record.Fans         = fans
+	record.Counterparty = to
+	record.Capped       = capped
+	state.record(from, record)
+	if capped > 0 && !payer.Bankrupt {
+		payer.Bankrupt = true
+		state.emit(Event {Type: EventBankrupt, Seat: from, From: -1, Tile: NewTile(-1, 0)})
+	}
+	return amount
+}
+
+func (state *GameState) record(id int, record ScoreRecord) {
+	state.Seats[id].Credit  += record.Score
+	state.Seats[id].Balance += record.Score
+	state.Seats[id].ScoreLog = append(state.Seats[id].ScoreLog, record)
+	state.emit(Event {Type: EventScore, Seat: id, From: record.Counterparty, Tile: StringToTile(record.Tile), Score: record.Score, Value: record.Capped, Message: record.Message, Reason: record.Reason, Fans: record.Fans})
+}
+
+// receivedFrom returns the record of a payment received from the seat
+func receivedFrom(record ScoreRecord, id int) ScoreRecord {
+	record.Counterparty = id
+	return record
+}
+
+// huFans returns the patterns of a hu with the bonus of the way it is made
+func (state *GameState) huFans(id int, tile Tile, Type int, robGon bool, fromID int) []Fan {
+	seat  := &state.Seats[id]
+	rules := state.Rules
+	var fans []Fan
+	bonus := func(name string, made bool) {
+		if made && rules.Count(name) {
+			fans = append(fans, Fan {name, 1})
+		}
+	}
+	if Type == COMMAND["ZIMO"] {
+		name := IF(id == state.Dealer, "天胡", "地胡").(string)
+		if state.RemainCount() > 51 && rules.Count(name) {
+			return []Fan {Fan {name, rules.HeavenTai}}
+		}
+		fans = seat.Fans(NewTile(-1, 0), rules)
+		bonus("自摸",     rules.ZimoBonus == ZimoAddTai)
+		bonus("槓上花",   seat.JustGon)
+		bonus("海底撈月", state.Wall.IsEmpty())
+		return fans
+	}
+	fans = seat.Fans(tile, rules)
+	bonus("搶槓胡", robGon)
+	bonus("槓上炮", !robGon && state.Seats[fromID].JustGon)
+	bonus("海底炮", !robGon && state.Wall.IsEmpty())
+	return fans
+}
+
+func (state *GameState) hu(id int, tile Tile, Type int, robGon, addToRoom bool, fromID int) int {
+	seat     := &state.Seats[id]
+	tai      := sumTai(seat.Fans(IF(Type == COMMAND["ZIMO"], NewTile(-1, 0), tile).(Tile), state.Rules))
+	fans     := state.huFans(id, tile, Type, robGon, fromID)
+	seat.IsHu = true
+	if state.FirstHu == -1 {
+		state.FirstHu = id
+	}
+	seat.HuTiles.Add(tile)
+	if Type == COMMAND["ZIMO"] {
+		seat.Hand.Sub(tile)
+	}
+	if addToRoom {
+		state.HuTiles.Add(tile)
+	}
+	score   := state.Rules.Score(sumTai(fans))
+	if Type == COMMAND["ZIMO"] && state.Rules.ZimoBonus == ZimoAddBase {
+		score += state.Rules.Base
+	}
+	score  *= state.Stake
+	message := IF(Type == COMMAND["HU"], "胡", "自摸").(string)
+	total   := 0
+	for i := 0; i < 4; i++ {
+		if Type == COMMAND["ZIMO"] && i != id && state.inHand(i) || Type == COMMAND["HU"] && i == fromID {
+			total += state.transfer(i, id, score, message, tile, fans)
+		}
+	}
+	record := NewScoreRecord(message, "", "", tile.ToString(), total)
+	if message == "胡" {
+		record = receivedFrom(NewScoreRecord(message, "from", state.Seats[fromID].Name, tile.ToString(), total), fromID)
+	}
+	record.Fans = fans
+	state.record(id, record)
+	seat.MaxTai = IF(seat.MaxTai < tai, tai, seat.MaxTai).(int)
+	return score
+}
+
+func (state *GameState) gon(id int, tile Tile, Type int, fromID int) int {
+	seat        := &state.Seats[id]
+	seat.JustGon = true
+	seat.gonTile(tile, Type)
+
+	score := 2 * state.Rules.Base * state.Stake
+	var message string
+	switch Type {
+	case COMMAND["PONGON"]:
+		score   = state.Rules.Base * state.Stake
+		message = "碰槓"
+	case COMMAND["ONGON"]:
+		message = "暗槓"
+	default:
+		message = "槓"
+	}
+	total := 0
+	for i := 0; i < 4; i++ {
+		if Type != COMMAND["GON"] && i != id && state.inHand(i) || Type == COMMAND["GON"] && i == fromID {
+			paid := state.transfer(i, id, score, message, tile, nil)
+			seat.GonRecord[i] += paid
+			total             += paid
+		}
+	}
+	if Type == COMMAND["GON"] {
+		state.record(id, receivedFrom(NewScoreRecord(message, "from", state.Seats[fromID].Name, tile.ToString(), total), fromID))
+	} else {
+		state.record(id, NewScoreRecord(message, "", "", tile.ToString(), total))
+	}
+	return score
+}
+
+func (state *GameState) pon(id int, tile Tile) {
+	seat := &state.Seats[id]
+	for i := 0; i < 3; i++ {
+		seat.Door.Add(tile)
+		seat.VisiableDoor.Add(tile)
+	}
+	seat.Hand.Sub(tile)
+	seat.Hand.Sub(tile)
+}
+
+func (state *GameState) end() {
+	if state.huUnder2() {
+		state.lackPenalty()
+		state.noTingPenalty()
+		state.returnMoney()
+	}
+	state.Phase = GameOver
+	state.setWaiting(false)
+	state.emit(Event {Type: EventEnd, Seat: -1, From: -1, Tile: NewTile(-1, 0)})
+}
+
+// huUnder2 returns whether the hand ends with two seats or more not won,
+// only then the penalties are paid
+func (state *GameState) huUnder2() bool {
+	count := 0
+	for i := 0; i < 4; i++ {
+		if state.Seats[i].IsHu {
+			count++
+		} else {
+			state.Seats[i].MaxTai = state.Seats[i].TingTai(state.Rules)
+			state.Seats[i].IsTing = state.Seats[i].MaxTai > 0
+		}
+	}
+	return count <= 2
+}
+
+func (state *GameState) lackPenalty() {
+	score := state.Rules.LackPenalty * state.Stake
+	for i := 0; i < 4; i++ {
+		if state.Seats[i].Hand.IsContainColor(state.Seats[i].Lack) {
+			for j := 0; j < 4; j++ {
+				if state.Seats[j].Hand[state.Seats[j].Lack].Count() == 0 && i != j && state.inHand(j) {
+					state.Seats[i].IsPenalize = true
+					paid := state.transfer(i, j, score, "花豬", NewTile(-1, 0), nil)
+					state.record(j, receivedFrom(NewScoreRecord("花豬", "from", state.Seats[i].Name, "", paid), i))
+				}
+			}
+		}
+	}
+}
+
+func (state *GameState) noTingPenalty() {
+	for i := 0; i < 4; i++ {
+		if !state.Seats[i].IsTing && !state.Seats[i].IsHu && !state.Seats[i].IsPenalize {
+			for j := 0; j < 4; j++ {
+				if state.Seats[j].IsTing && i != j {
+					score := state.Rules.Score(state.Seats[j].MaxTai) * state.Stake
+					paid  := state.transfer(i, j, score, "大叫", NewTile(-1, 0), nil)
+					state.record(j, receivedFrom(NewScoreRecord("大叫", "from", state.Seats[i].Name, "", paid), i))
+				}
+			}
+		}
+	}
+}
+
+func (state *GameState) returnMoney() {
+	for i := 0; i < 4; i++ {
+		if !state.Seats[i].IsTing && !state.Seats[i].IsHu {
+			for j := 0; j < 4; j++ {
+				score := state.Seats[i].GonRecord[j]
+				if score != 0 {
+					paid := state.transfer(i, j, score, "退稅", NewTile(-1, 0), nil)
+					state.record(j, receivedFrom(NewScoreRecord("退稅", "from", state.Seats[i].Name, "", paid), i))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"testing"
+)
+
+// suitSet returns the tiles as a suit set
+func suitSet(tiles ...string) SuitSet {
+	return ArrayToSuitSet(StringArrayToTileArray(tiles))
+}
+
+// playing returns a hand in progress of the rules, every seat lacks b and
+// the wall has 50 tiles left
+func playing(rules RuleSet, stake int) GameState {
+	state        := NewGameState(testNames, 1, rules, 0)
+	state.Phase   = Playing
+	state.Wall    = Wall {Tiles: make([]Tile, 108), Tail: 50}
+	state.HuTiles = NewSuitSet(false)
+	state.SetStake(stake, [4]int{-1, -1, -1, -1})
+	for i := 0; i < 4; i++ {
+		state.Seats[i].Lack = 2
+	}
+	return state
+}
+
+// ting is a hand of 平胡 (1 tai) which waits for d2, d5 and d8
+var ting = []string{"c2", "c3", "c4", "c5", "c6", "c7", "d2", "d3", "d4", "d5", "d6", "d7", "d8"}
+
+func credits(state GameState) [4]int {
+	var result [4]int
+	for i := 0; i < 4; i++ {
+		result[i] = state.Seats[i].Credit
+	}
+	return result
+}
+
+func TestPon(t *testing.T) {
+	state := playing(RulePresets["standard"], 1)
+	state.Seats[0].Hand = suitSet("c1", "c1", "c5")
+	state.pon(0, StringToTile("c1"))
+	seat := state.Seats[0]
+	if seat.Hand != suitSet("c5") || seat.Door != suitSet("c1", "c1", "c1") || seat.VisiableDoor != seat.Door {
+		t.Errorf("hand %v door %v after pon", seat.Hand.ToStringArray(), seat.Door.ToStringArray())
+	}
+	if credits(state) != [4]int{} {
+		t.Errorf("pon pays %v", credits(state))
+	}
+}
+
+func TestGon(t *testing.T) {
+	cases := []struct {
+		name    string
+		Type    int
+		hand    []string
+		door    []string
+		stake   int
+		credits [4]int
+		visible int
+	}{
+		{"gon",       COMMAND["GON"],    []string{"c1", "c1", "c1", "c5"},       nil,                        1,  [4]int{2, 0, -2, 0},     4},
+		{"gon stake", COMMAND["GON"],    []string{"c1", "c1", "c1", "c5"},       nil,                        10, [4]int{20, 0, -20, 0},   4},
+		{"onGon",     COMMAND["ONGON"],  []string{"c1", "c1", "c1", "c1", "c5"}, nil,                        1,  [4]int{6, -2, -2, -2},   0},
+		{"ponGon",    COMMAND["PONGON"], []string{"c1", "c5"},                   []string{"c1", "c1", "c1"}, 1,  [4]int{3, -1, -1, -1},   4},
+		{"ponGon x5", COMMAND["PONGON"], []string{"c1", "c5"},                   []string{"c1", "c1", "c1"}, 5,  [4]int{15, -5, -5, -5}, 4},
+	}
+	for _, c := range cases {
+		state := playing(RulePresets["standard"], c.stake)
+		state.Seats[0].Hand         = suitSet(c.hand...)
+		state.Seats[0].Door         = suitSet(c.door...)
+		state.Seats[0].VisiableDoor = suitSet(c.door...)
+		state.gon(0, StringToTile("c1"), c.Type, 2)
+
+		seat := state.Seats[0]
+		if seat.Hand != suitSet("c5") {
+			t.Errorf("%s: hand %v, want [c5]", c.name, seat.Hand.ToStringArray())
+		}
+		if seat.Door != suitSet("c1", "c1", "c1", "c1") {
+			t.Errorf("%s: door %v, want four c1", c.name, seat.Door.ToStringArray())
+		}
+		if int(seat.VisiableDoor.Count()) != c.visible {
+			t.Errorf("%s: %d tiles are visible, want %d", c.name, seat.VisiableDoor.Count(), c.visible)
+		}
+		if credits(state) != c.credits {
+			t.Errorf("%s: credits %v, want %v", c.name, credits(state), c.credits)
+		}
+		for i := 1; i < 4; i++ {
+			if seat.GonRecord[i] != -c.credits[i] {
+				t.Errorf("%s: gon record of seat %d is %d, want %d", c.name, i, seat.GonRecord[i], -c.credits[i])
+			}
+		}
+	}
+}
+
+func TestHu(t *testing.T) {
+	cases := []struct {
+		name    string
+		rules   string
+		Type    int
+		stake   int
+		credits [4]int
+	}{
+		{"hu",             "standard", COMMAND["HU"],   1, [4]int{1, 0, -1, 0}},
+		{"hu stake",       "standard", COMMAND["HU"],   3, [4]int{3, 0, -3, 0}},
+		{"zimo adds tai",  "standard", COMMAND["ZIMO"], 1, [4]int{6, -2, -2, -2}},
+		{"zimo adds base", "chengdu",  COMMAND["ZIMO"], 1, [4]int{6, -2, -2, -2}},
+		{"zimo stake",     "standard", COMMAND["ZIMO"], 2, [4]int{12, -4, -4, -4}},
+	}
+	for _, c := range cases {
+		state := playing(RulePresets[c.rules], c.stake)
+		state.Seats[0].Hand = suitSet(ting...)
+		tile := StringToTile("d5")
+		if c.Type == COMMAND["ZIMO"] {
+			state.Seats[0].Hand.Add(tile)
+		}
+		state.hu(0, tile, c.Type, false, true, 2)
+		if credits(state) != c.credits {
+			t.Errorf("%s: credits %v, want %v", c.name, credits(state), c.credits)
+		}
+		if !state.Seats[0].IsHu || state.FirstHu != 0 || !state.Seats[0].HuTiles.Have(tile) {
+			t.Errorf("%s: the seat hasn't won", c.name)
+		}
+	}
+}
+
+func TestCappedPayment(t *testing.T) {
+	state := playing(RulePresets["standard"], 10)
+	state.SetStake(10, [4]int{-1, 15, 5, -1})
+	state.Seats[0].Hand = suitSet("c1", "c1", "c1", "c1", "c5")
+	state.gon(0, StringToTile("c1"), COMMAND["ONGON"], 0)
+	if credits(state) != [4]int{40, -15, -5, -20} {
+		t.Errorf("credits %v, want [40 -15 -5 -20]", credits(state))
+	}
+	result := state.Result()
+	if result[1].Capped != 5 || !result[1].Bankrupt || result[2].Capped != 15 || !result[2].Bankrupt || result[3].Bankrupt {
+		t.Errorf("capped %d %d %d", result[1].Capped, result[2].Capped, result[3].Capped)
+	}
+	if state.Seats[1].Balance != 0 || state.Seats[2].Balance != 0 {
+		t.Errorf("balances %d %d after the payments", state.Seats[1].Balance, state.Seats[2].Balance)
+	}
+}
+
+func TestCappedHu(t *testing.T) {
+	state := playing(RulePresets["standard"], 10)
+	state.SetStake(10, [4]int{-1, -1, 0, -1})
+	state.Seats[0].Hand = suitSet(ting...)
+	state.hu(0, StringToTile("d5"), COMMAND["HU"], false, true, 2)
+	result := state.Result()
+	if credits(state) != [4]int{} || !result[2].Bankrupt {
+		t.Errorf("credits %v, want none paid by the bankrupt seat", credits(state))
+	}
+	if len(result[0].Fans) != 0 || len(result[0].HuTiles) != 1 || result[0].HuTiles[0] != "d5" {
+		t.Errorf("the capped hu has the fans %v and the tiles %v", result[0].Fans, result[0].HuTiles)
+	}
+}
+
+func TestPenalties(t *testing.T) {
+	state := playing(RulePresets["standard"], 1)
+	// 花豬: seat 0 holds b1 of its lack
+	state.Seats[0].Hand = suitSet("b1", "c2", "c3", "c4", "c5", "c6", "c7", "d2", "d3", "d4", "d6", "d7", "d8")
+	// 大叫: seats 1 and 3 are ting and seat 2 isn't
+	state.Seats[1].Hand = suitSet(ting...)
+	state.Seats[2].Hand = suitSet("c1", "c3", "c5", "c7", "c9", "d1", "d3", "d5", "d7", "d9", "d2", "d4", "d6")
+	state.Seats[3].Hand = suitSet(ting...)
+	// 退稅: seat 2 has gonned from seat 1 and refunds it
+	state.Seats[2].GonRecord[1] = 2
+	state.Seats[2].Credit       = 2
+	state.Seats[1].Credit       = -2
+	state.end()
+
+	// seat 0 pays 16 to each, seat 2 pays 1 to the ting seats and refunds 2
+	want := [4]int{-48, 16 + 1, 16 - 1 - 1, 16 + 1}
+	if credits(state) != want {
+		t.Errorf("credits %v, want %v", credits(state), want)
+	}
+	if !state.Seats[0].IsPenalize || !state.Seats[1].IsTing || state.Seats[2].IsTing {
+		t.Error("the seats are judged wrong")
+	}
+	reasons := map[string]int{}
+	for _, seat := range state.Seats {
+		for _, record := range seat.ScoreLog {
+			if record.Score > 0 {
+				reasons[record.Reason]++
+			}
+		}
+	}
+	if reasons[ReasonLack] != 3 || reasons[ReasonNoTing] != 2 || reasons[ReasonRefund] != 1 {
+		t.Errorf("payments %v", reasons)
+	}
+	if !state.IsOver() {
+		t.Error("the hand isn't over")
+	}
+}
+
+func TestNoPenaltyAfterThreeHu(t *testing.T) {
+	state := playing(RulePresets["standard"], 1)
+	state.Seats[0].Hand = suitSet("b1", "c2")
+	for i := 1; i < 4; i++ {
+		state.Seats[i].IsHu = true
+	}
+	state.end()
+	if credits(state) != [4]int{} {
+		t.Errorf("credits %v after three hu", credits(state))
+	}
+}
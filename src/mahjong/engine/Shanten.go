@@ -0,0 +1,204 @@
+package engine
+
+// UkeireTile represents a tile which improves the hand and the amount of it unseen
+type UkeireTile struct {
+	Tile   Tile
+	Unseen int
+}
+
+// blockSet is a bit set of the (meld, partial, pair) a suit can be split into
+type blockSet [2]uint64
+
+func blockIndex(meld int, partial int, pair int) uint {
+	return uint(meld * 16 + partial * 2 + pair)
+}
+
+func (set *blockSet) add(index uint) {
+	set[index / 64] |= 1 << (index % 64)
+}
+
+func (set blockSet) has(index uint) bool {
+	return (set[index / 64] >> (index % 64)) & 1 == 1
+}
+
+// Shanten returns how many tiles the hand is away from ting, 0 means
+// ting and -1 means hu. Tiles of lack can never be a part of the hand,
+// door is counted as melds, and seven pairs is counted if door is empty.
+// Like the usual shanten, a wait on a tile held four times is not excluded
+func Shanten(hand SuitSet, door SuitSet, lack int) int {
+	doorMeld := 0
+	useful   := 0
+	for s := 0; s < 3; s++ {
+		for v := uint(0); v < 9; v++ {
+			if door[s].GetIndex(v) >= 3 {
+				doorMeld++
+			}
+		}
+		if s != lack {
+			useful += int(hand[s].Count())
+		}
+	}
+
+	total := blockSet{}
+	total.add(blockIndex(0, 0, 0))
+	for s := 0; s < 3; s++ {
+		if s == lack {
+			continue
+		}
+		total = combineBlock(total, splitSuit(hand[s], make(map[Suit]blockSet)))
+	}
+
+	result := 8
+	for m := 0; m <= 4; m++ {
+		for t := 0; t <= 7; t++ {
+			for j := 0; j <= 1; j++ {
+				if !total.has(blockIndex(m, t, j)) || m + doorMeld + t > 4 {
+					continue
+				}
+				shanten := 8 - 2 * (m + doorMeld) - t - j
+				need    := (4 - m - doorMeld - t) + (1 - j)
+				float   := useful - 3 * m - 2 * t - 2 * j
+				if need > float {
+					shanten += need - float
+				}
+				if shanten < result {
+					result = shanten
+				}
+			}
+		}
+	}
+
+	if door.IsEmpty() {
+		pairs := 0
+		for s := 0; s < 3; s++ {
+			if s == lack {
+				continue
+			}
+			for v := uint(0); v < 9; v++ {
+				pairs += int(hand[s].GetIndex(v) / 2)
+			}
+		}
+		if pairs > 7 {
+			pairs = 7
+		}
+		shanten := 6 - pairs
+		need    := 7 - pairs
+		float   := useful - 2 * pairs
+		if need > float {
+			shanten += need - float
+		}
+		if shanten < result {
+			result = shanten
+		}
+	}
+	return result
+}
+
+// Ukeire returns the shanten of the hand and the tiles which reduce it,
+// visible is the tiles seen out of the hand and the door
+func Ukeire(hand SuitSet, door SuitSet, lack int, visible SuitSet) (int, []UkeireTile) {
+	shanten := Shanten(hand, door, lack)
+	var result []UkeireTile
+	for s := 0; s < 3; s++ {
+		if s == lack {
+			continue
+		}
+		for v := uint(0); v < 9; v++ {
+			tile := NewTile(s, v)
+			seen := int(hand[s].GetIndex(v) + door[s].GetIndex(v) + visible[s].GetIndex(v))
+			if hand[s].GetIndex(v) == 4 || seen >= 4 {
+				continue
+			}
+			hand.Add(tile)
+			if Shanten(hand, door, lack) < shanten {
+				result = append(result, UkeireTile {tile, 4 - seen})
+			}
+			hand.Sub(tile)
+		}
+	}
+	return shanten, result
+}
+
+func splitSuit(suit Suit, memo map[Suit]blockSet) blockSet {
+	if result, ok := memo[suit]; ok {
+		return result
+	}
+	result := blockSet{}
+	v      := uint(0)
+	for v < 9 && suit.GetIndex(v) == 0 {
+		v++
+	}
+	if v == 9 {
+		result.add(blockIndex(0, 0, 0))
+		memo[suit] = result
+		return result
+	}
+
+	try := func(rest Suit, meld int, partial int, pair int) {
+		sub := splitSuit(rest, memo)
+		for m := 0; m <= 4; m++ {
+			for t := 0; t <= 7; t++ {
+				for j := 0; j <= 1; j++ {
+					if sub.has(blockIndex(m, t, j)) && m + meld <= 4 && t + partial <= 7 && j + pair <= 1 {
+						result.add(blockIndex(m + meld, t + partial, j + pair))
+					}
+				}
+			}
+		}
+	}
+	count := suit.GetIndex(v)
+	rest  := suit
+	rest.Sub(v)
+	try(rest, 0, 0, 0)
+	if count >= 2 {
+		pair := rest
+		pair.Sub(v)
+		try(pair, 0, 0, 1)
+		try(pair, 0, 1, 0)
+		if count >= 3 {
+			triplet := pair
+			triplet.Sub(v)
+			try(triplet, 1, 0, 0)
+		}
+	}
+	if v + 1 < 9 && suit.GetIndex(v + 1) > 0 {
+		partial := rest
+		partial.Sub(v + 1)
+		try(partial, 0, 1, 0)
+		if v + 2 < 9 && suit.GetIndex(v + 2) > 0 {
+			sequence := partial
+			sequence.Sub(v + 2)
+			try(sequence, 1, 0, 0)
+		}
+	}
+	if v + 2 < 9 && suit.GetIndex(v + 2) > 0 {
+		partial := rest
+		partial.Sub(v + 2)
+		try(partial, 0, 1, 0)
+	}
+	memo[suit] = result
+	return result
+}
+
+func combineBlock(a blockSet, b blockSet) blockSet {
+	result := blockSet{}
+	for m1 := 0; m1 <= 4; m1++ {
+		for t1 := 0; t1 <= 7; t1++ {
+			for j1 := 0; j1 <= 1; j1++ {
+				if !a.has(blockIndex(m1, t1, j1)) {
+					continue
+				}
+				for m2 := 0; m1 + m2 <= 4; m2++ {
+					for t2 := 0; t1 + t2 <= 7; t2++ {
+						for j2 := 0; j1 + j2 <= 1; j2++ {
+							if b.has(blockIndex(m2, t2, j2)) {
+								result.add(blockIndex(m1 + m2, t1 + t2, j1 + j2))
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return result
+}
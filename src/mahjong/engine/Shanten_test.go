@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestShanten(t *testing.T) {
+	cases := []struct {
+		name    string
+		hand    []string
+		door    []string
+		lack    int
+		shanten int
+		waits   []string
+	}{
+		{"hu", []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7", "c8", "c9", "d1", "d2", "d3", "d5", "d5"}, nil, 2, -1, nil},
+		{"hu of seven pairs", []string{"c1", "c1", "c3", "c3", "c5", "c5", "c7", "c7", "d2", "d2", "d4", "d4", "d6", "d6"}, nil, 2, -1, nil},
+		{"hu with door", []string{"c2", "c3", "c4", "c5", "c6", "c7", "d2", "d3", "d4", "d5", "d5"}, []string{"c1", "c1", "c1"}, 2, -1, nil},
+		{"ting of three waits", ting, nil, 2, 0, []string{"d2", "d5", "d8"}},
+		{"ting of a single wait", []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7", "c8", "c9", "d1", "d2", "d3", "d5"}, nil, 2, 0, []string{"d5"}},
+		{"ting of seven pairs", []string{"c1", "c1", "c3", "c3", "c5", "c5", "c7", "c7", "d2", "d2", "d4", "d4", "d9"}, nil, 2, 0, []string{"d9"}},
+		{"ting with door", []string{"c2", "c3", "c4", "c5", "c6", "c7", "d2", "d3", "d4", "d5"}, []string{"c1", "c1", "c1"}, 2, 0, []string{"d2", "d5"}},
+		{"tile of lack", []string{"c2", "c3", "c4", "c5", "c6", "c7", "d2", "d3", "d4", "d6", "d7", "d8", "b1"}, nil, 2, 1, nil},
+		{"tiles of lack", []string{"c2", "c3", "c4", "c5", "c6", "c7", "d2", "d3", "d4", "b1", "b2", "b3", "b5"}, nil, 2, 4, nil},
+		{"lack counted", []string{"c2", "c3", "c4", "c5", "c6", "c7", "d2", "d3", "d4", "b1", "b2", "b3", "b5"}, nil, 1, 3, nil},
+		{"two away", []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7", "c8", "d1", "d2", "d5", "d7", "d9"}, nil, 2, 2, nil},
+		{"one away", []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7", "c8", "d1", "d2", "d5", "d5", "d9"}, nil, 2, 1, nil},
+	}
+	for _, c := range cases {
+		hand := suitSet(c.hand...)
+		door := suitSet(c.door...)
+		if shanten := Shanten(hand, door, c.lack); shanten != c.shanten {
+			t.Errorf("%s: shanten %d, want %d", c.name, shanten, c.shanten)
+		}
+		if c.shanten != 0 {
+			continue
+		}
+		_, ukeire := Ukeire(hand, door, c.lack, NewSuitSet(false))
+		var waits []string
+		for _, tile := range ukeire {
+			waits = append(waits, tile.Tile.ToString())
+			if tile.Tile.Suit == c.lack {
+				t.Errorf("%s: waits for %s of lack", c.name, tile.Tile.ToString())
+			}
+		}
+		if !reflect.DeepEqual(waits, c.waits) {
+			t.Errorf("%s: waits %v, want %v", c.name, waits, c.waits)
+		}
+		for _, wait := range c.waits {
+			won := hand
+			won.Add(StringToTile(wait))
+			if Shanten(won, door, c.lack) != -1 {
+				t.Errorf("%s: %s doesn't complete the hand", c.name, wait)
+			}
+		}
+	}
+}
+
+func TestUkeireUnseen(t *testing.T) {
+	hand := suitSet(ting...)
+	_, ukeire := Ukeire(hand, NewSuitSet(false), 2, suitSet("d2", "d2", "d5", "d8", "d8", "d8"))
+	unseen := map[string]int{}
+	for _, tile := range ukeire {
+		unseen[tile.Tile.ToString()] = tile.Unseen
+	}
+	if !reflect.DeepEqual(unseen, map[string]int {"d2": 1, "d5": 2}) {
+		t.Errorf("unseen %v", unseen)
+	}
+}
+
+// hands returns n random hands of 14 tiles of the deck
+func hands(n int) []SuitSet {
+	random := rand.New(rand.NewSource(1))
+	result := make([]SuitSet, n)
+	for i := range result {
+		deck := NewSuitSet(true)
+		for j := 0; j < 14; j++ {
+			result[i].Add(deck.Draw(random))
+		}
+	}
+	return result
+}
+
+func BenchmarkShanten(b *testing.B) {
+	list := hands(1024)
+	door := NewSuitSet(false)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Shanten(list[i % len(list)], door, i % 3)
+	}
+}
+
+func BenchmarkUkeire(b *testing.B) {
+	list    := hands(1024)
+	door    := NewSuitSet(false)
+	visible := NewSuitSet(false)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hand := list[i % len(list)]
+		hand.Sub(hand.At(0))
+		Ukeire(hand, door, i % 3, visible)
+	}
+}
@@ -1,4 +1,4 @@
-package mahjong
+package engine
 
 // Suit represents a mahjong suit
 type Suit uint32
@@ -1,4 +1,4 @@
-package mahjong
+package engine
 
 import (
 	"strconv"
@@ -63,10 +63,10 @@ func (suitSet SuitSet) Count() uint {
 	return amount
 }
 
-// Draw draws a tile from suit set
-func (suitSet *SuitSet) Draw() Tile {
+// Draw draws a tile from suit set with the random source
+func (suitSet *SuitSet) Draw(random *rand.Rand) Tile {
 	amount := int32(suitSet.Count())
-	tile   := suitSet.At(int(rand.Int31n(amount)))
+	tile   := suitSet.At(int(random.Int31n(amount)))
 	suitSet.Sub(tile)
 	return tile
 }
@@ -100,6 +100,19 @@ func (suitSet SuitSet) ToStringArray() []string {
 	return result
 }
 
+// ToTileArray converts suit set to tile array
+func (suitSet SuitSet) ToTileArray() []Tile {
+	var result []Tile
+	for s := 0; s < 3; s++ {
+		for v := uint(0); v < 9; v++ {
+			for n := uint(0); n < suitSet[s].GetIndex(v); n++ {
+				result = append(result, NewTile(s, v))
+			}
+		}
+	}
+	return result
+}
+
 // Add adds a tile or a suit set to a suit set
 func (suitSet *SuitSet) Add(input interface{}) {
 	switch input.(type) {
@@ -1,6 +1,7 @@
-package mahjong
+package engine
 
 import (
+	"encoding/json"
 	"strconv"
 )
 
@@ -30,6 +31,21 @@ func (tile Tile) ToString() string {
 	return suitStr[tile.Suit] + strconv.Itoa(int(tile.Value + 1))
 }
 
+// MarshalJSON converts tile to json string
+func (tile Tile) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tile.ToString())
+}
+
+// UnmarshalJSON converts json string to tile
+func (tile *Tile) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	*tile = StringToTile(str)
+	return nil
+}
+
 // StringArrayToTileArray converts string array to tile array
 func StringArrayToTileArray(tiles []string) []Tile {
 	var res []Tile
@@ -41,7 +57,7 @@ func StringArrayToTileArray(tiles []string) []Tile {
 
 // StringToTile converts string to tile
 func StringToTile(tile string) Tile {
-	if tile == "" {
+	if len(tile) < 2 {
 		return NewTile(-1, 0)
 	}
 	r    := []rune(tile)
@@ -63,7 +79,7 @@ func StringToTile(tile string) Tile {
 
 // IsValidTile checks if tile string is vaild
 func IsValidTile(tile string) bool {
-	if tile == "" {
+	if len(tile) < 2 {
 		return false
 	}
 	r    := []rune(tile)
@@ -1,4 +1,4 @@
-package mahjong
+package engine
 
 // IF implements ternary conditional operator
 func IF(condition bool, trueVal, falseVal interface{}) interface{} {
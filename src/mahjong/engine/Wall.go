@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"math/rand"
+)
+
+// Wall represents the tiles to draw in order
+//
+// Tiles are the shuffled tiles around the table, and the wall is broken
+// at Break by the Dice. A tile is drawn from the front after Break and a
+// replacement after a gon from the back, Head and Tail count the tiles
+// drawn from the front and left before the back. Tiles never change
+// after the wall is built, so the clones share them
+type Wall struct {
+	Tiles []Tile
+	Dice  [2]int
+	Break int
+	Head  int
+	Tail  int
+}
+
+// NewWall shuffles the tiles and rolls the dice, the side of the wall
+// counted from the dealer by the dice sum is broken
+func NewWall(random *rand.Rand, dealer int) Wall {
+	tiles := NewSuitSet(true).ToTileArray()
+	wall  := Wall {Tiles: make([]Tile, len(tiles)), Tail: len(tiles)}
+	for i, j := range random.Perm(len(tiles)) {
+		wall.Tiles[i] = tiles[j]
+	}
+	wall.Dice  = [2]int{random.Intn(6) + 1, random.Intn(6) + 1}
+	sum       := wall.Dice[0] + wall.Dice[1]
+	side      := (dealer + sum - 1) % 4
+	wall.Break = (side * len(tiles) / 4 + sum * 2) % len(tiles)
+	return wall
+}
+
+// Remain returns the amount of tiles left
+func (wall Wall) Remain() int {
+	return wall.Tail - wall.Head
+}
+
+// IsEmpty returns if no tile is left
+func (wall Wall) IsEmpty() bool {
+	return wall.Remain() == 0
+}
+
+// Draw draws a tile from the front
+func (wall *Wall) Draw() Tile {
+	tile := wall.Tiles[(wall.Break + wall.Head) % len(wall.Tiles)]
+	wall.Head++
+	return tile
+}
+
+// DrawBack draws a tile from the back
+func (wall *Wall) DrawBack() Tile {
+	wall.Tail--
+	return wall.Tiles[(wall.Break + wall.Tail) % len(wall.Tiles)]
+}
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func seededWall(seed int64, dealer int) Wall {
+	return NewWall(rand.New(rand.NewSource(seed)), dealer)
+}
+
+func TestNewWall(t *testing.T) {
+	full := NewSuitSet(true)
+	for seed := int64(1); seed <= 20; seed++ {
+		wall := seededWall(seed, 0)
+		if again := seededWall(seed, 0); !reflect.DeepEqual(wall, again) {
+			t.Fatalf("seed %d: the walls differ, dice %v and %v, break %d and %d", seed, wall.Dice, again.Dice, wall.Break, again.Break)
+		}
+		if ArrayToSuitSet(wall.Tiles) != full || wall.Head != 0 || wall.Tail != len(wall.Tiles) {
+			t.Fatalf("seed %d: the wall isn't a full set of tiles", seed)
+		}
+		sum := wall.Dice[0] + wall.Dice[1]
+		if wall.Dice[0] < 1 || wall.Dice[0] > 6 || wall.Dice[1] < 1 || wall.Dice[1] > 6 {
+			t.Fatalf("seed %d: the dice are %v", seed, wall.Dice)
+		}
+		if wall.Break != ((sum - 1) % 4 * 27 + sum * 2) % 108 {
+			t.Errorf("seed %d: the wall breaks at %d for the dice %v", seed, wall.Break, wall.Dice)
+		}
+		if other := seededWall(seed, 1); other.Dice != wall.Dice || other.Break != (wall.Break + 27) % 108 {
+			t.Errorf("seed %d: the next dealer's wall breaks at %d, want %d", seed, other.Break, (wall.Break + 27) % 108)
+		}
+	}
+	if reflect.DeepEqual(seededWall(1, 0).Tiles, seededWall(2, 0).Tiles) {
+		t.Error("two seeds shuffle the same wall")
+	}
+}
+
+func TestWallDraw(t *testing.T) {
+	wall   := seededWall(7, 2)
+	tiles  := wall.Tiles
+	n      := len(tiles)
+	var drawn []Tile
+	for i := 0; !wall.IsEmpty(); i++ {
+		remain := wall.Remain()
+		var tile, want Tile
+		if i % 3 == 2 {
+			want = tiles[(wall.Break + wall.Tail - 1) % n]
+			tile = wall.DrawBack()
+		} else {
+			want = tiles[(wall.Break + wall.Head) % n]
+			tile = wall.Draw()
+		}
+		if tile != want {
+			t.Fatalf("draw %d: got %s, want %s", i, tile.ToString(), want.ToString())
+		}
+		if wall.Remain() != remain - 1 || wall.Remain() != n - len(drawn) - 1 {
+			t.Fatalf("draw %d: %d tiles remain, want %d", i, wall.Remain(), remain - 1)
+		}
+		drawn = append(drawn, tile)
+	}
+	if len(drawn) != n || ArrayToSuitSet(drawn) != NewSuitSet(true) {
+		t.Errorf("%d tiles are drawn, want every tile once", len(drawn))
+	}
+	if wall.Head != wall.Tail {
+		t.Errorf("the wall is empty with the head %d and the tail %d", wall.Head, wall.Tail)
+	}
+
+	back := seededWall(7, 2)
+	if first, last := back.DrawBack(), tiles[(back.Break + n - 1) % n]; first != last {
+		t.Errorf("the first replacement is %s, want the tail tile %s", first.ToString(), last.ToString())
+	}
+}
+
+func TestLastDraw(t *testing.T) {
+	for _, remain := range []int{2, 1} {
+		state              := playing(RulePresets["standard"], 1)
+		state.Wall          = seededWall(1, 0)
+		state.Wall.Head     = state.Wall.Tail - remain
+		state.Seats[0].Hand = suitSet(ting...)
+		state.Wall.Tiles[(state.Wall.Break + state.Wall.Head) % len(state.Wall.Tiles)] = StringToTile("d5")
+		state.turn(0)
+		_, events := state.flush()
+		last := remain == 1
+		if len(events) != 1 || events[0].Type != EventDraw || events[0].Value != remain - 1 {
+			t.Fatalf("%d left: events %+v, want a draw leaving %d", remain, events, remain - 1)
+		}
+		if state.Wall.IsEmpty() != last {
+			t.Errorf("%d left: the wall is empty %v after the draw", remain, state.Wall.IsEmpty())
+		}
+		haidi := false
+		for _, fan := range state.huFans(0, NewTile(-1, 0), COMMAND["ZIMO"], false, -1) {
+			haidi = haidi || fan.Name == "海底撈月"
+		}
+		if haidi != last {
+			t.Errorf("%d left: 海底撈月 is %v on a zimo of the draw", remain, haidi)
+		}
+	}
+}
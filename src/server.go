@@ -1,30 +1,78 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
 	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/rs/cors"
 
 	"mahjong"
+	"mahjong/engine"
 )
 
 func main() {
+	dataDir  := flag.String("data", ".", "directory where the accounts, game logs, results, ratings, ledger and leaderboards are stored")
+	botWait  := flag.Duration("bot", 30 * time.Second, "waiting time before bots fill the empty seats, 0 disables bots")
+	timeout  := flag.Duration("queue", 10 * time.Minute, "time a player stays in the matchmaking queue, 0 disables the timeout")
+	huTable  := flag.String("table", "hutable.bin", "file where the hu table is cached")
+	secret   := flag.String("secret", "secret.key", "file where the key signing the tokens is stored")
+	rule     := flag.String("rule", engine.DefaultRule, "name of the rule set preset")
+	tier     := flag.String("tier", mahjong.DefaultTier, "tier of the players who don't ask for one")
+	bankrupt := flag.String("bankrupt", mahjong.BankruptcyCap, "what happens to a player who can't cover a payment, cap or leave")
+	credits  := flag.Int("credits", mahjong.StartingCredits, "credits granted to a new account")
+	season   := flag.Duration("season", mahjong.SeasonLength, "length of a season of the leaderboards")
+	flag.Parse()
 	rand.Seed(time.Now().Unix())
+	mahjong.DataDir         = *dataDir
+	mahjong.BotWaitingTime  = *botWait
+	mahjong.QueueTimeout    = *timeout
+	mahjong.HuTablePath     = *huTable
+	mahjong.SecretPath      = *secret
+	rules, ok := engine.GetRuleSet(*rule)
+	if !ok {
+		log.Fatal("unknown rule set: ", *rule)
+	}
+	mahjong.DefaultRules = rules
+	if _, ok := mahjong.Tiers[*tier]; !ok {
+		log.Fatal("unknown tier: ", *tier)
+	}
+	if *bankrupt != mahjong.BankruptcyCap && *bankrupt != mahjong.BankruptcyLeave {
+		log.Fatal("unknown bankruptcy rule: ", *bankrupt)
+	}
+	mahjong.DefaultTier     = *tier
+	mahjong.BankruptcyRule  = *bankrupt
+	mahjong.StartingCredits = *credits
+	if *season <= 0 {
+		log.Fatal("season must be longer than 0")
+	}
+	mahjong.SeasonLength    = *season
 
 	err := mahjong.NewGameManager()
 	if err {
 		return
 	}
-	go mahjong.Exec()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		mahjong.CloseGameManager()
+		os.Exit(0)
+	}()
 
 	mahjong.GetServer().On("connection", mahjong.SocketConnect)
 	mahjong.GetServer().On("error",      mahjong.SocketError)
 
 	mux := http.NewServeMux()
 	mux.Handle("/socket.io/", mahjong.GetServer())
+	mux.HandleFunc("/api/hands", mahjong.ServeHands)
+	mux.HandleFunc("/api/leaderboard", mahjong.ServeLeaderboard)
+	mux.HandleFunc("/api/leaderboard/archives", mahjong.ServeArchives)
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"http://140.118.127.157:9000"},
 		AllowCredentials: true,